@@ -0,0 +1,212 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var reInn = regexp.MustCompile(`^(\d{10}|\d{12})$`)
+
+// agentTypeNames содержит расшифровку битов признака агента.
+var agentTypeNames = map[AgentType]string{
+	AgentBankPaying:    "банковский платежный агент",
+	AgentBankSubPaying: "банковский платежный субагент",
+	AgentPaying:        "платежный агент",
+	AgentSubPaying:     "платежный субагент",
+	AgentAttorney:      "поверенный",
+	AgentCommission:    "комиссионер",
+	AgentOther:         "иной агент",
+}
+
+// String возвращает расшифровку признака агента.
+func (t AgentType) String() string {
+	var names []string
+	for bit := AgentBankPaying; bit <= AgentOther; bit <<= 1 {
+		if t&bit != 0 {
+			names = append(names, agentTypeNames[bit])
+		}
+	}
+	if len(names) == 0 {
+		return "нет"
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseAgentType разбирает признак агента из атрибута T1057 данных регистрации.
+func ParseAgentType(attr string) AgentType {
+	v, err := strconv.Atoi(strings.TrimSpace(attr))
+	if err != nil {
+		return 0
+	}
+	return AgentType(v)
+}
+
+// ValidateCheckAgent проверяет агентские реквизиты чека по данным регистрации.
+func ValidateCheckAgent(reg *RegData, agent *CheckAgent) error {
+	if agent == nil {
+		return nil
+	}
+	if agent.Type == 0 {
+		return fmt.Errorf("не задан признак агента (T1057)")
+	}
+	if err := checkAgentRegistered(reg, agent.Type); err != nil {
+		return err
+	}
+	if err := validateAgentData(agent.Type, agent.Data); err != nil {
+		return err
+	}
+	return validatePhones("T1171", agent.SupplierPhones)
+}
+
+// ValidateItemAgent проверяет агентские реквизиты предмета расчета по данным регистрации.
+func ValidateItemAgent(reg *RegData, agent *ItemAgent) error {
+	if agent == nil {
+		return nil
+	}
+	if agent.Type == 0 || agent.Type&(agent.Type-1) != 0 {
+		return fmt.Errorf("признак агента по предмету расчета (T1222) должен содержать ровно одно значение")
+	}
+	if err := checkAgentRegistered(reg, agent.Type); err != nil {
+		return err
+	}
+	if !reInn.MatchString(agent.SupplierInn) {
+		return fmt.Errorf("не задан или некорректен ИНН поставщика (T1226): %q", agent.SupplierInn)
+	}
+	if agent.Supplier == nil || strings.TrimSpace(agent.Supplier.Name) == "" {
+		return fmt.Errorf("не задано наименование поставщика (T1225)")
+	}
+	if err := validatePhones("T1171", agent.Supplier.Phones); err != nil {
+		return err
+	}
+	return validateAgentData(agent.Type, agent.Data)
+}
+
+// checkAgentRegistered проверяет, что ККТ зарегистрирована в режиме агента
+// и признак входит в зарегистрированную маску T1057.
+func checkAgentRegistered(reg *RegData, t AgentType) error {
+	if reg == nil {
+		return fmt.Errorf("нет данных регистрации для проверки признака агента")
+	}
+	registered := ParseAgentType(reg.AgentAttr)
+	if registered == 0 {
+		return fmt.Errorf("ККТ не зарегистрирована в режиме агента (T1057)")
+	}
+	if t&^registered != 0 {
+		return fmt.Errorf("признак агента '%s' не соответствует регистрации ('%s')", t&^registered, registered)
+	}
+	return nil
+}
+
+// validateAgentData проверяет обязательные данные платежных агентов.
+func validateAgentData(t AgentType, data *AgentData) error {
+	bank := t&(AgentBankPaying|AgentBankSubPaying) != 0
+	paying := t&(AgentPaying|AgentSubPaying) != 0
+
+	if data == nil {
+		if bank || paying {
+			return fmt.Errorf("для платежного агента необходимо задать данные агента")
+		}
+		return nil
+	}
+
+	if bank && strings.TrimSpace(data.Operation) == "" {
+		return fmt.Errorf("для банковского платежного агента необходимо задать операцию (T1044)")
+	}
+	if (bank || paying) && len(data.AgentPhones) == 0 {
+		return fmt.Errorf("для платежного агента необходимо задать телефон агента (T1073)")
+	}
+	if paying && len(data.OperatorPhones) == 0 {
+		return fmt.Errorf("для платежного агента необходимо задать телефон оператора по приему платежей (T1074)")
+	}
+	if data.TransferOperatorInn != "" && !reInn.MatchString(data.TransferOperatorInn) {
+		return fmt.Errorf("некорректный ИНН оператора перевода (T1016): %s", data.TransferOperatorInn)
+	}
+
+	for tag, phones := range map[string][]string{
+		"T1073": data.AgentPhones,
+		"T1074": data.OperatorPhones,
+		"T1075": data.TransferOperatorPhones,
+	} {
+		if err := validatePhones(tag, phones); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePhones(tag string, phones []string) error {
+	for _, p := range phones {
+		if !reCustomerPhone.MatchString(p) {
+			return fmt.Errorf("некорректный телефон в %s: %q (ожидается +7XXXXXXXXXX)", tag, p)
+		}
+	}
+	return nil
+}
+
+// agentDataTags формирует теги данных агента.
+func agentDataTags(data *AgentData) string {
+	if data == nil {
+		return ""
+	}
+	var sb strings.Builder
+	writeTag(&sb, 1044, data.Operation)
+	for _, p := range data.AgentPhones {
+		writeTag(&sb, 1073, p)
+	}
+	for _, p := range data.OperatorPhones {
+		writeTag(&sb, 1074, p)
+	}
+	for _, p := range data.TransferOperatorPhones {
+		writeTag(&sb, 1075, p)
+	}
+	writeTag(&sb, 1026, data.TransferOperatorName)
+	writeTag(&sb, 1005, data.TransferOperatorAddress)
+	writeTag(&sb, 1016, data.TransferOperatorInn)
+	return sb.String()
+}
+
+// checkAgentTags формирует теги агента уровня чека для команды <Do CHECK='OPEN'>.
+func checkAgentTags(agent *CheckAgent) string {
+	if agent == nil {
+		return ""
+	}
+	var sb strings.Builder
+	writeTag(&sb, 1057, strconv.Itoa(int(agent.Type)))
+	sb.WriteString(agentDataTags(agent.Data))
+	for _, p := range agent.SupplierPhones {
+		writeTag(&sb, 1171, p)
+	}
+	return sb.String()
+}
+
+// itemAgentTags формирует теги агента предмета расчета для команды <ADD>.
+func itemAgentTags(agent *ItemAgent) string {
+	if agent == nil {
+		return ""
+	}
+	var sb strings.Builder
+	writeTag(&sb, 1222, strconv.Itoa(int(agent.Type)))
+	if data := agentDataTags(agent.Data); data != "" {
+		sb.WriteString("<T1223>" + data + "</T1223>")
+	}
+	if agent.Supplier != nil {
+		var sup strings.Builder
+		for _, p := range agent.Supplier.Phones {
+			writeTag(&sup, 1171, p)
+		}
+		writeTag(&sup, 1225, agent.Supplier.Name)
+		sb.WriteString("<T1224>" + sup.String() + "</T1224>")
+	}
+	writeTag(&sb, 1226, agent.SupplierInn)
+	return sb.String()
+}
+
+// writeTag добавляет тег <Tnnnn>значение</Tnnnn>, если значение не пустое.
+func writeTag(sb *strings.Builder, tag int, value string) {
+	if value == "" {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("<T%d>%s</T%d>", tag, escapeXMLText(value), tag))
+}
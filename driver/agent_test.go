@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateItemAgent(t *testing.T) {
+	reg := &RegData{AgentAttr: "96"} // комиссионер + иной агент
+	commission := &ItemAgent{
+		Type:        AgentCommission,
+		Supplier:    &SupplierData{Name: "ООО Поставщик", Phones: []string{"+79990001122"}},
+		SupplierInn: "7707083893",
+	}
+
+	if err := ValidateItemAgent(reg, commission); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateItemAgent(&RegData{}, commission); err == nil {
+		t.Error("expected error for register without agent mode")
+	}
+
+	noInn := *commission
+	noInn.SupplierInn = ""
+	if err := ValidateItemAgent(reg, &noInn); err == nil {
+		t.Error("expected error for missing supplier INN")
+	}
+
+	twoBits := *commission
+	twoBits.Type = AgentCommission | AgentOther
+	if err := ValidateItemAgent(reg, &twoBits); err == nil {
+		t.Error("expected error for several agent bits in T1222")
+	}
+
+	notRegistered := *commission
+	notRegistered.Type = AgentAttorney
+	if err := ValidateItemAgent(reg, &notRegistered); err == nil {
+		t.Error("expected error for agent type outside of registration")
+	}
+}
+
+func TestValidateCheckAgentPaying(t *testing.T) {
+	reg := &RegData{AgentAttr: "4"}
+	agent := &CheckAgent{Type: AgentPaying, Data: &AgentData{AgentPhones: []string{"+79990001122"}}}
+	if err := ValidateCheckAgent(reg, agent); err == nil {
+		t.Error("expected error for missing operator phone (T1074)")
+	}
+	agent.Data.OperatorPhones = []string{"+74950001122"}
+	if err := ValidateCheckAgent(reg, agent); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestItemAgentTags(t *testing.T) {
+	tags := itemAgentTags(&ItemAgent{
+		Type:        AgentCommission,
+		Supplier:    &SupplierData{Name: "ООО Поставщик", Phones: []string{"+79990001122"}},
+		SupplierInn: "7707083893",
+	})
+	expected := "<T1222>32</T1222><T1224><T1171>+79990001122</T1171><T1225>ООО Поставщик</T1225></T1224><T1226>7707083893</T1226>"
+	if tags != expected {
+		t.Errorf("expected %s, got %s", expected, tags)
+	}
+
+	checkTags := checkAgentTags(&CheckAgent{Type: AgentPaying, Data: &AgentData{Operation: "Оплата"}})
+	if !strings.HasPrefix(checkTags, "<T1057>4</T1057><T1044>Оплата</T1044>") {
+		t.Errorf("unexpected check tags: %s", checkTags)
+	}
+}
@@ -45,26 +45,157 @@ func (d *mitsuDriver) PrintXReport() error {
 	return err
 }
 
+// ReportCurrentState формирует и печатает отчет о текущем состоянии расчетов.
+// Возвращает FD и FP отчета, количество неотправленных в ОФД документов и
+// дату/время первого из них. Если отчет сформирован, но не напечатан,
+// возвращается отчет вместе с ошибкой печати.
+func (d *mitsuDriver) ReportCurrentState() (*CurrentStateReport, error) {
+	rep, err := d.printCurrentStateReport()
+	if err != nil {
+		return rep, err
+	}
+
+	ofd, err := d.GetOfdExchangeStatus()
+	if err != nil {
+		return rep, fmt.Errorf("ошибка получения статуса обмена с ОФД: %w", err)
+	}
+	rep.Unsent = ofd.Count
+	rep.FirstUnsent = ofd.FirstDoc
+	rep.FirstUnsentDate = ofd.Date
+	rep.FirstUnsentTime = ofd.Time
+	return rep, nil
+}
+
 // PrintZReport печатает отчет по расчетам (Этот отчет не закрывает смену!).
+// Это отчет о текущем состоянии расчетов, см. ReportCurrentState.
 func (d *mitsuDriver) PrintZReport() error {
-	// Формируем отчет
-	_, err := d.sendCommand("<MAKE REPORT='Z'/>")
+	_, err := d.printCurrentStateReport()
+	return err
+}
+
+// printCurrentStateReport формирует и печатает отчет о текущем состоянии расчетов.
+func (d *mitsuDriver) printCurrentStateReport() (*CurrentStateReport, error) {
+	resp, err := d.sendCommand("<MAKE REPORT='Z'/>")
 	if err != nil {
+		return nil, fmt.Errorf("ошибка формирования отчета о текущем состоянии расчетов: %w", err)
+	}
+	// Отчет уже записан в ФН: ответ разбирается без прерывания печати
+	var rep CurrentStateReport
+	if err := decodeXML(resp, &rep); err != nil {
+		rep = CurrentStateReport{}
+		if d.config.Logger != nil {
+			d.config.Logger(fmt.Sprintf("Ошибка парсинга ответа отчета о текущем состоянии расчетов: %v", err))
+		}
+	}
+
+	if _, err := d.sendCommand("<PRINT/>"); err != nil {
+		return &rep, fmt.Errorf("отчет о текущем состоянии расчетов сформирован (ФД %d), но не напечатан: %w", rep.FD, err)
+	}
+	return &rep, nil
+}
+
+// CashIn выполняет внесение наличных в денежный ящик.
+// operator: кассир, устанавливаемый перед операцией (пустая строка - не менять).
+// print: печатать ли документ о внесении.
+func (d *mitsuDriver) CashIn(amount float64, operator string, print bool) error {
+	return d.cashOperation("INCOME", amount, operator, print)
+}
+
+// CashOut выполняет изъятие (выплату) наличных из денежного ящика.
+// operator: кассир, устанавливаемый перед операцией (пустая строка - не менять).
+// print: печатать ли документ об изъятии.
+func (d *mitsuDriver) CashOut(amount float64, operator string, print bool) error {
+	return d.cashOperation("PAYOUT", amount, operator, print)
+}
+
+// cashOperation формирует команду внесения/выплаты.
+// Суммы отражаются в итогах смены (<GET INFO='1'/>: INCOME, PAYOUT, CASH).
+func (d *mitsuDriver) cashOperation(kind string, amount float64, operator string, print bool) error {
+	if amount <= 0 {
+		return fmt.Errorf("сумма операции должна быть больше нуля: %.2f", amount)
+	}
+
+	// Устанавливаем кассира перед операцией
+	if operator != "" {
+		if err := d.SetCashier(operator, ""); err != nil {
+			return fmt.Errorf("ошибка установки кассира: %w", err)
+		}
+	}
+
+	cmd := fmt.Sprintf("<Do CASH='%s' TOTAL='%.2f'/>", kind, amount)
+	if _, err := d.sendCommand(cmd); err != nil {
 		return err
 	}
 
-	// Печатаем отчет
-	_, err = d.sendCommand("<PRINT/>")
+	if !print {
+		return nil
+	}
+
+	// Печатаем документ
+	_, err := d.sendCommand("<PRINT/>")
 	return err
 }
 
 // OpenCheck открывает чек.
 func (d *mitsuDriver) OpenCheck(checkType int, taxSystem int) error {
+	d.resetCheckRegData()
 	cmd := fmt.Sprintf("<Do CHECK='OPEN' TYPE='%d' TAX='%d' MERGE='0'/>", checkType, taxSystem)
 	_, err := d.sendCommand(cmd)
 	return err
 }
 
+// OpenCheckWithOptions открывает чек с дополнительными реквизитами уровня чека.
+// Агентские, отраслевые и операционный реквизиты проверяются по данным регистрации
+// (режимы и версия ФФД) до открытия чека. Данные регистрации запрашиваются
+// один раз и используются для всех позиций чека.
+func (d *mitsuDriver) OpenCheckWithOptions(checkType int, taxSystem int, opts OpenCheckOptions) error {
+	d.resetCheckRegData()
+	reg, err := d.checkRegData()
+	if err != nil {
+		return err
+	}
+	if err := ValidateCheckAgent(reg, opts.Agent); err != nil {
+		return err
+	}
+	if err := ValidateIndustryRequisites(reg.FfdVer, opts.Industry); err != nil {
+		return err
+	}
+	if err := ValidateOperationalRequisite(reg.FfdVer, opts.Operational); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("<Do CHECK='OPEN' TYPE='%d' TAX='%d' MERGE='0'/>", checkType, taxSystem)
+	if tags := checkAgentTags(opts.Agent) + industryTags(1261, opts.Industry) + operationalTags(opts.Operational); tags != "" {
+		cmd = fmt.Sprintf("<Do CHECK='OPEN' TYPE='%d' TAX='%d' MERGE='0'>%s</Do>", checkType, taxSystem, tags)
+	}
+	_, err = d.sendCommand(cmd)
+	return err
+}
+
+// checkRegData возвращает данные регистрации для текущего чека. Данные
+// запрашиваются у ККТ один раз и сбрасываются при открытии, закрытии и
+// отмене чека.
+func (d *mitsuDriver) checkRegData() (*RegData, error) {
+	d.checkRegMu.Lock()
+	defer d.checkRegMu.Unlock()
+	if d.checkReg != nil {
+		return d.checkReg, nil
+	}
+	reg, err := d.GetRegistrationData()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения рег. данных: %w", err)
+	}
+	d.checkReg = reg
+	return reg, nil
+}
+
+// resetCheckRegData сбрасывает данные регистрации текущего чека.
+func (d *mitsuDriver) resetCheckRegData() {
+	d.checkRegMu.Lock()
+	d.checkReg = nil
+	d.checkRegMu.Unlock()
+}
+
 // AddPosition добавляет позицию в чек.
 func (d *mitsuDriver) AddPosition(pos ItemPosition) error {
 	// Маппинг TaxRate: 0->6 (Без НДС), 1->1 (20%), 2->2 (10%), 3->3 (20/120), 4->4 (10/110), 5->5 (0%), 6->6 (Без НДС)
@@ -82,12 +213,46 @@ func (d *mitsuDriver) AddPosition(pos ItemPosition) error {
 		tax = 6 // по умолчанию Без НДС
 	}
 
+	// Дробное количество маркированного товара задает количество позиции
+	if pos.Mark != nil && pos.Mark.Fraction != nil && pos.Quantity == 0 {
+		pos.Quantity = pos.Mark.Fraction.FractionQuantity()
+	}
+
+	// Агентские, отраслевые реквизиты и код маркировки предмета расчета
+	// проверяются по данным регистрации
+	ffdVer := ""
+	if pos.Agent != nil || len(pos.Industry) > 0 || pos.Mark != nil {
+		reg, err := d.checkRegData()
+		if err != nil {
+			return err
+		}
+		if err := ValidateItemAgent(reg, pos.Agent); err != nil {
+			return err
+		}
+		if err := ValidateIndustryRequisites(reg.FfdVer, pos.Industry); err != nil {
+			return err
+		}
+		if err := ValidateItemMark(reg.FfdVer, pos.Mark, pos.Quantity); err != nil {
+			return err
+		}
+		ffdVer = reg.FfdVer
+	}
+
+	markTags, err := itemMarkTags(ffdVer, pos.Mark)
+	if err != nil {
+		return err
+	}
+
+	unit := 0
+	if pos.Mark != nil {
+		unit = pos.Mark.Measure
+	}
 	total := pos.Price * pos.Quantity
 	safeName := escapeXMLText(pos.Name)
 
-	cmd := fmt.Sprintf("<ADD ITEM='%.3f' TAX='%d' UNIT='0' PRICE='%.2f' TOTAL='%.2f' TYPE='1' MODE='4'><NAME>%s</NAME></ADD>",
-		pos.Quantity, tax, pos.Price, total, safeName)
-	_, err := d.sendCommand(cmd)
+	cmd := fmt.Sprintf("<ADD ITEM='%.3f' TAX='%d' UNIT='%d' PRICE='%.2f' TOTAL='%.2f' TYPE='1' MODE='4'><NAME>%s</NAME>%s%s%s</ADD>",
+		pos.Quantity, tax, unit, pos.Price, total, safeName, itemAgentTags(pos.Agent), industryTags(1260, pos.Industry), markTags)
+	_, err = d.sendCommand(cmd)
 	return err
 }
 
@@ -123,25 +288,69 @@ func (d *mitsuDriver) Payment(pay PaymentInfo) error {
 
 // CloseCheck закрывает чек.
 func (d *mitsuDriver) CloseCheck() error {
-	// Завершаем формирование чека
-	_, err := d.sendCommand("<Do CHECK='END'/>")
-	if err != nil {
-		return err
+	_, err := d.CloseCheckWithOptions(CloseCheckOptions{})
+	return err
+}
+
+// CloseCheckWithOptions закрывает чек с передачей контакта покупателя (T1008)
+// и, при необходимости, без печати (электронный чек).
+// Перед закрытием проверяются правила электронного чека по данным регистрации.
+func (d *mitsuDriver) CloseCheckWithOptions(opts CloseCheckOptions) (*CheckResult, error) {
+	contact := ""
+	if opts.CustomerContact != "" || opts.NoPrint {
+		reg, err := d.checkRegData()
+		if err != nil {
+			return nil, err
+		}
+		contact, err = CheckElectronicReceipt(reg, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Завершаем формирование чека (с контактом покупателя, если задан)
+	endCmd := "<Do CHECK='END'/>"
+	if contact != "" {
+		endCmd = fmt.Sprintf("<Do CHECK='END'><T1008>%s</T1008></Do>", escapeXMLText(contact))
+	}
+	if _, err := d.sendCommand(endCmd); err != nil {
+		return nil, err
 	}
 
-	// Закрываем чек и печатаем
-	_, err = d.sendCommand("<Do CHECK='CLOSE'/>")
+	// Закрываем чек
+	resp, err := d.sendCommand("<Do CHECK='CLOSE'/>")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	d.resetCheckRegData()
+
+	// Чек уже записан в ФН: ответ разбирается без прерывания закрытия,
+	// чтобы не пропустить печать
+	var res CheckResult
+	if err := decodeXML(resp, &res); err != nil {
+		res = CheckResult{}
+		if d.config.Logger != nil {
+			d.config.Logger(fmt.Sprintf("Ошибка парсинга ответа закрытия чека: %v", err))
+		}
+	}
+	res.CustomerContact = contact
+
+	if opts.NoPrint {
+		return &res, nil
 	}
 
 	// Печатаем чек
-	_, err = d.sendCommand("<PRINT/>")
-	return err
+	if _, err := d.sendCommand("<PRINT/>"); err != nil {
+		return &res, fmt.Errorf("чек закрыт (ФД %d), но не напечатан: %w", res.FD, err)
+	}
+	res.Printed = true
+
+	return &res, nil
 }
 
 // CancelCheck отменяет чек.
 func (d *mitsuDriver) CancelCheck() error {
+	d.resetCheckRegData()
 	_, err := d.sendCommand("<Do CHECK='CANCEL'/>")
 	return err
 }
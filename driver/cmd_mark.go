@@ -0,0 +1,70 @@
+package driver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// MarkCheck передает код маркировки в ФН для проверки (ФФД 1.2).
+// quantity - количество предмета расчета (T1023), для дробного количества
+// рассчитывается по mark.Fraction, если не задано.
+// Команда: <Do MARK='CHECK' STATUS='T2003' QTY='количество' UNIT='T2108' [PART='числитель/знаменатель']>КМ в HEX</Do>
+// Ответ: <OK RESULT='T2106' TYPE='T2100' REASON='причина'/>
+func (d *mitsuDriver) MarkCheck(mark ItemMark, quantity float64) (*MarkCheckResult, error) {
+	if mark.Fraction != nil && quantity == 0 {
+		quantity = mark.Fraction.FractionQuantity()
+	}
+	if err := ValidateItemMark("1.2", &mark, quantity); err != nil {
+		return nil, err
+	}
+	part := ""
+	if f := mark.Fraction; f != nil {
+		part = fmt.Sprintf(" PART='%d/%d'", f.Numerator, f.Denominator)
+	}
+	code := strings.ToUpper(hex.EncodeToString([]byte(NormalizeMarkCode(mark.Code))))
+	cmd := fmt.Sprintf("<Do MARK='CHECK' STATUS='%d' QTY='%.3f' UNIT='%d'%s>%s</Do>", mark.Status, quantity, mark.Measure, part, code)
+	resp, err := d.sendCommand(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки кода маркировки в ФН: %w", err)
+	}
+	var r MarkCheckResult
+	if err := decodeXML(resp, &r); err != nil {
+		return nil, fmt.Errorf("ошибка разбора ответа MARK CHECK: %w", err)
+	}
+	return &r, nil
+}
+
+// MarkRequestOism запрашивает в ОИСМ статус кода маркировки, переданного MarkCheck.
+// Сервер ОИСМ задается SetOismSettings.
+// Команда: <Do MARK='ONLINE'/>
+// Ответ: <OK RESULT='T2106' TYPE='T2100' CODE='T2105' STATUS='T2109'/>
+func (d *mitsuDriver) MarkRequestOism() (*MarkCheckResult, error) {
+	resp, err := d.sendCommand("<Do MARK='ONLINE'/>")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса статуса кода маркировки в ОИСМ: %w", err)
+	}
+	var r MarkCheckResult
+	if err := decodeXML(resp, &r); err != nil {
+		return nil, fmt.Errorf("ошибка разбора ответа MARK ONLINE: %w", err)
+	}
+	return &r, nil
+}
+
+// MarkAccept принимает проверенный код маркировки для включения в чек.
+// Команда: <Do MARK='ACCEPT'/>
+func (d *mitsuDriver) MarkAccept() error {
+	if _, err := d.sendCommand("<Do MARK='ACCEPT'/>"); err != nil {
+		return fmt.Errorf("ошибка принятия кода маркировки: %w", err)
+	}
+	return nil
+}
+
+// MarkReject отклоняет проверенный код маркировки.
+// Команда: <Do MARK='REJECT'/>
+func (d *mitsuDriver) MarkReject() error {
+	if _, err := d.sendCommand("<Do MARK='REJECT'/>"); err != nil {
+		return fmt.Errorf("ошибка отклонения кода маркировки: %w", err)
+	}
+	return nil
+}
@@ -97,14 +97,20 @@ func (d *mitsuDriver) OfdCancelRead() error {
 // OfdReadFullDocument читает полный документ для отправки в ОФД.
 // Возвращает бинарные данные документа (с обёрткой для ОФД).
 func (d *mitsuDriver) OfdReadFullDocument() ([]byte, error) {
+	return readFullMessage(d.OfdBeginRead, d.OfdReadBlock, d.OfdEndRead, d.OfdCancelRead)
+}
+
+// readFullMessage читает сообщение для сервера (ОФД или ОИСМ) блоками:
+// начало чтения, блоки по 1000 байт, завершение (отмена при ошибке).
+func readFullMessage(begin func() (int, error), read func(offset, length int) ([]byte, int, error), end, cancel func() error) ([]byte, error) {
 	// 1. Начинаем чтение, получаем размер
-	totalLength, err := d.OfdBeginRead()
+	totalLength, err := begin()
 	if err != nil {
 		return nil, err
 	}
 
 	if totalLength == 0 {
-		d.OfdEndRead()
+		end()
 		return nil, fmt.Errorf("документ пуст или отсутствует")
 	}
 
@@ -120,9 +126,9 @@ func (d *mitsuDriver) OfdReadFullDocument() ([]byte, error) {
 			chunkSize = remaining
 		}
 
-		data, actualLen, err := d.OfdReadBlock(offset, chunkSize)
+		data, actualLen, err := read(offset, chunkSize)
 		if err != nil {
-			d.OfdCancelRead() // Отменяем при ошибке
+			cancel() // Отменяем при ошибке
 			return nil, err
 		}
 
@@ -136,7 +142,7 @@ func (d *mitsuDriver) OfdReadFullDocument() ([]byte, error) {
 	}
 
 	// 3. Завершаем чтение
-	if err := d.OfdEndRead(); err != nil {
+	if err := end(); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,89 @@
+package driver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// OismBeginRead начинает чтение первого непереданного уведомления о реализации
+// маркированного товара для отправки в ОИСМ. Возвращает размер сообщения в байтах.
+// Команда: <Do OISM='BEGIN'/>
+// Ответ: <OK LENGTH='размер'/>
+func (d *mitsuDriver) OismBeginRead() (int, error) {
+	resp, err := d.sendCommand("<Do OISM='BEGIN'/>")
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала чтения уведомления ОИСМ: %w", err)
+	}
+
+	var r struct {
+		Length int `xml:"LENGTH,attr"`
+	}
+	if err := decodeXML(resp, &r); err != nil {
+		return 0, fmt.Errorf("ошибка разбора ответа OISM BEGIN: %w", err)
+	}
+	return r.Length, nil
+}
+
+// OismReadBlock считывает блок уведомления (не более 1000 байт).
+// Команда: <Do OISM='READ' OFFSET='позиция' LENGTH='размер'/>
+// Ответ: <OK LENGTH='размер'>БЛОК ДАННЫХ В HEX</OK>
+func (d *mitsuDriver) OismReadBlock(offset, length int) ([]byte, int, error) {
+	if length > 1000 {
+		length = 1000
+	}
+
+	cmd := fmt.Sprintf("<Do OISM='READ' OFFSET='%d' LENGTH='%d'/>", offset, length)
+	resp, err := d.sendCommand(cmd)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка чтения блока OISM offset=%d length=%d: %w", offset, length, err)
+	}
+
+	var r struct {
+		Length int    `xml:"LENGTH,attr"`
+		Data   string `xml:",innerxml"`
+	}
+	if err := decodeXML(resp, &r); err != nil {
+		return nil, 0, fmt.Errorf("ошибка разбора ответа OISM READ: %w", err)
+	}
+
+	data, err := hex.DecodeString(r.Data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка декодирования HEX данных OISM: %w", err)
+	}
+	return data, r.Length, nil
+}
+
+// OismEndRead завершает чтение уведомления.
+// Команда: <Do OISM='END'/>
+func (d *mitsuDriver) OismEndRead() error {
+	if _, err := d.sendCommand("<Do OISM='END'/>"); err != nil {
+		return fmt.Errorf("ошибка завершения чтения уведомления ОИСМ: %w", err)
+	}
+	return nil
+}
+
+// OismLoadReceipt записывает квитанцию ОИСМ на уведомление в ФН.
+// Команда: <Do OISM='LOAD' LENGTH='размер'>КВИТАНЦИЯ В HEX</Do>
+func (d *mitsuDriver) OismLoadReceipt(receipt []byte) error {
+	hexData := strings.ToUpper(hex.EncodeToString(receipt))
+	cmd := fmt.Sprintf("<Do OISM='LOAD' LENGTH='%d'>%s</Do>", len(receipt), hexData)
+	if _, err := d.sendCommand(cmd); err != nil {
+		return fmt.Errorf("ошибка записи квитанции ОИСМ: %w", err)
+	}
+	return nil
+}
+
+// OismCancelRead отменяет чтение уведомления.
+// Команда: <Do OISM='CANCEL'/>
+func (d *mitsuDriver) OismCancelRead() error {
+	if _, err := d.sendCommand("<Do OISM='CANCEL'/>"); err != nil {
+		return fmt.Errorf("ошибка отмены чтения уведомления ОИСМ: %w", err)
+	}
+	return nil
+}
+
+// OismReadFullNotice читает первое непереданное уведомление целиком.
+func (d *mitsuDriver) OismReadFullNotice() ([]byte, error) {
+	return readFullMessage(d.OismBeginRead, d.OismReadBlock, d.OismEndRead, d.OismCancelRead)
+}
@@ -34,6 +34,24 @@ func (d *mitsuDriver) Reregister(req RegistrationRequest, reasons []int) (*RegRe
 
 // performRegistration формирует XML команду <REG> и отправляет её.
 func (d *mitsuDriver) performRegistration(req RegistrationRequest) (*RegResponse, error) {
+	// Проверяем параметры до отправки, чтобы не получать невнятные коды ошибок ККТ.
+	// Контрольные цифры РНМ проверяются, только если удалось считать заводской номер.
+	// Если версия ФФД не указана, при перерегистрации берется текущая версия ККТ.
+	if !req.SkipValidation {
+		var opts RegValidationOptions
+		if _, serial, _, err := d.GetVersion(); err == nil {
+			opts.KKTSerial = serial
+		}
+		if req.IsReregistration && strings.TrimSpace(req.FfdVer) == "" {
+			if reg, err := d.GetRegistrationData(); err == nil {
+				opts.CurrentFfdVer = reg.FfdVer
+			}
+		}
+		if err := ValidateRegistration(req, opts).Err(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Сборка атрибутов
 	// Обязательные атрибуты согласно стр. 23
 	attrs := fmt.Sprintf("BASE='%s' T1062='%s'", req.Base, req.TaxSystems)
@@ -0,0 +1,267 @@
+package driver
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"mitsuscanner/internal/cliche"
+	"mitsuscanner/pkg/fndoc"
+)
+
+// CopyFormat определяет формат вывода копии документа из архива ФН.
+type CopyFormat int
+
+const (
+	CopyText CopyFormat = iota // Обычный текст (строки "Название: значение")
+	CopyHTML                   // HTML-страница
+)
+
+// docTypeNames - наименования документов по коду формы ФД.
+var docTypeNames = map[int]string{
+	DocTypeRegistration:      "Отчет о регистрации",
+	DocTypeShiftOpen:         "Отчет об открытии смены",
+	DocTypeReceipt:           "Кассовый чек",
+	DocTypeBSO:               "Бланк строгой отчетности",
+	DocTypeShiftClose:        "Отчет о закрытии смены",
+	DocTypeFnClose:           "Отчет о закрытии ФН",
+	DocTypeOperatorConfirm:   "Подтверждение оператора",
+	DocTypeReregistration:    "Отчет об изменении параметров регистрации",
+	DocTypeCurrentState:      "Отчет о текущем состоянии расчетов",
+	DocTypeCorrectionReceipt: "Кассовый чек коррекции",
+	DocTypeCorrectionBSO:     "БСО коррекции",
+}
+
+// tagNames - наименования реквизитов, выводимых в копии документа.
+// Реквизиты, отсутствующие в таблице, выводятся как "Txxxx".
+var tagNames = map[int]string{
+	1008: "Телефон или email покупателя",
+	1009: "Адрес расчетов",
+	1012: "Дата, время",
+	1013: "ЗН ККТ",
+	1018: "ИНН пользователя",
+	1020: "Сумма расчета",
+	1021: "Кассир",
+	1023: "Количество",
+	1030: "Наименование предмета расчета",
+	1031: "Наличными",
+	1037: "РН ККТ",
+	1038: "Номер смены",
+	1040: "Номер ФД",
+	1041: "Номер ФН",
+	1042: "Номер чека за смену",
+	1043: "Стоимость предмета расчета",
+	1046: "Наименование ОФД",
+	1048: "Наименование пользователя",
+	1054: "Признак расчета",
+	1055: "Применяемая СНО",
+	1059: "Предмет расчета",
+	1060: "Адрес сайта ФНС",
+	1077: "ФП",
+	1079: "Цена за единицу",
+	1081: "Безналичными",
+	1097: "Количество непереданных ФД",
+	1098: "Дата первого непереданного ФД",
+	1102: "Сумма НДС 20%",
+	1103: "Сумма НДС 10%",
+	1104: "Сумма расчета по ставке 0%",
+	1105: "Сумма расчета без НДС",
+	1116: "Номер первого непереданного ФД",
+	1117: "Адрес эл. почты отправителя чека",
+	1187: "Место расчетов",
+	1199: "Ставка НДС",
+	1203: "ИНН кассира",
+	1209: "Версия ФФД",
+	1212: "Признак предмета расчета",
+	1214: "Признак способа расчета",
+	1215: "Предоплатой (зачет аванса)",
+	1216: "Постоплатой (кредит)",
+	1217: "Встречным предоставлением",
+}
+
+// ArchivedDocument - документ из архива ФН в разобранном виде.
+type ArchivedDocument struct {
+	FD    int
+	Form  int    // Код формы ФД (см. DocType*)
+	Title string // Наименование документа
+	root  *fndoc.Node
+}
+
+// ParseArchivedDocument разбирает XML документа, полученный GetDocumentXMLFromFN.
+func ParseArchivedDocument(fd int, xmlDoc string) (*ArchivedDocument, error) {
+	root, err := fndoc.ParseTree(xmlDoc)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора документа ФД %d: %w", fd, err)
+	}
+	form, _ := strconv.Atoi(root.Attrs["FORM"])
+	return &ArchivedDocument{FD: fd, Form: form, Title: DocTypeName(form), root: root}, nil
+}
+
+// DocTypeName возвращает наименование документа по коду формы ФД.
+func DocTypeName(form int) string {
+	if name, ok := docTypeNames[form]; ok {
+		return name
+	}
+	return fmt.Sprintf("Документ (форма %d)", form)
+}
+
+// copyLine - строка копии документа с уровнем вложенности.
+type copyLine struct {
+	Level int
+	Label string
+	Value string
+}
+
+// lines возвращает реквизиты документа в порядке следования.
+func (doc *ArchivedDocument) lines() []copyLine {
+	var res []copyLine
+	var walk func(n *fndoc.Node, level int)
+	walk = func(n *fndoc.Node, level int) {
+		for _, c := range n.Children {
+			label := tagLabel(c)
+			if len(c.Children) > 0 {
+				res = append(res, copyLine{Level: level, Label: label})
+				walk(c, level+1)
+				continue
+			}
+			res = append(res, copyLine{Level: level, Label: label, Value: tagValue(c)})
+		}
+	}
+	walk(doc.root, 0)
+	return res
+}
+
+// tagLabel возвращает наименование реквизита.
+func tagLabel(n *fndoc.Node) string {
+	if label, ok := tagNames[n.Tag]; ok {
+		return label
+	}
+	return n.Name
+}
+
+// tagValue форматирует значение реквизита для вывода.
+func tagValue(n *fndoc.Node) string {
+	if n.Tag == 1012 {
+		if t, err := fndoc.ParseDateTime(n.Value); err == nil {
+			return t.Format("02.01.2006 15:04")
+		}
+	}
+	return n.Value
+}
+
+// Text возвращает копию документа в виде текста.
+func (doc *ArchivedDocument) Text() string {
+	var sb strings.Builder
+	sb.WriteString("КОПИЯ\n")
+	fmt.Fprintf(&sb, "%s\n", doc.Title)
+	fmt.Fprintf(&sb, "ФД: %d\n", doc.FD)
+	for _, l := range doc.lines() {
+		sb.WriteString(strings.Repeat("  ", l.Level))
+		if l.Value == "" {
+			fmt.Fprintf(&sb, "%s:\n", l.Label)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", l.Label, l.Value)
+	}
+	return sb.String()
+}
+
+// HTML возвращает копию документа в виде HTML-страницы.
+func (doc *ArchivedDocument) HTML() string {
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">")
+	fmt.Fprintf(&sb, "<title>%s ФД %d</title></head><body>\n", html.EscapeString(doc.Title), doc.FD)
+	fmt.Fprintf(&sb, "<h3>КОПИЯ</h3>\n<h2>%s</h2>\n<p>ФД: %d</p>\n<table>\n", html.EscapeString(doc.Title), doc.FD)
+	for _, l := range doc.lines() {
+		pad := l.Level * 16
+		if l.Value == "" {
+			fmt.Fprintf(&sb, "<tr><th colspan=\"2\" style=\"text-align:left;padding-left:%dpx\">%s</th></tr>\n", pad, html.EscapeString(l.Label))
+			continue
+		}
+		fmt.Fprintf(&sb, "<tr><td style=\"padding-left:%dpx\">%s</td><td>%s</td></tr>\n", pad, html.EscapeString(l.Label), html.EscapeString(l.Value))
+	}
+	sb.WriteString("</table>\n</body></html>\n")
+	return sb.String()
+}
+
+// Render возвращает копию документа в указанном формате.
+func (doc *ArchivedDocument) Render(format CopyFormat) (string, error) {
+	switch format {
+	case CopyText:
+		return doc.Text(), nil
+	case CopyHTML:
+		return doc.HTML(), nil
+	default:
+		return "", fmt.Errorf("неизвестный формат копии: %d", format)
+	}
+}
+
+// NonFiscal собирает копию документа для печати на принтере ККТ.
+// fnSerial - номер ФН для QR-кода чека, если в документе нет T1041.
+func (doc *ArchivedDocument) NonFiscal(fnSerial string) *NonFiscalDocument {
+	center := cliche.Props{Align: 1}
+	nf := NewNonFiscalDocument().
+		Text("КОПИЯ", cliche.Props{Align: 1, Width: 2, Height: 2}).
+		Text(doc.Title, center).
+		Text(fmt.Sprintf("ФД: %d", doc.FD), center)
+	for _, l := range doc.lines() {
+		text := strings.Repeat(" ", l.Level*2) + l.Label
+		if l.Value != "" {
+			text += ": " + l.Value
+		}
+		nf.Text(truncateRunes(text, maxNonFiscalText), cliche.Props{})
+	}
+	// Для чеков добавляем QR-код проверки, если в документе достаточно данных
+	if parsed, err := fndoc.FromTree(doc.root); err == nil {
+		if receipt, ok := parsed.(*fndoc.Receipt); ok {
+			if q, err := fndoc.ReceiptQRFromDocument(receipt, fnSerial); err == nil {
+				nf.QR(q.String(), 0, 1)
+			}
+		}
+	}
+	return nf
+}
+
+// truncateRunes обрезает строку до n символов.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// GetDocumentCopy читает документ из архива ФН и возвращает его копию в указанном формате.
+func (d *mitsuDriver) GetDocumentCopy(fd int, format CopyFormat) (string, error) {
+	doc, err := d.readArchivedDocument(fd)
+	if err != nil {
+		return "", err
+	}
+	return doc.Render(format)
+}
+
+// PrintDocumentCopy печатает копию документа из архива ФН по номеру FD.
+func (d *mitsuDriver) PrintDocumentCopy(fd int) error {
+	doc, err := d.readArchivedDocument(fd)
+	if err != nil {
+		return err
+	}
+	// Номер ФН для QR-кода чеков без T1041 (как в CheckResult.ReceiptQR)
+	fn, err := d.GetFnStatus()
+	if err != nil {
+		return fmt.Errorf("ошибка получения статуса ФН: %w", err)
+	}
+	if err := d.PrintNonFiscal(doc.NonFiscal(fn.Serial)); err != nil {
+		return fmt.Errorf("ошибка печати копии ФД %d: %w", fd, err)
+	}
+	return nil
+}
+
+func (d *mitsuDriver) readArchivedDocument(fd int) (*ArchivedDocument, error) {
+	xmlDoc, err := d.GetDocumentXMLFromFN(fd)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ФД %d из архива ФН: %w", fd, err)
+	}
+	return ParseArchivedDocument(fd, xmlDoc)
+}
@@ -0,0 +1,74 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleReceiptXML = `<?xml version="1.0" encoding="windows-1251"?>
+<DocXML FORM="3"><T1012>01-05-23T10:15</T1012><T1040>42</T1040>` +
+	`<T1059><T1030>Хлеб &amp; соль</T1030><T1079>50.00</T1079></T1059>` +
+	`<T1020>50.00</T1020><T9999>x</T9999></DocXML>`
+
+func TestParseArchivedDocument(t *testing.T) {
+	doc, err := ParseArchivedDocument(42, sampleReceiptXML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if doc.Form != DocTypeReceipt || doc.Title != "Кассовый чек" {
+		t.Fatalf("unexpected form/title: %d %q", doc.Form, doc.Title)
+	}
+
+	text := doc.Text()
+	for _, want := range []string{
+		"КОПИЯ\nКассовый чек\nФД: 42\n",
+		"Дата, время: 01.05.2023 10:15\n",
+		"Предмет расчета:\n  Наименование предмета расчета: Хлеб & соль\n",
+		"T9999: x\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text copy missing %q:\n%s", want, text)
+		}
+	}
+
+	htmlCopy, err := doc.Render(CopyHTML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(htmlCopy, "Хлеб &amp; соль") {
+		t.Errorf("html copy is not escaped:\n%s", htmlCopy)
+	}
+
+	nf := doc.NonFiscal("")
+	if err := nf.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cmds := nf.Commands(); len(cmds) != 3+7 {
+		t.Fatalf("unexpected number of print lines: %d", len(cmds))
+	}
+}
+
+func TestArchivedReceiptCopyQR(t *testing.T) {
+	// Чек без номера ФН (T1041): номер берется из статуса ФН
+	doc, err := ParseArchivedDocument(42, `<DocXML FORM="3"><T1012>01-05-23T10:15</T1012><T1040>42</T1040>`+
+		`<T1054>1</T1054><T1020>50.00</T1020><T1077>1234567890</T1077></DocXML>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmds := doc.NonFiscal("").Commands(); strings.Contains(strings.Join(cmds, ""), "fn=") {
+		t.Fatalf("QR without FN serial: %v", cmds)
+	}
+	cmds := doc.NonFiscal("9999078900001234").Commands()
+	if last := cmds[len(cmds)-1]; !strings.Contains(last, "fn=9999078900001234") || !strings.Contains(last, "i=42") {
+		t.Fatalf("unexpected QR command: %s", last)
+	}
+}
+
+func TestParseArchivedDocumentErrors(t *testing.T) {
+	if _, err := ParseArchivedDocument(1, ""); err == nil {
+		t.Fatal("expected error for empty document")
+	}
+	if _, err := ParseArchivedDocument(1, "<DocXML><T1012>"); err == nil {
+		t.Fatal("expected error for broken document")
+	}
+}
@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mitsuscanner/pkg/fndoc"
+)
+
+// FnWarnings - флаги предупреждений ФН (атрибут FLAG в <GET INFO='F'/>).
+type FnWarnings uint8
+
+const (
+	FnWarnUrgentReplace FnWarnings = 1 << iota // Срочная замена КС (до окончания срока действия 3 дня)
+	FnWarnResourceLow                          // Исчерпание ресурса КС (до окончания срока действия 30 дней)
+	FnWarnMemoryFull                           // Переполнение памяти ФН (архив заполнен на 99%)
+	FnWarnOfdTimeout                           // Превышено время ожидания ответа ОФД
+	FnWarnFlcFailure                           // Отказ по данным форматно-логического контроля
+	FnWarnSetupRequired                        // Требуется настройка ККТ
+	FnWarnOfdCancelled                         // ОФД аннулирован
+	FnWarnCriticalError                        // Критическая ошибка ФН
+)
+
+var fnWarningNames = []string{
+	"срочная замена ФН (до окончания срока 3 дня)",
+	"исчерпание ресурса ФН (до окончания срока 30 дней)",
+	"память ФН заполнена на 99%",
+	"превышено время ожидания ответа ОФД",
+	"отказ по данным форматно-логического контроля",
+	"требуется настройка ККТ",
+	"ОФД аннулирован",
+	"критическая ошибка ФН",
+}
+
+// ParseFnWarnings разбирает HEX маску предупреждений ФН ("0x05", "05", "").
+func ParseFnWarnings(flag string) (FnWarnings, error) {
+	flag = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(flag)), "0x")
+	if flag == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(flag, 16, 8)
+	if err != nil {
+		return 0, fmt.Errorf("некорректная маска предупреждений ФН: %s", flag)
+	}
+	return FnWarnings(v), nil
+}
+
+// Has возвращает true, если установлены все указанные флаги.
+func (w FnWarnings) Has(flags FnWarnings) bool {
+	return w&flags == flags
+}
+
+// Critical возвращает true, если ФН требует немедленного вмешательства
+// (срочная замена, заполнение памяти или критическая ошибка).
+func (w FnWarnings) Critical() bool {
+	return w&(FnWarnUrgentReplace|FnWarnMemoryFull|FnWarnCriticalError) != 0
+}
+
+// Messages возвращает описания установленных флагов.
+func (w FnWarnings) Messages() []string {
+	var res []string
+	for i, name := range fnWarningNames {
+		if w&(1<<i) != 0 {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+func (w FnWarnings) String() string {
+	if w == 0 {
+		return "нет предупреждений"
+	}
+	return strings.Join(w.Messages(), "; ")
+}
+
+// Warnings возвращает расшифрованные флаги предупреждений ФН.
+func (s *FnStatus) Warnings() (FnWarnings, error) {
+	return ParseFnWarnings(s.Flag)
+}
+
+// ValidUntil возвращает дату окончания срока действия ФН (атрибут VALID).
+func (s *FnStatus) ValidUntil() (time.Time, error) {
+	t, err := fndoc.ParseDateTime(strings.TrimSpace(s.Valid))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("некорректный срок действия ФН: %s", s.Valid)
+	}
+	return t, nil
+}
+
+// Фазы жизни ФН (атрибут PHASE в <GET INFO='F'/>).
+const (
+	FnPhaseReady   = 0x01 // Готовность к фискализации
+	FnPhaseFiscal  = 0x03 // Фискальный режим
+	FnPhaseClosed  = 0x07 // Постфискальный режим (архив закрыт)
+	FnPhaseArchive = 0x0F // Чтение данных из архива ФН
+)
+
+// PhaseCode возвращает фазу жизни ФН ("0x03", "3").
+func (s *FnStatus) PhaseCode() (int, error) {
+	phase := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s.Phase)), "0x")
+	v, err := strconv.ParseInt(phase, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("некорректная фаза ФН: %s", s.Phase)
+	}
+	return int(v), nil
+}
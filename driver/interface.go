@@ -32,6 +32,8 @@ type Driver interface {
 	GetOptions() (*DeviceOptions, error)
 	GetCurrentDocumentType() (int, error)
 	GetDocumentXMLFromFN(fd int) (string, error)
+	// GetDocumentCopy возвращает копию документа из архива ФН в виде текста или HTML.
+	GetDocumentCopy(fd int, format CopyFormat) (string, error)
 
 	SetPowerFlag(value int) error
 	SetDateTime(t time.Time) error
@@ -57,13 +59,28 @@ type Driver interface {
 	CloseShift(operator string) error
 	PrintXReport() error
 	PrintZReport() error
+	ReportCurrentState() (*CurrentStateReport, error)
+	// CashIn/CashOut выполняют внесение и изъятие наличных.
+	CashIn(amount float64, operator string, print bool) error
+	CashOut(amount float64, operator string, print bool) error
 	OpenCheck(checkType int, taxSystem int) error
+	// OpenCheckWithOptions открывает чек с реквизитами уровня чека (агент и т.п.).
+	OpenCheckWithOptions(checkType int, taxSystem int, opts OpenCheckOptions) error
 	AddPosition(pos ItemPosition) error
 	Subtotal() error
 	Payment(pay PaymentInfo) error
 	CloseCheck() error
+	// CloseCheckWithOptions закрывает чек с контактом покупателя (T1008) и/или без печати.
+	CloseCheckWithOptions(opts CloseCheckOptions) (*CheckResult, error)
 	CancelCheck() error
 	OpenCorrectionCheck(checkType int, taxSystem int) error
+
+	// Коды маркировки (ФФД 1.2): проверка в ФН, запрос в ОИСМ, принятие/отклонение.
+	MarkCheck(mark ItemMark, quantity float64) (*MarkCheckResult, error)
+	MarkRequestOism() (*MarkCheckResult, error)
+	MarkAccept() error
+	MarkReject() error
+
 	RebootDevice() error
 	PrintDiagnostics() error
 	DeviceJob(job int) error
@@ -73,6 +90,10 @@ type Driver interface {
 	Feed(lines int) error
 	Cut() error
 	PrintLastDocument() error
+	// PrintDocumentCopy печатает копию любого документа из архива ФН по номеру FD.
+	PrintDocumentCopy(fd int) error
+	// PrintNonFiscal печатает нефискальный документ (текст, штрихкоды, QR, картинки).
+	PrintNonFiscal(doc *NonFiscalDocument) error
 
 	// UploadImage загружает изображение в память ККТ.
 	// index: 0 - логотип, 1-20 - пользовательские картинки.
@@ -86,6 +107,14 @@ type Driver interface {
 	OfdLoadReceipt(receipt []byte) error
 	OfdCancelRead() error
 	OfdReadFullDocument() ([]byte, error)
+
+	// Уведомления о реализации маркированных товаров (обмен с ОИСМ)
+	OismBeginRead() (int, error)
+	OismReadBlock(offset, length int) ([]byte, int, error)
+	OismEndRead() error
+	OismLoadReceipt(receipt []byte) error
+	OismCancelRead() error
+	OismReadFullNotice() ([]byte, error)
 }
 
 // ActiveDriver - глобально активный драйвер
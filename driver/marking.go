@@ -0,0 +1,199 @@
+package driver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Биты результата проверки сведений о товаре (T2106).
+const (
+	markFnChecked   = 1 << 0 // КМ проверен ФН
+	markFnValid     = 1 << 1 // Результат проверки КП КМ фискальным накопителем положительный
+	markOismChecked = 1 << 2 // Проверка статуса ОИСМ выполнена
+	markOismValid   = 1 << 3 // Результат проверки статуса ОИСМ положительный
+)
+
+// Ответ ОИСМ о статусе товара (T2109).
+const (
+	OismItemStatusCorrect   = 1 // Планируемый статус товара корректен
+	OismItemStatusIncorrect = 2 // Планируемый статус товара некорректен
+	OismItemStatusSuspended = 3 // Оборот товара приостановлен
+)
+
+// FnChecked возвращает true, если КМ проверен ФН.
+func (r *MarkCheckResult) FnChecked() bool { return r.Result&markFnChecked != 0 }
+
+// FnValid возвращает true, если проверка КМ фискальным накопителем положительна.
+func (r *MarkCheckResult) FnValid() bool { return r.Result&markFnValid != 0 }
+
+// OismChecked возвращает true, если получен ответ ОИСМ.
+func (r *MarkCheckResult) OismChecked() bool { return r.Result&markOismChecked != 0 }
+
+// OismValid возвращает true, если ответ ОИСМ положительный.
+func (r *MarkCheckResult) OismValid() bool { return r.Result&markOismValid != 0 }
+
+// Problems возвращает отрицательные результаты проверки (пустой - КМ можно принять).
+// Непроверенный КМ (ФН без ключа проверки, ОИСМ недоступен) ошибкой не считается.
+func (r *MarkCheckResult) Problems() []string {
+	var res []string
+	if r.FnChecked() && !r.FnValid() {
+		res = append(res, "код маркировки не прошел проверку в ФН")
+	}
+	if r.OismChecked() && !r.OismValid() {
+		res = append(res, "отрицательный результат проверки в ОИСМ")
+	}
+	switch r.ItemStatus {
+	case OismItemStatusIncorrect:
+		res = append(res, "планируемый статус товара некорректен (T2109)")
+	case OismItemStatusSuspended:
+		res = append(res, "оборот товара приостановлен (T2109)")
+	}
+	return res
+}
+
+// NormalizeMarkCode приводит КМ, считанный сканером, к виду для передачи в ФН:
+// убирает пробелы и переводы строк по краям и заменяет принятые у сканеров
+// обозначения разделителя групп (<GS>, {GS}, \u001d) на символ GS (0x1D).
+func NormalizeMarkCode(code string) string {
+	code = strings.Trim(code, " \t\r\n")
+	return strings.NewReplacer("<GS>", "\x1d", "{GS}", "\x1d", `\u001d`, "\x1d", `\x1d`, "\x1d").Replace(code)
+}
+
+// GS1Mark - разобранный КМ в формате GS1 DataMatrix.
+type GS1Mark struct {
+	GTIN   string // (01) Код товара, 14 цифр
+	Serial string // (21) Индивидуальный серийный номер
+}
+
+// ParseGS1Mark разбирает КМ в формате GS1 DataMatrix (01 GTIN 21 серийный номер ...).
+// Серийный номер заканчивается разделителем GS; если сканер его не передал,
+// берется не более 13 символов.
+func ParseGS1Mark(code string) (GS1Mark, error) {
+	code = NormalizeMarkCode(code)
+	if len(code) < 19 || !strings.HasPrefix(code, "01") || !isDigits(code[2:16]) || code[16:18] != "21" {
+		return GS1Mark{}, fmt.Errorf("код маркировки не в формате GS1 DataMatrix")
+	}
+	serial := code[18:]
+	if i := strings.IndexByte(serial, 0x1d); i >= 0 {
+		serial = serial[:i]
+	} else if len(serial) > 13 {
+		serial = serial[:13]
+	}
+	if serial == "" {
+		return GS1Mark{}, fmt.Errorf("в коде маркировки нет серийного номера")
+	}
+	return GS1Mark{GTIN: code[2:16], Serial: serial}, nil
+}
+
+// ProductCode1162 формирует код товара (T1162, ФФД 1.05) из КМ в формате
+// GS1 DataMatrix: тип кода 0x444D, GTIN (6 байт) и серийный номер. Результат в HEX.
+func ProductCode1162(code string) (string, error) {
+	m, err := ParseGS1Mark(code)
+	if err != nil {
+		return "", err
+	}
+	gtin, _ := strconv.ParseUint(m.GTIN, 10, 64)
+	buf := []byte{0x44, 0x4D}
+	for shift := 40; shift >= 0; shift -= 8 {
+		buf = append(buf, byte(gtin>>uint(shift)))
+	}
+	buf = append(buf, m.Serial...)
+	if len(buf) > 32 {
+		return "", fmt.Errorf("код товара (T1162) длиннее 32 байт")
+	}
+	return strings.ToUpper(hex.EncodeToString(buf)), nil
+}
+
+// ValidateItemMark проверяет код маркировки предмета расчета.
+// В ФФД 1.2 КМ передается в T2000 вместе с планируемым статусом (T2003), в
+// ФФД 1.05 - кодом товара (T1162) без статуса и дробного количества.
+// quantity - количество предмета расчета (T1023).
+func ValidateItemMark(ffdVer string, m *ItemMark, quantity float64) error {
+	if m == nil {
+		return nil
+	}
+	code := NormalizeMarkCode(m.Code)
+	if code == "" {
+		return fmt.Errorf("не задан код маркировки")
+	}
+	if len(code) > 256 {
+		return fmt.Errorf("код маркировки (T2000) длиннее 256 символов")
+	}
+	for _, c := range []byte(code) {
+		if (c < 0x20 && c != 0x1d) || c > 0x7e {
+			return fmt.Errorf("код маркировки содержит недопустимый символ 0x%02X", c)
+		}
+	}
+
+	if !IsFFD12(ffdVer) {
+		if m.Fraction != nil {
+			return fmt.Errorf("дробное количество маркированного товара (T1291) поддерживается только в ФФД 1.2")
+		}
+		_, err := ProductCode1162(code)
+		return err
+	}
+
+	switch m.Status {
+	case MarkStatusPieceSold, MarkStatusPieceReturned:
+		if m.Fraction != nil {
+			return fmt.Errorf("дробное количество (T1291) не применяется к штучному товару (T2003=%d)", m.Status)
+		}
+		if quantity != 1 {
+			return fmt.Errorf("количество штучного маркированного товара должно быть 1: %v", quantity)
+		}
+	case MarkStatusMeasuredSold, MarkStatusMeasuredReturned, MarkStatusUnchanged:
+	default:
+		return fmt.Errorf("некорректный планируемый статус товара (T2003): %d", m.Status)
+	}
+	if m.Measure < 0 || m.Measure > 255 {
+		return fmt.Errorf("некорректная мера количества (T2108): %d", m.Measure)
+	}
+	if f := m.Fraction; f != nil {
+		if f.Numerator <= 0 || f.Denominator <= 0 || f.Numerator >= f.Denominator {
+			return fmt.Errorf("некорректное дробное количество (T1291): %d/%d", f.Numerator, f.Denominator)
+		}
+		if m.Measure != 0 {
+			return fmt.Errorf("дробное количество (T1291) допускается только для меры количества \"штуки\" (T2108=0)")
+		}
+	}
+	if quantity <= 0 {
+		return fmt.Errorf("количество маркированного товара должно быть больше 0")
+	}
+	return nil
+}
+
+// FractionQuantity возвращает количество (T1023), соответствующее дробному количеству.
+func (f MarkFraction) FractionQuantity() float64 {
+	if f.Denominator == 0 {
+		return 0
+	}
+	return float64(f.Numerator) / float64(f.Denominator)
+}
+
+// itemMarkTags формирует теги кода маркировки предмета расчета. КМ передается в HEX.
+func itemMarkTags(ffdVer string, m *ItemMark) (string, error) {
+	if m == nil {
+		return "", nil
+	}
+	code := NormalizeMarkCode(m.Code)
+	var sb strings.Builder
+	if !IsFFD12(ffdVer) {
+		pc, err := ProductCode1162(code)
+		if err != nil {
+			return "", err
+		}
+		writeTag(&sb, 1162, pc)
+		return sb.String(), nil
+	}
+	writeTag(&sb, 2000, strings.ToUpper(hex.EncodeToString([]byte(code))))
+	writeTag(&sb, 2003, strconv.Itoa(int(m.Status)))
+	if f := m.Fraction; f != nil {
+		var inner strings.Builder
+		writeTag(&inner, 1293, strconv.Itoa(f.Numerator))
+		writeTag(&inner, 1294, strconv.Itoa(f.Denominator))
+		sb.WriteString("<T1291>" + inner.String() + "</T1291>")
+	}
+	return sb.String(), nil
+}
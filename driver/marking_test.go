@@ -0,0 +1,93 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMarkCode = "0104601234567890215abcDEF123456\x1d91EE07\x1d92abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQR="
+
+func TestParseGS1Mark(t *testing.T) {
+	m, err := ParseGS1Mark(strings.ReplaceAll(testMarkCode, "\x1d", "<GS>") + "\r\n")
+	if err != nil {
+		t.Fatalf("ParseGS1Mark: %v", err)
+	}
+	if m.GTIN != "04601234567890" || m.Serial != "5abcDEF123456" {
+		t.Errorf("unexpected mark: %+v", m)
+	}
+	if _, err := ParseGS1Mark("RU-401301-AAA0277031"); err == nil {
+		t.Error("expected error for non-GS1 code")
+	}
+
+	pc, err := ProductCode1162(testMarkCode)
+	if err != nil {
+		t.Fatalf("ProductCode1162: %v", err)
+	}
+	// 444D + GTIN 4601234567890 (0x042F4EF3B2D2) + серийный номер в ASCII
+	if want := "444D042F4EF3B2D2" + strings.ToUpper("35616263444546313233343536"); pc != want {
+		t.Errorf("T1162 = %s, want %s", pc, want)
+	}
+}
+
+func TestValidateItemMark(t *testing.T) {
+	piece := &ItemMark{Code: testMarkCode, Status: MarkStatusPieceSold}
+	if err := ValidateItemMark("1.2", piece, 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateItemMark("1.2", piece, 2); err == nil {
+		t.Error("expected error for piece quantity != 1")
+	}
+	if err := ValidateItemMark("1.2", &ItemMark{Code: testMarkCode}, 1); err == nil {
+		t.Error("expected error for missing T2003")
+	}
+
+	fraction := &ItemMark{Code: testMarkCode, Status: MarkStatusMeasuredSold, Fraction: &MarkFraction{Numerator: 1, Denominator: 3}}
+	if err := ValidateItemMark("4", fraction, fraction.Fraction.FractionQuantity()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateItemMark("1.05", fraction, 1); err == nil {
+		t.Error("expected error for fraction in FFD 1.05")
+	}
+	bad := *fraction
+	bad.Fraction = &MarkFraction{Numerator: 3, Denominator: 3}
+	if err := ValidateItemMark("1.2", &bad, 1); err == nil {
+		t.Error("expected error for numerator >= denominator")
+	}
+	if err := ValidateItemMark("1.2", &ItemMark{Code: "код", Status: MarkStatusPieceSold}, 1); err == nil {
+		t.Error("expected error for non-ASCII code")
+	}
+}
+
+func TestItemMarkTags(t *testing.T) {
+	mark := &ItemMark{Code: "0104601234567890215abcDEF123456", Status: MarkStatusMeasuredSold, Fraction: &MarkFraction{Numerator: 1, Denominator: 2}}
+	got, err := itemMarkTags("1.2", mark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<T2000>30313034363031323334353637383930323135616263444546313233343536</T2000><T2003>2</T2003><T1291><T1293>1</T1293><T1294>2</T1294></T1291>"
+	if got != want {
+		t.Errorf("FFD 1.2 tags:\n%s\nwant\n%s", got, want)
+	}
+	if got, err := itemMarkTags("1.05", &ItemMark{Code: mark.Code}); err != nil || !strings.HasPrefix(got, "<T1162>444D") {
+		t.Errorf("FFD 1.05 tags: %s (%v)", got, err)
+	}
+	// Без версии ФФД КМ не в формате GS1 не должен давать пустой T1162
+	if got, err := itemMarkTags("", &ItemMark{Code: "not-a-gs1-code"}); err == nil {
+		t.Errorf("expected error for empty FFD and non-GS1 code, got %s", got)
+	}
+}
+
+func TestMarkCheckResultProblems(t *testing.T) {
+	ok := MarkCheckResult{Result: 0x0F, ItemStatus: OismItemStatusCorrect}
+	if p := ok.Problems(); len(p) != 0 {
+		t.Errorf("unexpected problems: %v", p)
+	}
+	unchecked := MarkCheckResult{Reason: 2}
+	if p := unchecked.Problems(); len(p) != 0 {
+		t.Errorf("unchecked code must not be a problem: %v", p)
+	}
+	bad := MarkCheckResult{Result: 0x05, ItemStatus: OismItemStatusIncorrect}
+	if p := bad.Problems(); len(p) != 3 {
+		t.Errorf("expected 3 problems, got %v", p)
+	}
+}
@@ -37,8 +37,15 @@ type mitsuDriver struct {
 	config Config
 	mu     sync.Mutex
 	port   io.ReadWriteCloser // Используется только для COM.
+
+	// Данные регистрации, полученные для текущего чека (см. checkRegData)
+	checkRegMu sync.Mutex
+	checkReg   *RegData
 }
 
+// NewMitsuDriver создает драйвер ККТ Mitsu. Операции высокого риска
+// (технологическое обнуление, закрытие архива ФН и т.п.) не ограничиваются;
+// для кассовых приложений используйте NewCashierDriver (см. PolicyDriver).
 func NewMitsuDriver(config Config) Driver {
 	if config.Timeout == 0 {
 		config.Timeout = 3000
@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"mitsuscanner/internal/cliche"
+)
+
+// BarcodeType определяет тип одномерного штрихкода.
+type BarcodeType int
+
+const (
+	BarcodeUPCA    BarcodeType = 0
+	BarcodeUPCE    BarcodeType = 1
+	BarcodeEAN13   BarcodeType = 2
+	BarcodeEAN8    BarcodeType = 3
+	BarcodeCode39  BarcodeType = 4
+	BarcodeITF     BarcodeType = 5
+	BarcodeCodabar BarcodeType = 6
+	BarcodeCode93  BarcodeType = 7
+	BarcodeCode128 BarcodeType = 8
+)
+
+const (
+	maxNonFiscalText = 256 // Макс. длина строки текста
+	maxQRData        = 512 // Макс. длина данных QR-кода
+	maxImageIndex    = 20  // Номер последнего пользовательского слота картинки
+)
+
+var (
+	reDigits    = regexp.MustCompile(`^\d+$`)
+	reCode39    = regexp.MustCompile(`^[0-9A-Z \-.$/+%]+$`)
+	reCodabar   = regexp.MustCompile(`^[A-Da-d][0-9\-$:/.+]+[A-Da-d]$`)
+	reASCIIData = regexp.MustCompile(`^[\x20-\x7E]+$`)
+)
+
+// NonFiscalDocument собирает нефискальный документ (текст, штрихкоды, QR, картинки)
+// для печати между чеками. Ошибки добавления элементов накапливаются и
+// возвращаются методом Err (и при печати).
+type NonFiscalDocument struct {
+	items []string
+	err   error
+}
+
+// NewNonFiscalDocument создает пустой нефискальный документ.
+func NewNonFiscalDocument() *NonFiscalDocument {
+	return &NonFiscalDocument{}
+}
+
+// Text добавляет строку текста с форматированием в формате клише (см. cliche.Props).
+func (doc *NonFiscalDocument) Text(text string, props cliche.Props) *NonFiscalDocument {
+	if utf8.RuneCountInString(text) > maxNonFiscalText {
+		return doc.fail(fmt.Errorf("строка длиннее %d символов", maxNonFiscalText))
+	}
+	doc.items = append(doc.items, fmt.Sprintf("<TEXT FORM='%s'>%s</TEXT>", cliche.BuildFormat(props), escapeXMLText(text)))
+	return doc
+}
+
+// Barcode добавляет одномерный штрихкод. hri - печатать ли текст под штрихкодом.
+func (doc *NonFiscalDocument) Barcode(kind BarcodeType, data string, hri bool) *NonFiscalDocument {
+	if err := validateBarcode(kind, data); err != nil {
+		return doc.fail(err)
+	}
+	doc.items = append(doc.items, fmt.Sprintf("<BARCODE TYPE='%d' HRI='%d'>%s</BARCODE>", kind, boolAttr(hri), escapeXMLText(data)))
+	return doc
+}
+
+// QR добавляет QR-код. size - размер модуля (1-8, 0 - по умолчанию), align - выравнивание (0-лево, 1-центр, 2-право).
+func (doc *NonFiscalDocument) QR(data string, size int, align int) *NonFiscalDocument {
+	if data == "" || len(data) > maxQRData {
+		return doc.fail(fmt.Errorf("данные QR-кода должны быть от 1 до %d байт", maxQRData))
+	}
+	if size < 0 || size > 8 {
+		return doc.fail(fmt.Errorf("некорректный размер QR-кода: %d", size))
+	}
+	if align < 0 || align > 2 {
+		return doc.fail(fmt.Errorf("некорректное выравнивание QR-кода: %d", align))
+	}
+	doc.items = append(doc.items, fmt.Sprintf("<QR SIZE='%d' ALIGN='%d'>%s</QR>", size, align, escapeXMLText(data)))
+	return doc
+}
+
+// Image добавляет картинку, ранее загруженную в память ККТ (см. UploadImage).
+// index: 0 - логотип, 1-20 - пользовательские картинки.
+func (doc *NonFiscalDocument) Image(index int) *NonFiscalDocument {
+	if index < 0 || index > maxImageIndex {
+		return doc.fail(fmt.Errorf("некорректный номер картинки: %d (допустимо 0-%d)", index, maxImageIndex))
+	}
+	doc.items = append(doc.items, fmt.Sprintf("<PIC N='%d'/>", index))
+	return doc
+}
+
+// Feed добавляет прогон бумаги на указанное количество строк.
+func (doc *NonFiscalDocument) Feed(lines int) *NonFiscalDocument {
+	if lines <= 0 {
+		return doc
+	}
+	doc.items = append(doc.items, fmt.Sprintf("<FEED N='%d'/>", lines))
+	return doc
+}
+
+// Err возвращает первую ошибку, возникшую при сборке документа.
+func (doc *NonFiscalDocument) Err() error {
+	return doc.err
+}
+
+// Commands возвращает список команд документа в порядке печати.
+func (doc *NonFiscalDocument) Commands() []string {
+	return append([]string(nil), doc.items...)
+}
+
+func (doc *NonFiscalDocument) fail(err error) *NonFiscalDocument {
+	if doc.err == nil {
+		doc.err = err
+	}
+	return doc
+}
+
+// PrintNonFiscal печатает нефискальный документ.
+// Документ открывается, заполняется командами построителя, закрывается и печатается с отрезкой.
+func (d *mitsuDriver) PrintNonFiscal(doc *NonFiscalDocument) error {
+	if doc == nil || len(doc.items) == 0 {
+		return fmt.Errorf("нефискальный документ пуст")
+	}
+	if doc.err != nil {
+		return fmt.Errorf("ошибка формирования нефискального документа: %w", doc.err)
+	}
+
+	if _, err := d.sendCommand("<Do DOC='OPEN'/>"); err != nil {
+		return fmt.Errorf("ошибка открытия нефискального документа: %w", err)
+	}
+
+	for i, cmd := range doc.items {
+		if _, err := d.sendCommand(cmd); err != nil {
+			d.sendCommand("<Do DOC='CANCEL'/>")
+			return fmt.Errorf("ошибка печати элемента %d: %w", i+1, err)
+		}
+	}
+
+	if _, err := d.sendCommand("<Do DOC='CLOSE'/>"); err != nil {
+		// Не оставляем ККТ с открытым нефискальным документом
+		d.sendCommand("<Do DOC='CANCEL'/>")
+		return fmt.Errorf("ошибка закрытия нефискального документа: %w", err)
+	}
+
+	_, err := d.sendCommand("<PRINT/>")
+	return err
+}
+
+// validateBarcode проверяет данные штрихкода на соответствие типу.
+func validateBarcode(kind BarcodeType, data string) error {
+	if data == "" {
+		return fmt.Errorf("данные штрихкода пусты")
+	}
+	digitsLen := func(lengths ...int) error {
+		if !reDigits.MatchString(data) {
+			return fmt.Errorf("штрихкод %d допускает только цифры: %q", kind, data)
+		}
+		for _, l := range lengths {
+			if len(data) == l {
+				return nil
+			}
+		}
+		return fmt.Errorf("неверная длина штрихкода %d: %d", kind, len(data))
+	}
+
+	switch kind {
+	case BarcodeUPCA:
+		return digitsLen(11, 12)
+	case BarcodeUPCE:
+		return digitsLen(6, 7, 8, 11, 12)
+	case BarcodeEAN13:
+		return digitsLen(12, 13)
+	case BarcodeEAN8:
+		return digitsLen(7, 8)
+	case BarcodeITF:
+		if !reDigits.MatchString(data) || len(data)%2 != 0 {
+			return fmt.Errorf("ITF допускает только четное количество цифр: %q", data)
+		}
+	case BarcodeCode39:
+		if !reCode39.MatchString(strings.ToUpper(data)) {
+			return fmt.Errorf("недопустимые символы для CODE39: %q", data)
+		}
+	case BarcodeCodabar:
+		if !reCodabar.MatchString(data) {
+			return fmt.Errorf("недопустимые данные для CODABAR: %q", data)
+		}
+	case BarcodeCode93, BarcodeCode128:
+		if !reASCIIData.MatchString(data) {
+			return fmt.Errorf("штрихкод %d допускает только ASCII символы: %q", kind, data)
+		}
+	default:
+		return fmt.Errorf("неизвестный тип штрихкода: %d", kind)
+	}
+	if len(data) > 255 {
+		return fmt.Errorf("данные штрихкода длиннее 255 символов")
+	}
+	return nil
+}
+
+func boolAttr(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
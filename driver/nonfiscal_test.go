@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"testing"
+
+	"mitsuscanner/internal/cliche"
+)
+
+func TestNonFiscalDocumentCommands(t *testing.T) {
+	doc := NewNonFiscalDocument().
+		Text("Заказ №15", cliche.Props{Width: 2, Height: 2, Align: 1}).
+		Barcode(BarcodeEAN13, "4601234567893", true).
+		QR("https://example.ru/o/15", 4, 1).
+		Image(3).
+		Feed(2)
+
+	if err := doc.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"<TEXT FORM='022001'>Заказ №15</TEXT>",
+		"<BARCODE TYPE='2' HRI='1'>4601234567893</BARCODE>",
+		"<QR SIZE='4' ALIGN='1'>https://example.ru/o/15</QR>",
+		"<PIC N='3'/>",
+		"<FEED N='2'/>",
+	}
+	cmds := doc.Commands()
+	if len(cmds) != len(expected) {
+		t.Fatalf("expected %d commands, got %d", len(expected), len(cmds))
+	}
+	for i := range expected {
+		if cmds[i] != expected[i] {
+			t.Errorf("command %d: expected %s, got %s", i, expected[i], cmds[i])
+		}
+	}
+}
+
+func TestNonFiscalDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  *NonFiscalDocument
+	}{
+		{"EAN13 with letters", NewNonFiscalDocument().Barcode(BarcodeEAN13, "46012345ABC93", false)},
+		{"ITF odd length", NewNonFiscalDocument().Barcode(BarcodeITF, "123", false)},
+		{"unknown barcode", NewNonFiscalDocument().Barcode(BarcodeType(42), "1", false)},
+		{"image out of range", NewNonFiscalDocument().Image(21)},
+		{"empty QR", NewNonFiscalDocument().QR("", 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.doc.Err() == nil {
+				t.Error("expected error, but got none")
+			}
+		})
+	}
+}
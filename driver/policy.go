@@ -0,0 +1,422 @@
+package driver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Operation - операция драйвера, контролируемая политикой.
+type Operation string
+
+const (
+	OpTechReset          Operation = "TechReset"
+	OpResetMGM           Operation = "ResetMGM"
+	OpCloseFiscalArchive Operation = "CloseFiscalArchive"
+	OpRegister           Operation = "Register"
+	OpReregister         Operation = "Reregister"
+	OpSetDateTimeFuture  Operation = "SetDateTimeFuture" // Установка времени ККТ в будущее
+)
+
+// RiskClass - класс риска операции.
+type RiskClass int
+
+const (
+	RiskLow      RiskClass = iota // Обратимые операции, политикой не ограничиваются
+	RiskHigh                      // Необратимые изменения регистрации и состояния ФН
+	RiskCritical                  // Уничтожение настроек ККТ, закрытие архива ФН
+)
+
+func (r RiskClass) String() string {
+	switch r {
+	case RiskHigh:
+		return "high"
+	case RiskCritical:
+		return "critical"
+	default:
+		return "low"
+	}
+}
+
+// operationRisks - классы риска контролируемых операций.
+var operationRisks = map[Operation]RiskClass{
+	OpTechReset:          RiskCritical,
+	OpCloseFiscalArchive: RiskCritical,
+	OpResetMGM:           RiskHigh,
+	OpRegister:           RiskHigh,
+	OpReregister:         RiskHigh,
+	OpSetDateTimeFuture:  RiskHigh,
+}
+
+// OperationRisk возвращает класс риска операции.
+func OperationRisk(op Operation) RiskClass {
+	return operationRisks[op]
+}
+
+// Principal - от чьего имени работает драйвер.
+type Principal struct {
+	Name string
+	Role string
+}
+
+// Policy определяет, какие операции разрешены.
+type Policy struct {
+	// Forbidden - операции, запрещенные всегда (подтверждение невозможно).
+	Forbidden []Operation
+	// RoleGrants - операции, разрешенные роли без подтверждения.
+	RoleGrants map[string][]Operation
+	// ApprovalTTL - срок действия подтверждения (0 - 5 минут).
+	ApprovalTTL time.Duration
+	// ClockTolerance - насколько можно перевести время ККТ вперед без
+	// подтверждения (0 - 10 минут).
+	ClockTolerance time.Duration
+}
+
+// CashierPolicy - политика для кассовых приложений: все операции высокого
+// риска запрещены.
+func CashierPolicy() Policy {
+	return Policy{Forbidden: []Operation{OpTechReset, OpResetMGM, OpCloseFiscalArchive, OpRegister, OpReregister, OpSetDateTimeFuture}}
+}
+
+// EngineerPolicy - политика для сервисных приложений: операции высокого
+// риска выполняются только после подтверждения (Approve).
+func EngineerPolicy() Policy {
+	return Policy{}
+}
+
+func (p Policy) forbidden(op Operation) bool {
+	for _, f := range p.Forbidden {
+		if f == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Policy) granted(role string, op Operation) bool {
+	for _, g := range p.RoleGrants[role] {
+		if g == op {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditRecord - запись журнала контролируемых операций.
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Operation Operation `json:"operation"`
+	Risk      string    `json:"risk"`
+	Principal string    `json:"principal"`
+	Role      string    `json:"role"`
+	Event     string    `json:"event"` // approve, execute, deny
+	Approver  string    `json:"approver,omitempty"`
+	Token     string    `json:"token,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// JSONAuditWriter возвращает функцию записи журнала в формате JSON Lines.
+func JSONAuditWriter(w io.Writer) func(AuditRecord) {
+	var mu sync.Mutex
+	return func(r AuditRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		data, err := json.Marshal(r)
+		if err != nil {
+			return
+		}
+		w.Write(append(data, '\n'))
+	}
+}
+
+// PolicyError возвращается при попытке выполнить запрещенную или неподтвержденную операцию.
+type PolicyError struct {
+	Op     Operation
+	Risk   RiskClass
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("операция %s (риск %s) отклонена: %s", e.Op, e.Risk, e.Reason)
+}
+
+// approval - выданное подтверждение операции.
+type approval struct {
+	op       Operation
+	approver string
+	reason   string
+	expires  time.Time
+}
+
+// PolicyDriver - обертка драйвера, выполняющая операции высокого риска только
+// с разрешения политики: по роли (Policy.RoleGrants) или по одноразовому
+// подтверждению, которое предъявляется при вызове (WithApproval). Все решения
+// записываются в журнал.
+// Оборачиваемый драйвер не экспортируется, чтобы политику нельзя было обойти;
+// остальные методы Driver передаются ему без изменений (policy_forward.go).
+type PolicyDriver struct {
+	drv Driver
+
+	policy    Policy
+	principal Principal
+	audit     func(AuditRecord)
+	now       func() time.Time
+
+	mu        sync.Mutex
+	approvals map[string]approval
+}
+
+var _ Driver = (*PolicyDriver)(nil)
+
+// NewPolicyDriver оборачивает драйвер. audit может быть nil.
+func NewPolicyDriver(drv Driver, policy Policy, principal Principal, audit func(AuditRecord)) *PolicyDriver {
+	if policy.ApprovalTTL <= 0 {
+		policy.ApprovalTTL = 5 * time.Minute
+	}
+	if policy.ClockTolerance <= 0 {
+		policy.ClockTolerance = 10 * time.Minute
+	}
+	if audit == nil {
+		audit = func(AuditRecord) {}
+	}
+	return &PolicyDriver{
+		drv:       drv,
+		policy:    policy,
+		principal: principal,
+		audit:     audit,
+		now:       time.Now,
+		approvals: make(map[string]approval),
+	}
+}
+
+func (p *PolicyDriver) record(op Operation, event string, a approval, token string, err error) {
+	r := AuditRecord{
+		Time:      p.now(),
+		Operation: op,
+		Risk:      OperationRisk(op).String(),
+		Principal: p.principal.Name,
+		Role:      p.principal.Role,
+		Event:     event,
+		Approver:  a.approver,
+		Token:     token,
+		Reason:    a.reason,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	p.audit(r)
+}
+
+// NewCashierDriver подключает драйвер ККТ с политикой кассового приложения
+// (CashierPolicy). NewMitsuDriver возвращает драйвер без ограничений; кассовым
+// приложениям нужно использовать этот конструктор или NewPolicyDriver с CashierPolicy.
+func NewCashierDriver(config Config, principal Principal, audit func(AuditRecord)) *PolicyDriver {
+	return NewPolicyDriver(NewMitsuDriver(config), CashierPolicy(), principal, audit)
+}
+
+// Approve выдает одноразовое подтверждение операции op от имени approver.
+// Подтверждение действует в течение Policy.ApprovalTTL и только при
+// предъявлении токена: p.WithApproval(token).TechReset(). Вызов операции
+// напрямую через PolicyDriver подтверждения не использует.
+func (p *PolicyDriver) Approve(op Operation, approver, reason string) (string, error) {
+	a := approval{op: op, approver: approver, reason: reason}
+	if p.policy.forbidden(op) {
+		err := &PolicyError{Op: op, Risk: OperationRisk(op), Reason: "операция запрещена политикой"}
+		p.record(op, "deny", a, "", err)
+		return "", err
+	}
+	if approver == "" {
+		return "", fmt.Errorf("не указано, кто подтверждает операцию %s", op)
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("ошибка формирования токена: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	a.expires = p.now().Add(p.policy.ApprovalTTL)
+
+	p.mu.Lock()
+	p.approvals[token] = a
+	p.mu.Unlock()
+	p.record(op, "approve", a, token, nil)
+	return token, nil
+}
+
+// Revoke отменяет неиспользованное подтверждение.
+func (p *PolicyDriver) Revoke(token string) {
+	p.mu.Lock()
+	delete(p.approvals, token)
+	p.mu.Unlock()
+}
+
+// ApprovedDriver - PolicyDriver, предъявляющий при операциях высокого риска
+// свои подтверждения (см. WithApproval). Подтверждения не расходуются другими
+// вызовами того же PolicyDriver.
+type ApprovedDriver struct {
+	*PolicyDriver
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+var _ Driver = (*ApprovedDriver)(nil)
+
+// WithApproval возвращает драйвер, предъявляющий указанные подтверждения.
+func (p *PolicyDriver) WithApproval(tokens ...string) *ApprovedDriver {
+	return &ApprovedDriver{PolicyDriver: p, tokens: append([]string(nil), tokens...)}
+}
+
+// Approve выдает подтверждение (см. PolicyDriver.Approve) и привязывает его к a.
+func (a *ApprovedDriver) Approve(op Operation, approver, reason string) (string, error) {
+	token, err := a.PolicyDriver.Approve(op, approver, reason)
+	if err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	a.tokens = append(a.tokens, token)
+	a.mu.Unlock()
+	return token, nil
+}
+
+func (a *ApprovedDriver) presented() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.tokens...)
+}
+
+// authorize проверяет, можно ли выполнить операцию, и расходует предъявленное подтверждение.
+func (p *PolicyDriver) authorize(op Operation, tokens []string) (approval, string, error) {
+	risk := OperationRisk(op)
+	if p.policy.forbidden(op) {
+		err := &PolicyError{Op: op, Risk: risk, Reason: "операция запрещена политикой"}
+		p.record(op, "deny", approval{}, "", err)
+		return approval{}, "", err
+	}
+	if p.policy.granted(p.principal.Role, op) {
+		return approval{approver: "role:" + p.principal.Role}, "", nil
+	}
+
+	p.mu.Lock()
+	now := p.now()
+	for _, token := range tokens {
+		a, ok := p.approvals[token]
+		if !ok {
+			continue
+		}
+		if now.After(a.expires) {
+			delete(p.approvals, token)
+			continue
+		}
+		if a.op == op {
+			delete(p.approvals, token)
+			p.mu.Unlock()
+			return a, token, nil
+		}
+	}
+	p.mu.Unlock()
+
+	err := &PolicyError{Op: op, Risk: risk, Reason: "нет подтверждения"}
+	p.record(op, "deny", approval{}, "", err)
+	return approval{}, "", err
+}
+
+// guard выполняет операцию с проверкой политики.
+func (p *PolicyDriver) guard(op Operation, tokens []string, fn func() error) error {
+	a, token, err := p.authorize(op, tokens)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	p.record(op, "execute", a, token, err)
+	return err
+}
+
+func (p *PolicyDriver) TechReset() error { return p.techReset(nil) }
+
+func (a *ApprovedDriver) TechReset() error { return a.techReset(a.presented()) }
+
+func (p *PolicyDriver) techReset(tokens []string) error {
+	return p.guard(OpTechReset, tokens, p.drv.TechReset)
+}
+
+func (p *PolicyDriver) ResetMGM() error { return p.resetMGM(nil) }
+
+func (a *ApprovedDriver) ResetMGM() error { return a.resetMGM(a.presented()) }
+
+func (p *PolicyDriver) resetMGM(tokens []string) error {
+	return p.guard(OpResetMGM, tokens, p.drv.ResetMGM)
+}
+
+func (p *PolicyDriver) CloseFiscalArchive() (*CloseFnResult, error) {
+	return p.closeFiscalArchive(nil)
+}
+
+func (a *ApprovedDriver) CloseFiscalArchive() (*CloseFnResult, error) {
+	return a.closeFiscalArchive(a.presented())
+}
+
+func (p *PolicyDriver) closeFiscalArchive(tokens []string) (*CloseFnResult, error) {
+	var res *CloseFnResult
+	err := p.guard(OpCloseFiscalArchive, tokens, func() (err error) {
+		res, err = p.drv.CloseFiscalArchive()
+		return err
+	})
+	return res, err
+}
+
+func (p *PolicyDriver) Register(req RegistrationRequest) (*RegResponse, error) {
+	return p.register(req, nil)
+}
+
+func (a *ApprovedDriver) Register(req RegistrationRequest) (*RegResponse, error) {
+	return a.register(req, a.presented())
+}
+
+func (p *PolicyDriver) register(req RegistrationRequest, tokens []string) (*RegResponse, error) {
+	var resp *RegResponse
+	err := p.guard(OpRegister, tokens, func() (err error) {
+		resp, err = p.drv.Register(req)
+		return err
+	})
+	return resp, err
+}
+
+func (p *PolicyDriver) Reregister(req RegistrationRequest, reasons []int) (*RegResponse, error) {
+	return p.reregister(req, reasons, nil)
+}
+
+func (a *ApprovedDriver) Reregister(req RegistrationRequest, reasons []int) (*RegResponse, error) {
+	return a.reregister(req, reasons, a.presented())
+}
+
+func (p *PolicyDriver) reregister(req RegistrationRequest, reasons []int, tokens []string) (*RegResponse, error) {
+	var resp *RegResponse
+	err := p.guard(OpReregister, tokens, func() (err error) {
+		resp, err = p.drv.Reregister(req, reasons)
+		return err
+	})
+	return resp, err
+}
+
+// SetDateTime требует подтверждения, если время переводится вперед больше чем
+// на Policy.ClockTolerance относительно текущего времени ККТ: документы ФН
+// с более поздней датой не позволят вернуть время обратно.
+func (p *PolicyDriver) SetDateTime(t time.Time) error { return p.setDateTime(t, nil) }
+
+func (a *ApprovedDriver) SetDateTime(t time.Time) error { return a.setDateTime(t, a.presented()) }
+
+func (p *PolicyDriver) setDateTime(t time.Time, tokens []string) error {
+	current, err := p.drv.GetDateTime()
+	if err != nil {
+		current = p.now()
+	}
+	if !t.After(current.Add(p.policy.ClockTolerance)) {
+		return p.drv.SetDateTime(t)
+	}
+	return p.guard(OpSetDateTimeFuture, tokens, func() error { return p.drv.SetDateTime(t) })
+}
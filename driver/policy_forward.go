@@ -0,0 +1,339 @@
+package driver
+
+import "time"
+
+// Методы Driver без проверки политики: PolicyDriver передает их
+// оборачиваемому драйверу без изменений. Операции высокого риска
+// реализованы в policy.go.
+
+func (p *PolicyDriver) Connect() error {
+	return p.drv.Connect()
+}
+
+func (p *PolicyDriver) Disconnect() error {
+	return p.drv.Disconnect()
+}
+
+func (p *PolicyDriver) GetFiscalInfo() (*FiscalInfo, error) {
+	return p.drv.GetFiscalInfo()
+}
+
+func (p *PolicyDriver) GetModel() (string, error) {
+	return p.drv.GetModel()
+}
+
+func (p *PolicyDriver) GetVersion() (string, string, string, error) {
+	return p.drv.GetVersion()
+}
+
+func (p *PolicyDriver) GetDateTime() (time.Time, error) {
+	return p.drv.GetDateTime()
+}
+
+func (p *PolicyDriver) GetCashier() (string, string, error) {
+	return p.drv.GetCashier()
+}
+
+func (p *PolicyDriver) GetPrinterSettings() (*PrinterSettings, error) {
+	return p.drv.GetPrinterSettings()
+}
+
+func (p *PolicyDriver) GetMoneyDrawerSettings() (*DrawerSettings, error) {
+	return p.drv.GetMoneyDrawerSettings()
+}
+
+func (p *PolicyDriver) GetComSettings() (int32, error) {
+	return p.drv.GetComSettings()
+}
+
+func (p *PolicyDriver) GetHeader(headerNum int) ([]ClicheLineData, error) {
+	return p.drv.GetHeader(headerNum)
+}
+
+func (p *PolicyDriver) GetLanSettings() (*LanSettings, error) {
+	return p.drv.GetLanSettings()
+}
+
+func (p *PolicyDriver) GetOfdSettings() (*OfdSettings, error) {
+	return p.drv.GetOfdSettings()
+}
+
+func (p *PolicyDriver) GetOismSettings() (*OismSettings, error) {
+	return p.drv.GetOismSettings()
+}
+
+func (p *PolicyDriver) GetOkpSettings() (*ServerSettings, error) {
+	return p.drv.GetOkpSettings()
+}
+
+func (p *PolicyDriver) GetTaxRates() (*TaxRates, error) {
+	return p.drv.GetTaxRates()
+}
+
+func (p *PolicyDriver) GetRegistrationData() (*RegData, error) {
+	return p.drv.GetRegistrationData()
+}
+
+func (p *PolicyDriver) GetShiftStatus() (*ShiftStatus, error) {
+	return p.drv.GetShiftStatus()
+}
+
+func (p *PolicyDriver) GetShiftTotals() (*ShiftTotals, error) {
+	return p.drv.GetShiftTotals()
+}
+
+func (p *PolicyDriver) GetFnStatus() (*FnStatus, error) {
+	return p.drv.GetFnStatus()
+}
+
+func (p *PolicyDriver) GetOfdExchangeStatus() (*OfdExchangeStatus, error) {
+	return p.drv.GetOfdExchangeStatus()
+}
+
+func (p *PolicyDriver) GetMarkingStatus() (*MarkingStatus, error) {
+	return p.drv.GetMarkingStatus()
+}
+
+func (p *PolicyDriver) GetTimezone() (int, error) {
+	return p.drv.GetTimezone()
+}
+
+func (p *PolicyDriver) GetPowerStatus() (int, error) {
+	return p.drv.GetPowerStatus()
+}
+
+func (p *PolicyDriver) GetPowerFlag() (bool, error) {
+	return p.drv.GetPowerFlag()
+}
+
+func (p *PolicyDriver) GetOptions() (*DeviceOptions, error) {
+	return p.drv.GetOptions()
+}
+
+func (p *PolicyDriver) GetCurrentDocumentType() (int, error) {
+	return p.drv.GetCurrentDocumentType()
+}
+
+func (p *PolicyDriver) GetDocumentXMLFromFN(fd int) (string, error) {
+	return p.drv.GetDocumentXMLFromFN(fd)
+}
+
+func (p *PolicyDriver) GetDocumentCopy(fd int, format CopyFormat) (string, error) {
+	return p.drv.GetDocumentCopy(fd, format)
+}
+
+func (p *PolicyDriver) SetPowerFlag(value int) error {
+	return p.drv.SetPowerFlag(value)
+}
+
+func (p *PolicyDriver) SetCashier(name string, inn string) error {
+	return p.drv.SetCashier(name, inn)
+}
+
+func (p *PolicyDriver) SetComSettings(speed int32) error {
+	return p.drv.SetComSettings(speed)
+}
+
+func (p *PolicyDriver) SetPrinterSettings(settings PrinterSettings) error {
+	return p.drv.SetPrinterSettings(settings)
+}
+
+func (p *PolicyDriver) SetMoneyDrawerSettings(settings DrawerSettings) error {
+	return p.drv.SetMoneyDrawerSettings(settings)
+}
+
+func (p *PolicyDriver) SetHeader(headerNum int, lines []ClicheLineData) error {
+	return p.drv.SetHeader(headerNum, lines)
+}
+
+func (p *PolicyDriver) SetHeaderLine(headerNum int, lineNum int, text string, format string) error {
+	return p.drv.SetHeaderLine(headerNum, lineNum, text, format)
+}
+
+func (p *PolicyDriver) SetLanSettings(settings LanSettings) error {
+	return p.drv.SetLanSettings(settings)
+}
+
+func (p *PolicyDriver) SetOfdSettings(settings OfdSettings) error {
+	return p.drv.SetOfdSettings(settings)
+}
+
+func (p *PolicyDriver) SetOismSettings(settings ServerSettings) error {
+	return p.drv.SetOismSettings(settings)
+}
+
+func (p *PolicyDriver) SetOkpSettings(settings ServerSettings) error {
+	return p.drv.SetOkpSettings(settings)
+}
+
+func (p *PolicyDriver) SetOption(optionNum int, value int) error {
+	return p.drv.SetOption(optionNum, value)
+}
+
+func (p *PolicyDriver) SetTimezone(value int) error {
+	return p.drv.SetTimezone(value)
+}
+
+func (p *PolicyDriver) OpenShift(operator string) error {
+	return p.drv.OpenShift(operator)
+}
+
+func (p *PolicyDriver) CloseShift(operator string) error {
+	return p.drv.CloseShift(operator)
+}
+
+func (p *PolicyDriver) PrintXReport() error {
+	return p.drv.PrintXReport()
+}
+
+func (p *PolicyDriver) PrintZReport() error {
+	return p.drv.PrintZReport()
+}
+
+func (p *PolicyDriver) ReportCurrentState() (*CurrentStateReport, error) {
+	return p.drv.ReportCurrentState()
+}
+
+func (p *PolicyDriver) CashIn(amount float64, operator string, print bool) error {
+	return p.drv.CashIn(amount, operator, print)
+}
+
+func (p *PolicyDriver) CashOut(amount float64, operator string, print bool) error {
+	return p.drv.CashOut(amount, operator, print)
+}
+
+func (p *PolicyDriver) OpenCheck(checkType int, taxSystem int) error {
+	return p.drv.OpenCheck(checkType, taxSystem)
+}
+
+func (p *PolicyDriver) OpenCheckWithOptions(checkType int, taxSystem int, opts OpenCheckOptions) error {
+	return p.drv.OpenCheckWithOptions(checkType, taxSystem, opts)
+}
+
+func (p *PolicyDriver) AddPosition(pos ItemPosition) error {
+	return p.drv.AddPosition(pos)
+}
+
+func (p *PolicyDriver) Subtotal() error {
+	return p.drv.Subtotal()
+}
+
+func (p *PolicyDriver) Payment(pay PaymentInfo) error {
+	return p.drv.Payment(pay)
+}
+
+func (p *PolicyDriver) CloseCheck() error {
+	return p.drv.CloseCheck()
+}
+
+func (p *PolicyDriver) CloseCheckWithOptions(opts CloseCheckOptions) (*CheckResult, error) {
+	return p.drv.CloseCheckWithOptions(opts)
+}
+
+func (p *PolicyDriver) CancelCheck() error {
+	return p.drv.CancelCheck()
+}
+
+func (p *PolicyDriver) OpenCorrectionCheck(checkType int, taxSystem int) error {
+	return p.drv.OpenCorrectionCheck(checkType, taxSystem)
+}
+
+func (p *PolicyDriver) MarkCheck(mark ItemMark, quantity float64) (*MarkCheckResult, error) {
+	return p.drv.MarkCheck(mark, quantity)
+}
+
+func (p *PolicyDriver) MarkRequestOism() (*MarkCheckResult, error) {
+	return p.drv.MarkRequestOism()
+}
+
+func (p *PolicyDriver) MarkAccept() error {
+	return p.drv.MarkAccept()
+}
+
+func (p *PolicyDriver) MarkReject() error {
+	return p.drv.MarkReject()
+}
+
+func (p *PolicyDriver) RebootDevice() error {
+	return p.drv.RebootDevice()
+}
+
+func (p *PolicyDriver) PrintDiagnostics() error {
+	return p.drv.PrintDiagnostics()
+}
+
+func (p *PolicyDriver) DeviceJob(job int) error {
+	return p.drv.DeviceJob(job)
+}
+
+func (p *PolicyDriver) Feed(lines int) error {
+	return p.drv.Feed(lines)
+}
+
+func (p *PolicyDriver) Cut() error {
+	return p.drv.Cut()
+}
+
+func (p *PolicyDriver) PrintLastDocument() error {
+	return p.drv.PrintLastDocument()
+}
+
+func (p *PolicyDriver) PrintDocumentCopy(fd int) error {
+	return p.drv.PrintDocumentCopy(fd)
+}
+
+func (p *PolicyDriver) PrintNonFiscal(doc *NonFiscalDocument) error {
+	return p.drv.PrintNonFiscal(doc)
+}
+
+func (p *PolicyDriver) UploadImage(index int, data []byte) error {
+	return p.drv.UploadImage(index, data)
+}
+
+func (p *PolicyDriver) OfdBeginRead() (int, error) {
+	return p.drv.OfdBeginRead()
+}
+
+func (p *PolicyDriver) OfdReadBlock(offset, length int) ([]byte, int, error) {
+	return p.drv.OfdReadBlock(offset, length)
+}
+
+func (p *PolicyDriver) OfdEndRead() error {
+	return p.drv.OfdEndRead()
+}
+
+func (p *PolicyDriver) OfdLoadReceipt(receipt []byte) error {
+	return p.drv.OfdLoadReceipt(receipt)
+}
+
+func (p *PolicyDriver) OfdCancelRead() error {
+	return p.drv.OfdCancelRead()
+}
+
+func (p *PolicyDriver) OfdReadFullDocument() ([]byte, error) {
+	return p.drv.OfdReadFullDocument()
+}
+
+func (p *PolicyDriver) OismBeginRead() (int, error) {
+	return p.drv.OismBeginRead()
+}
+
+func (p *PolicyDriver) OismReadBlock(offset, length int) ([]byte, int, error) {
+	return p.drv.OismReadBlock(offset, length)
+}
+
+func (p *PolicyDriver) OismEndRead() error {
+	return p.drv.OismEndRead()
+}
+
+func (p *PolicyDriver) OismLoadReceipt(receipt []byte) error {
+	return p.drv.OismLoadReceipt(receipt)
+}
+
+func (p *PolicyDriver) OismCancelRead() error {
+	return p.drv.OismCancelRead()
+}
+
+func (p *PolicyDriver) OismReadFullNotice() ([]byte, error) {
+	return p.drv.OismReadFullNotice()
+}
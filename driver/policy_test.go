@@ -0,0 +1,151 @@
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// policyStub - драйвер для тестов политики; неиспользуемые методы паникуют.
+type policyStub struct {
+	Driver
+	calls []string
+	clock time.Time
+}
+
+func (s *policyStub) TechReset() error { s.calls = append(s.calls, "TechReset"); return nil }
+func (s *policyStub) ResetMGM() error  { s.calls = append(s.calls, "ResetMGM"); return nil }
+func (s *policyStub) GetDateTime() (time.Time, error) {
+	return s.clock, nil
+}
+func (s *policyStub) SetDateTime(t time.Time) error {
+	s.calls = append(s.calls, "SetDateTime")
+	s.clock = t
+	return nil
+}
+
+func TestPolicyDriverCashierCannotReset(t *testing.T) {
+	stub := &policyStub{}
+	var audit bytes.Buffer
+	drv := NewPolicyDriver(stub, CashierPolicy(), Principal{Name: "Иванова", Role: "cashier"}, JSONAuditWriter(&audit))
+
+	var perr *PolicyError
+	if err := drv.TechReset(); !errors.As(err, &perr) || perr.Risk != RiskCritical {
+		t.Fatalf("expected policy error, got %v", err)
+	}
+	if _, err := drv.Approve(OpTechReset, "Иванова", "ошибка в программе"); err == nil {
+		t.Fatal("approval of a forbidden operation must fail")
+	}
+	if err := drv.TechReset(); err == nil || len(stub.calls) != 0 {
+		t.Fatalf("tech reset executed: %v %v", err, stub.calls)
+	}
+	if n := strings.Count(audit.String(), `"event":"deny"`); n != 3 {
+		t.Fatalf("unexpected audit:\n%s", audit.String())
+	}
+}
+
+func TestPolicyDriverHidesInnerDriver(t *testing.T) {
+	typ := reflect.TypeOf(PolicyDriver{})
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.IsExported() {
+			t.Errorf("exported field %s allows bypassing the policy", f.Name)
+		}
+	}
+}
+
+func TestPolicyDriverApproval(t *testing.T) {
+	stub := &policyStub{}
+	var records []AuditRecord
+	drv := NewPolicyDriver(stub, EngineerPolicy(), Principal{Name: "service", Role: "engineer"}, func(r AuditRecord) {
+		records = append(records, r)
+	})
+
+	if err := drv.ResetMGM(); err == nil {
+		t.Fatal("expected error without approval")
+	}
+	token, err := drv.Approve(OpResetMGM, "Петров", "плановое обслуживание")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Подтверждение действует только при предъявлении токена
+	if err := drv.ResetMGM(); err == nil {
+		t.Fatal("approval must not be used without its token")
+	}
+	if err := drv.WithApproval("other").ResetMGM(); err == nil {
+		t.Fatal("approval must not be used with another token")
+	}
+	approved := drv.WithApproval(token)
+	if err := approved.TechReset(); err == nil {
+		t.Fatal("approval must be limited to its operation")
+	}
+	if err := approved.ResetMGM(); err != nil {
+		t.Fatal(err)
+	}
+	// Подтверждение одноразовое
+	if err := approved.ResetMGM(); err == nil {
+		t.Fatal("approval must be consumed")
+	}
+	if len(stub.calls) != 1 {
+		t.Fatalf("unexpected calls: %v", stub.calls)
+	}
+	var executed *AuditRecord
+	for i := range records {
+		if records[i].Event == "execute" {
+			executed = &records[i]
+		}
+	}
+	if executed == nil || executed.Approver != "Петров" || executed.Token != token || executed.Reason != "плановое обслуживание" {
+		t.Fatalf("unexpected audit: %+v", records)
+	}
+
+	// Просроченное подтверждение не действует
+	now := time.Now()
+	drv.now = func() time.Time { return now }
+	token, err = drv.Approve(OpResetMGM, "Петров", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drv.now = func() time.Time { return now.Add(time.Hour) }
+	if err := drv.WithApproval(token).ResetMGM(); err == nil {
+		t.Fatal("expired approval must not be used")
+	}
+}
+
+func TestApprovedDriverApprove(t *testing.T) {
+	stub := &policyStub{}
+	drv := NewPolicyDriver(stub, EngineerPolicy(), Principal{Name: "service", Role: "engineer"}, nil)
+	approved := drv.WithApproval()
+	if _, err := approved.Approve(OpTechReset, "Петров", "замена платы"); err != nil {
+		t.Fatal(err)
+	}
+	// Подтверждение, выданное через ApprovedDriver, недоступно другим вызовам
+	if err := drv.TechReset(); err == nil {
+		t.Fatal("approval bound to another driver was used")
+	}
+	if err := approved.TechReset(); err != nil || len(stub.calls) != 1 {
+		t.Fatalf("unexpected result: %v %v", err, stub.calls)
+	}
+}
+
+func TestPolicyDriverRoleGrantAndClock(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	stub := &policyStub{clock: now}
+	policy := Policy{RoleGrants: map[string][]Operation{"admin": {OpResetMGM}}}
+	drv := NewPolicyDriver(stub, policy, Principal{Name: "root", Role: "admin"}, nil)
+
+	if err := drv.ResetMGM(); err != nil {
+		t.Fatalf("role grant ignored: %v", err)
+	}
+	if err := drv.SetDateTime(now.Add(time.Minute)); err != nil {
+		t.Fatalf("small clock adjustment must be allowed: %v", err)
+	}
+	if err := drv.SetDateTime(now.Add(-time.Hour)); err != nil {
+		t.Fatalf("setting clock back must be allowed: %v", err)
+	}
+	if err := drv.SetDateTime(now.Add(48 * time.Hour)); err == nil {
+		t.Fatal("setting clock into the future requires approval")
+	}
+}
@@ -0,0 +1,132 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mitsuscanner/pkg/fndoc"
+)
+
+var (
+	reCustomerEmail = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	reCustomerPhone = regexp.MustCompile(`^\+\d{11,15}$`)
+)
+
+// NormalizeCustomerContact приводит телефон или e-mail покупателя (T1008) к виду,
+// принимаемому ФН: телефон "+7XXXXXXXXXX", e-mail без изменений.
+// Допускает телефон в формате "8XXXXXXXXXX", "7XXXXXXXXXX" и с разделителями.
+func NormalizeCustomerContact(contact string) (string, error) {
+	contact = strings.TrimSpace(contact)
+	if contact == "" {
+		return "", fmt.Errorf("не задан телефон или e-mail покупателя")
+	}
+	if len([]rune(contact)) > 64 {
+		return "", fmt.Errorf("T1008 длиннее 64 символов")
+	}
+
+	if strings.Contains(contact, "@") {
+		if !reCustomerEmail.MatchString(contact) {
+			return "", fmt.Errorf("некорректный e-mail покупателя: %s", contact)
+		}
+		return contact, nil
+	}
+
+	// Телефон: убираем разделители
+	phone := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(contact)
+	if len(phone) == 11 && (phone[0] == '8' || phone[0] == '7') {
+		phone = "+7" + phone[1:]
+	}
+	if !reCustomerPhone.MatchString(phone) {
+		return "", fmt.Errorf("некорректный телефон покупателя: %s", contact)
+	}
+	return phone, nil
+}
+
+// CheckElectronicReceipt проверяет параметры электронного чека по данным регистрации ККТ
+// и возвращает нормализованный контакт покупателя (T1008).
+// Правила:
+//   - при расчетах в сети Интернет (T1108) контакт покупателя обязателен (ошибка ККТ #145);
+//   - чек можно не печатать только в режиме расчетов в Интернет или в автоматическом режиме (T1001);
+//   - вне этих режимов без печати чек можно оформить только при наличии контакта покупателя.
+func CheckElectronicReceipt(reg *RegData, opts CloseCheckOptions) (string, error) {
+	internet := reg != nil && reg.InternetAttr == "1"
+	automat := reg != nil && reg.AutoModeAttr == "1"
+
+	contact := ""
+	if opts.CustomerContact != "" {
+		c, err := NormalizeCustomerContact(opts.CustomerContact)
+		if err != nil {
+			return "", err
+		}
+		contact = c
+	}
+
+	if internet && contact == "" {
+		return "", fmt.Errorf("при расчетах в сети Интернет необходимо указать телефон или e-mail покупателя (T1008)")
+	}
+
+	if opts.NoPrint && !internet && !automat && contact == "" {
+		return "", fmt.Errorf("чек без печати допускается только при передаче телефона или e-mail покупателя (T1008)")
+	}
+
+	return contact, nil
+}
+
+// ReceiptQR формирует данные QR-кода чека по результату его закрытия.
+// checkType - признак расчета, с которым был открыт чек (1-4).
+func (r *CheckResult) ReceiptQR(fnSerial string, checkType int) (fndoc.ReceiptQR, error) {
+	dt, err := fndoc.ParseDateTime(r.Date + "T" + r.Time)
+	if err != nil {
+		return fndoc.ReceiptQR{}, fmt.Errorf("ошибка даты чека: %w", err)
+	}
+	total, err := strconv.ParseFloat(strings.ReplaceAll(r.Total, ",", "."), 64)
+	if err != nil {
+		return fndoc.ReceiptQR{}, fmt.Errorf("некорректный итог чека: %s", r.Total)
+	}
+	q := fndoc.ReceiptQR{
+		DateTime: dt,
+		Total:    total,
+		FNSerial: fnSerial,
+		FD:       r.FD,
+		FP:       r.FP,
+		Type:     checkType,
+	}
+	return q, q.Validate()
+}
+
+// CheckReceiptQR формирует данные QR-кода только что закрытого чека,
+// номер ФН берется из FnStatus.
+func CheckReceiptQR(drv Driver, res *CheckResult, checkType int) (fndoc.ReceiptQR, error) {
+	fn, err := drv.GetFnStatus()
+	if err != nil {
+		return fndoc.ReceiptQR{}, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+	}
+	return res.ReceiptQR(fn.Serial, checkType)
+}
+
+// ArchivedReceiptQR формирует данные QR-кода чека из архива ФН по номеру FD.
+func ArchivedReceiptQR(drv Driver, fd int) (fndoc.ReceiptQR, error) {
+	xmlDoc, err := drv.GetDocumentXMLFromFN(fd)
+	if err != nil {
+		return fndoc.ReceiptQR{}, fmt.Errorf("ошибка чтения ФД %d из архива ФН: %w", fd, err)
+	}
+	doc, err := fndoc.Parse(xmlDoc)
+	if err != nil {
+		return fndoc.ReceiptQR{}, fmt.Errorf("ошибка разбора ФД %d: %w", fd, err)
+	}
+	receipt, ok := doc.(*fndoc.Receipt)
+	if !ok {
+		return fndoc.ReceiptQR{}, fmt.Errorf("ФД %d не является чеком", fd)
+	}
+	fnSerial := ""
+	if receipt.FNSerial == "" {
+		fn, err := drv.GetFnStatus()
+		if err != nil {
+			return fndoc.ReceiptQR{}, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+		}
+		fnSerial = fn.Serial
+	}
+	return fndoc.ReceiptQRFromDocument(receipt, fnSerial)
+}
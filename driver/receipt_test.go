@@ -0,0 +1,91 @@
+package driver
+
+import "testing"
+
+func TestNormalizeCustomerContact(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		hasError bool
+	}{
+		{name: "phone with plus", input: "+79991234567", expected: "+79991234567"},
+		{name: "phone with leading 8", input: "8 (999) 123-45-67", expected: "+79991234567"},
+		{name: "phone with leading 7", input: "79991234567", expected: "+79991234567"},
+		{name: "email", input: " buyer@example.ru ", expected: "buyer@example.ru"},
+		{name: "short phone", input: "12345", hasError: true},
+		{name: "broken email", input: "buyer@", hasError: true},
+		{name: "empty", input: "", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NormalizeCustomerContact(tt.input)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("expected error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestCheckElectronicReceipt(t *testing.T) {
+	retail := &RegData{}
+	internet := &RegData{InternetAttr: "1"}
+	automat := &RegData{AutoModeAttr: "1"}
+
+	tests := []struct {
+		name     string
+		reg      *RegData
+		opts     CloseCheckOptions
+		expected string
+		hasError bool
+	}{
+		{name: "retail printed", reg: retail, opts: CloseCheckOptions{}},
+		{name: "retail no print without contact", reg: retail, opts: CloseCheckOptions{NoPrint: true}, hasError: true},
+		{name: "retail no print with contact", reg: retail, opts: CloseCheckOptions{NoPrint: true, CustomerContact: "a@b.ru"}, expected: "a@b.ru"},
+		{name: "internet without contact", reg: internet, opts: CloseCheckOptions{NoPrint: true}, hasError: true},
+		{name: "internet with phone", reg: internet, opts: CloseCheckOptions{NoPrint: true, CustomerContact: "89991234567"}, expected: "+79991234567"},
+		{name: "automat no print", reg: automat, opts: CloseCheckOptions{NoPrint: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contact, err := CheckElectronicReceipt(tt.reg, tt.opts)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("expected error, but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if contact != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, contact)
+			}
+		})
+	}
+}
+
+func TestCheckResultReceiptQR(t *testing.T) {
+	res := &CheckResult{FD: 15, FP: "2750011122", Date: "2024-03-01", Time: "09:05:30", Total: "99.90"}
+	q, err := res.ReceiptQR("9999078900001234", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "t=20240301T0905&s=99.90&fn=9999078900001234&i=15&fp=2750011122&n=2"; q.String() != want {
+		t.Fatalf("got %q, want %q", q.String(), want)
+	}
+	if _, err := (&CheckResult{FD: 1, FP: "1", Date: "bad"}).ReceiptQR("1", 1); err == nil {
+		t.Fatal("expected error for bad date")
+	}
+}
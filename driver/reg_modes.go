@@ -0,0 +1,189 @@
+package driver
+
+// RegistrationModes - режимы работы ККТ из параметров регистрации
+// (маски MODE и ExtMODE в <GET REG='?'/>).
+type RegistrationModes struct {
+	// MODE
+	Encryption bool // бит 0: Шифрование (T1056)
+	Autonomous bool // бит 1: Автономный режим (T1002)
+	Automat    bool // бит 2: Автоматический режим (T1001)
+	Services   bool // бит 3: Расчеты за услуги (T1109)
+	BSO        bool // бит 4: Применение БСО (T1110)
+	Internet   bool // бит 5: Расчеты в сети Интернет (T1108)
+	Catering   bool // бит 6: Общепит (DINE)
+	Wholesale  bool // бит 7: Оптовая торговля (OPT)
+
+	// ExtMODE
+	Excise         bool // бит 0: Подакцизные товары (T1207)
+	Gambling       bool // бит 1: Азартные игры (T1193)
+	Lottery        bool // бит 2: Лотереи (T1126)
+	PrinterAutomat bool // бит 3: Принтер в автомате (T1221)
+	Marking        bool // бит 4: Маркированные товары (MARK)
+	Pawn           bool // бит 5: Ломбард (PAWN)
+	Insurance      bool // бит 6: Страхование (INS)
+	Vending        bool // бит 7: Вендинг (VEND)
+}
+
+// LabelLang - язык наименований режимов.
+type LabelLang int
+
+const (
+	LabelsRU LabelLang = iota
+	LabelsEN
+)
+
+// ModeFlag описывает один режим работы ККТ.
+type ModeFlag struct {
+	Ext bool // true - бит маски ExtMODE, false - MODE
+	Bit int
+	RU  string
+	EN  string
+
+	field func(m *RegistrationModes) *bool
+}
+
+// Label возвращает наименование режима на указанном языке.
+func (f ModeFlag) Label(lang LabelLang) string {
+	if lang == LabelsEN {
+		return f.EN
+	}
+	return f.RU
+}
+
+var registrationModeFlags = []ModeFlag{
+	{Bit: 0, RU: "Шифрование данных", EN: "Encryption", field: func(m *RegistrationModes) *bool { return &m.Encryption }},
+	{Bit: 1, RU: "Автономный режим", EN: "Autonomous mode", field: func(m *RegistrationModes) *bool { return &m.Autonomous }},
+	{Bit: 2, RU: "Автоматический режим", EN: "Automatic mode", field: func(m *RegistrationModes) *bool { return &m.Automat }},
+	{Bit: 3, RU: "Расчеты за услуги", EN: "Services", field: func(m *RegistrationModes) *bool { return &m.Services }},
+	{Bit: 4, RU: "Применение БСО", EN: "Strict reporting forms (BSO)", field: func(m *RegistrationModes) *bool { return &m.BSO }},
+	{Bit: 5, RU: "Расчеты в сети Интернет", EN: "Internet payments", field: func(m *RegistrationModes) *bool { return &m.Internet }},
+	{Bit: 6, RU: "Общепит", EN: "Catering", field: func(m *RegistrationModes) *bool { return &m.Catering }},
+	{Bit: 7, RU: "Оптовая торговля", EN: "Wholesale", field: func(m *RegistrationModes) *bool { return &m.Wholesale }},
+	{Ext: true, Bit: 0, RU: "Подакцизные товары", EN: "Excise goods", field: func(m *RegistrationModes) *bool { return &m.Excise }},
+	{Ext: true, Bit: 1, RU: "Проведение азартных игр", EN: "Gambling", field: func(m *RegistrationModes) *bool { return &m.Gambling }},
+	{Ext: true, Bit: 2, RU: "Проведение лотерей", EN: "Lottery", field: func(m *RegistrationModes) *bool { return &m.Lottery }},
+	{Ext: true, Bit: 3, RU: "Принтер в автомате", EN: "Printer in vending automat", field: func(m *RegistrationModes) *bool { return &m.PrinterAutomat }},
+	{Ext: true, Bit: 4, RU: "Маркированные товары", EN: "Marked goods", field: func(m *RegistrationModes) *bool { return &m.Marking }},
+	{Ext: true, Bit: 5, RU: "Ломбард", EN: "Pawnshop", field: func(m *RegistrationModes) *bool { return &m.Pawn }},
+	{Ext: true, Bit: 6, RU: "Страхование", EN: "Insurance", field: func(m *RegistrationModes) *bool { return &m.Insurance }},
+	{Ext: true, Bit: 7, RU: "Вендинг", EN: "Vending", field: func(m *RegistrationModes) *bool { return &m.Vending }},
+}
+
+// RegistrationModeFlags возвращает описания всех режимов в порядке битов MODE, затем ExtMODE.
+func RegistrationModeFlags() []ModeFlag {
+	return append([]ModeFlag(nil), registrationModeFlags...)
+}
+
+// DecodeRegistrationModes разбирает маски MODE и ExtMODE.
+func DecodeRegistrationModes(mode, extMode uint32) RegistrationModes {
+	var m RegistrationModes
+	for _, f := range registrationModeFlags {
+		mask := mode
+		if f.Ext {
+			mask = extMode
+		}
+		*f.field(&m) = mask&(1<<f.Bit) != 0
+	}
+	return m
+}
+
+// Encode возвращает маски MODE и ExtMODE.
+func (m RegistrationModes) Encode() (mode, extMode uint32) {
+	for _, f := range registrationModeFlags {
+		if !*f.field(&m) {
+			continue
+		}
+		if f.Ext {
+			extMode |= 1 << f.Bit
+		} else {
+			mode |= 1 << f.Bit
+		}
+	}
+	return mode, extMode
+}
+
+// Enabled возвращает описания включенных режимов.
+func (m RegistrationModes) Enabled() []ModeFlag {
+	var res []ModeFlag
+	for _, f := range registrationModeFlags {
+		if *f.field(&m) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// Labels возвращает наименования включенных режимов на указанном языке.
+func (m RegistrationModes) Labels(lang LabelLang) []string {
+	var res []string
+	for _, f := range m.Enabled() {
+		res = append(res, f.Label(lang))
+	}
+	return res
+}
+
+// Modes возвращает режимы работы из данных регистрации. Режим считается
+// включенным, если установлен бит маски или соответствующий атрибут равен "1".
+func (r *RegData) Modes() RegistrationModes {
+	m := DecodeRegistrationModes(r.ModeMask, r.ExtModeMask)
+	attr := func(v string) bool { return v == "1" }
+	m.Encryption = m.Encryption || attr(r.EncryptAttr)
+	m.Autonomous = m.Autonomous || attr(r.AutonomAttr)
+	m.Automat = m.Automat || attr(r.AutoModeAttr)
+	m.Services = m.Services || attr(r.ServiceAttr)
+	m.BSO = m.BSO || attr(r.BsoAttr)
+	m.Internet = m.Internet || attr(r.InternetAttr)
+	m.Catering = m.Catering || attr(r.DineAttr)
+	m.Wholesale = m.Wholesale || attr(r.OptAttr)
+	m.Excise = m.Excise || attr(r.ExciseAttr)
+	m.Gambling = m.Gambling || attr(r.GamblingAttr)
+	m.Lottery = m.Lottery || attr(r.LotteryAttr)
+	m.PrinterAutomat = m.PrinterAutomat || attr(r.PrintAutoAttr)
+	m.Marking = m.Marking || attr(r.MarkAttr)
+	m.Pawn = m.Pawn || attr(r.PawnAttr)
+	m.Insurance = m.Insurance || attr(r.InsAttr)
+	m.Vending = m.Vending || attr(r.VendAttr)
+	return m
+}
+
+// Modes возвращает режимы работы, заданные в запросе регистрации.
+func (req *RegistrationRequest) Modes() RegistrationModes {
+	return RegistrationModes{
+		Encryption:     req.Encryption,
+		Autonomous:     req.AutonomousMode,
+		Automat:        req.AutomatMode,
+		Services:       req.Service,
+		BSO:            req.BSO,
+		Internet:       req.InternetCalc,
+		Catering:       req.Catering,
+		Wholesale:      req.Wholesale,
+		Excise:         req.Excise,
+		Gambling:       req.Gambling,
+		Lottery:        req.Lottery,
+		PrinterAutomat: req.PrinterAutomat,
+		Marking:        req.Marking,
+		Pawn:           req.PawnShop,
+		Insurance:      req.Insurance,
+		Vending:        req.Vending,
+	}
+}
+
+// SetModes заполняет флаги режимов запроса регистрации.
+func (req *RegistrationRequest) SetModes(m RegistrationModes) {
+	req.Encryption = m.Encryption
+	req.AutonomousMode = m.Autonomous
+	req.AutomatMode = m.Automat
+	req.Service = m.Services
+	req.BSO = m.BSO
+	req.InternetCalc = m.Internet
+	req.Catering = m.Catering
+	req.Wholesale = m.Wholesale
+	req.Excise = m.Excise
+	req.Gambling = m.Gambling
+	req.Lottery = m.Lottery
+	req.PrinterAutomat = m.PrinterAutomat
+	req.Marking = m.Marking
+	req.PawnShop = m.Pawn
+	req.Insurance = m.Insurance
+	req.Vending = m.Vending
+}
@@ -0,0 +1,46 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistrationModesRoundTrip(t *testing.T) {
+	m := DecodeRegistrationModes(0x29, 0x90) // MODE: 0,3,5; ExtMODE: 4,7
+	want := RegistrationModes{Encryption: true, Services: true, Internet: true, Marking: true, Vending: true}
+	if m != want {
+		t.Fatalf("unexpected modes: %+v", m)
+	}
+	if mode, ext := m.Encode(); mode != 0x29 || ext != 0x90 {
+		t.Fatalf("unexpected masks: %#x %#x", mode, ext)
+	}
+	for mode := uint32(0); mode < 256; mode++ {
+		if got, ext := DecodeRegistrationModes(mode, 255-mode).Encode(); got != mode || ext != 255-mode {
+			t.Fatalf("round trip failed for %#x: %#x %#x", mode, got, ext)
+		}
+	}
+
+	if got := m.Labels(LabelsRU); !reflect.DeepEqual(got, []string{"Шифрование данных", "Расчеты за услуги", "Расчеты в сети Интернет", "Маркированные товары", "Вендинг"}) {
+		t.Fatalf("unexpected RU labels: %v", got)
+	}
+	if got := m.Labels(LabelsEN); !reflect.DeepEqual(got, []string{"Encryption", "Services", "Internet payments", "Marked goods", "Vending"}) {
+		t.Fatalf("unexpected EN labels: %v", got)
+	}
+	if len(RegistrationModeFlags()) != 16 {
+		t.Fatalf("unexpected number of flags: %d", len(RegistrationModeFlags()))
+	}
+}
+
+func TestRegistrationModesFromRegData(t *testing.T) {
+	reg := &RegData{ModeMask: 0x02, InternetAttr: "1", ExciseAttr: "0", PawnAttr: "1"}
+	m := reg.Modes()
+	if !m.Autonomous || !m.Internet || m.Excise || !m.Pawn {
+		t.Fatalf("unexpected modes: %+v", m)
+	}
+
+	var req RegistrationRequest
+	req.SetModes(m)
+	if !req.AutonomousMode || !req.InternetCalc || !req.PawnShop || req.Modes() != m {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+}
@@ -0,0 +1,253 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"mitsuscanner/pkg/rnm"
+)
+
+var (
+	reEmail   = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	reFnsSite = regexp.MustCompile(`^(https?://)?[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)+(/\S*)?$`)
+)
+
+// ValidationIssue - одна ошибка в параметрах регистрации.
+type ValidationIssue struct {
+	Field   string // Имя поля RegistrationRequest
+	Tag     string // Тег ФФД (может быть пустым)
+	Message string
+}
+
+func (i ValidationIssue) String() string {
+	if i.Tag != "" {
+		return fmt.Sprintf("%s (%s): %s", i.Field, i.Tag, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Field, i.Message)
+}
+
+// ValidationErrors - список ошибок проверки параметров регистрации.
+type ValidationErrors []ValidationIssue
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, issue := range e {
+		lines[i] = issue.String()
+	}
+	return "некорректные параметры регистрации: " + strings.Join(lines, "; ")
+}
+
+// Err возвращает nil, если ошибок нет (для возврата в качестве error).
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// RegValidationOptions - дополнительные данные для проверки параметров регистрации.
+type RegValidationOptions struct {
+	// KKTSerial - заводской номер ККТ для проверки контрольных цифр РНМ
+	// (пустой - проверяется только формат РНМ).
+	KKTSerial string
+	// CurrentFfdVer - версия ФФД, на которой работает ККТ. Используется, если
+	// в запросе версия ФФД не указана; если неизвестна и она, режимы,
+	// доступные только в ФФД 1.2, не проверяются.
+	CurrentFfdVer string
+}
+
+// ValidateINN проверяет формат и контрольные цифры ИНН (10 или 12 цифр).
+func ValidateINN(inn string) error {
+	if len(inn) != 10 && len(inn) != 12 {
+		return fmt.Errorf("ИНН должен содержать 10 или 12 цифр")
+	}
+	d := make([]int, len(inn))
+	for i, r := range inn {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("ИНН должен состоять только из цифр")
+		}
+		d[i] = int(r - '0')
+	}
+	check := func(n int, weights []int) bool {
+		sum := 0
+		for i, w := range weights {
+			sum += d[i] * w
+		}
+		return sum%11%10 == d[n]
+	}
+	ok := false
+	if len(inn) == 10 {
+		ok = check(9, []int{2, 4, 10, 3, 5, 9, 4, 6, 8})
+	} else {
+		ok = check(10, []int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) &&
+			check(11, []int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8})
+	}
+	if !ok {
+		return fmt.Errorf("неверные контрольные цифры ИНН")
+	}
+	return nil
+}
+
+// ValidateRegistration проверяет параметры регистрации до отправки в ККТ
+// и возвращает все найденные ошибки.
+func ValidateRegistration(req RegistrationRequest, opts RegValidationOptions) ValidationErrors {
+	var errs ValidationErrors
+	add := func(field, tag, format string, args ...interface{}) {
+		errs = append(errs, ValidationIssue{Field: field, Tag: tag, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// ИНН пользователя и РНМ при перерегистрации не передаются
+	if !req.IsReregistration || req.Inn != "" {
+		if err := ValidateINN(strings.TrimSpace(req.Inn)); err != nil {
+			add("Inn", "T1018", "%v", err)
+		}
+	}
+	if !req.IsReregistration || req.RNM != "" {
+		regNum := strings.TrimSpace(req.RNM)
+		if len(regNum) != rnm.Length || !isDigits(regNum) {
+			add("RNM", "T1037", "РНМ должен содержать %d цифр", rnm.Length)
+		} else if opts.KKTSerial != "" && ValidateINN(strings.TrimSpace(req.Inn)) == nil {
+			if err := rnm.VerifyRNM(regNum, strings.TrimSpace(req.Inn), opts.KKTSerial); err != nil {
+				add("RNM", "T1037", "%v", err)
+			}
+		}
+	}
+
+	// ИНН ОФД
+	ofdInn := strings.TrimSpace(req.OfdInn)
+	if req.AutonomousMode {
+		if strings.Trim(ofdInn, "0") != "" {
+			add("OfdInn", "T1017", "в автономном режиме ИНН ОФД не указывается")
+		}
+	} else if ofdInn == "" {
+		add("OfdInn", "T1017", "не указан ИНН ОФД")
+	} else if err := ValidateINN(ofdInn); err != nil {
+		add("OfdInn", "T1017", "%v", err)
+	} else if len(ofdInn) != 10 {
+		add("OfdInn", "T1017", "ИНН ОФД (юридического лица) должен содержать 10 цифр")
+	}
+
+	// Системы налогообложения
+	taxes := map[int]bool{}
+	if strings.TrimSpace(req.TaxSystems) == "" {
+		add("TaxSystems", "T1062", "не указаны системы налогообложения")
+	} else {
+		for _, p := range strings.Split(req.TaxSystems, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(p))
+			switch {
+			case err != nil || code < 0 || code > 5:
+				add("TaxSystems", "T1062", "некорректный код СНО: %q (допустимо 0-5)", strings.TrimSpace(p))
+			case taxes[code]:
+				add("TaxSystems", "T1062", "код СНО %d указан повторно", code)
+			default:
+				taxes[code] = true
+			}
+		}
+	}
+	if base := strings.TrimSpace(req.TaxSystemBase); base != "" {
+		code, err := strconv.Atoi(base)
+		if err != nil || !taxes[code] {
+			add("TaxSystemBase", "T1062", "базовая СНО %q отсутствует в списке СНО", base)
+		}
+	}
+
+	// Версия ФФД
+	switch strings.TrimSpace(req.FfdVer) {
+	case "", "2", "4", "1.05", "1.2":
+	default:
+		add("FfdVer", "T1209", "неподдерживаемая версия ФФД: %q (допустимо 2 - ФФД 1.05, 4 - ФФД 1.2)", req.FfdVer)
+	}
+	ffdVer := strings.TrimSpace(req.FfdVer)
+	if ffdVer == "" {
+		ffdVer = strings.TrimSpace(opts.CurrentFfdVer)
+	}
+	if ffdVer != "" && !IsFFD12(ffdVer) {
+		for _, f := range []struct {
+			on    bool
+			field string
+			name  string
+		}{
+			{req.Marking, "Marking", "маркированные товары"},
+			{req.PawnShop, "PawnShop", "ломбард"},
+			{req.Insurance, "Insurance", "страхование"},
+			{req.Catering, "Catering", "общепит"},
+			{req.Wholesale, "Wholesale", "оптовая торговля"},
+			{req.Vending, "Vending", "вендинг"},
+		} {
+			if f.on {
+				add(f.field, "T1209", "режим \"%s\" доступен только в ФФД 1.2", f.name)
+			}
+		}
+	}
+
+	// Недопустимые сочетания режимов
+	if req.AutonomousMode && req.InternetCalc {
+		add("InternetCalc", "T1108", "расчеты в сети Интернет недоступны в автономном режиме")
+	}
+	if req.Gambling || req.Lottery {
+		for _, f := range []struct {
+			on    bool
+			field string
+			name  string
+		}{
+			{req.Excise, "Excise", "подакцизные товары"},
+			{req.Marking, "Marking", "маркированные товары"},
+			{req.Catering, "Catering", "общепит"},
+			{req.Wholesale, "Wholesale", "оптовая торговля"},
+			{req.PawnShop, "PawnShop", "ломбард"},
+			{req.Insurance, "Insurance", "страхование"},
+		} {
+			if f.on {
+				add(f.field, "", "режим \"%s\" несовместим с проведением азартных игр и лотерей", f.name)
+			}
+		}
+	}
+	automat := strings.TrimSpace(req.AutomatNumber)
+	if (req.AutomatMode || req.PrinterAutomat) && automat == "" {
+		add("AutomatNumber", "T1036", "для автоматического режима и принтера в автомате требуется номер автомата")
+	}
+
+	// Адреса и длины полей
+	email := strings.TrimSpace(req.SenderEmail)
+	if email != "" && !reEmail.MatchString(email) {
+		add("SenderEmail", "T1117", "некорректный адрес электронной почты: %q", email)
+	}
+	site := strings.TrimSpace(req.FnsSite)
+	if site != "" && !reFnsSite.MatchString(site) {
+		add("FnsSite", "T1060", "некорректный адрес сайта ФНС: %q", site)
+	}
+	for _, f := range []struct {
+		value string
+		field string
+		tag   string
+		max   int
+	}{
+		{req.OrgName, "OrgName", "T1048", 256},
+		{req.Address, "Address", "T1009", 256},
+		{req.Place, "Place", "T1187", 256},
+		{req.OfdName, "OfdName", "T1046", 256},
+		{req.FnsSite, "FnsSite", "T1060", 256},
+		{req.SenderEmail, "SenderEmail", "T1117", 64},
+		{req.AutomatNumber, "AutomatNumber", "T1036", 20},
+	} {
+		if n := utf8.RuneCountInString(f.value); n > f.max {
+			add(f.field, f.tag, "длина %d превышает %d символов", n, f.max)
+		}
+	}
+	return errs
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+
+	"mitsuscanner/pkg/rnm"
+)
+
+func TestValidateINN(t *testing.T) {
+	for _, inn := range []string{"7707083893", "500100732259"} {
+		if err := ValidateINN(inn); err != nil {
+			t.Errorf("%s: %v", inn, err)
+		}
+	}
+	for _, inn := range []string{"7707083894", "500100732258", "12345", "77070838a3", ""} {
+		if err := ValidateINN(inn); err == nil {
+			t.Errorf("%s: expected error", inn)
+		}
+	}
+}
+
+func validRegRequest(t *testing.T) RegistrationRequest {
+	regNum, err := rnm.GenerateRNM("1", "7707083893", "065001234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return RegistrationRequest{
+		RNM:         regNum,
+		Inn:         "7707083893",
+		FfdVer:      "4",
+		TaxSystems:  "0,1",
+		OrgName:     "ООО Ромашка",
+		Address:     "г. Москва",
+		Place:       "Магазин",
+		OfdName:     "ОФД",
+		OfdInn:      "7707083893",
+		FnsSite:     "www.nalog.gov.ru",
+		SenderEmail: "kkt@example.ru",
+		Marking:     true,
+	}
+}
+
+func TestValidateRegistrationValid(t *testing.T) {
+	req := validRegRequest(t)
+	if errs := ValidateRegistration(req, RegValidationOptions{KKTSerial: "065001234567"}); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	// Автономный режим без ОФД
+	req.AutonomousMode = true
+	req.OfdInn = "0000000000"
+	req.OfdName = ""
+	if errs := ValidateRegistration(req, RegValidationOptions{}); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	// При перерегистрации ИНН и РНМ не передаются
+	req = validRegRequest(t)
+	req.IsReregistration = true
+	req.Inn, req.RNM = "", ""
+	if errs := ValidateRegistration(req, RegValidationOptions{}); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidateRegistrationEmptyFfd(t *testing.T) {
+	req := validRegRequest(t)
+	req.FfdVer = ""
+
+	// Версия ФФД неизвестна - режимы ФФД 1.2 не отклоняются
+	if errs := ValidateRegistration(req, RegValidationOptions{}); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	// Используется версия ФФД ККТ
+	if errs := ValidateRegistration(req, RegValidationOptions{CurrentFfdVer: "4"}); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if errs := ValidateRegistration(req, RegValidationOptions{CurrentFfdVer: "2"}); len(errs) != 1 || errs[0].Field != "Marking" {
+		t.Fatalf("expected marking issue for FFD 1.05, got %v", errs)
+	}
+}
+
+func TestValidateRegistrationCollectsAllIssues(t *testing.T) {
+	req := validRegRequest(t)
+	req.Inn = "7707083894"
+	req.TaxSystems = "0,7,0"
+	req.TaxSystemBase = "3"
+	req.SenderEmail = "kkt.example.ru"
+	req.FnsSite = "nalog"
+	req.FfdVer = "2"
+	req.AutonomousMode = true
+	req.InternetCalc = true
+	req.Gambling = true
+	req.Excise = true
+	req.AutomatMode = true
+	req.OrgName = strings.Repeat("я", 257)
+
+	errs := ValidateRegistration(req, RegValidationOptions{KKTSerial: "065001234567"})
+	got := map[string]int{}
+	for _, e := range errs {
+		got[e.Field]++
+	}
+	want := map[string]int{
+		"Inn":           1,
+		"OfdInn":        1, // автономный режим с ИНН ОФД
+		"TaxSystems":    2, // код 7 и повтор 0
+		"TaxSystemBase": 1,
+		"SenderEmail":   1,
+		"FnsSite":       1,
+		"Marking":       2, // ФФД 1.05 и азартные игры
+		"InternetCalc":  1,
+		"Excise":        1,
+		"AutomatNumber": 1,
+		"OrgName":       1,
+	}
+	for field, n := range want {
+		if got[field] != n {
+			t.Errorf("%s: got %d issues, want %d (%v)", field, got[field], n, errs)
+		}
+	}
+	if len(errs) != 13 {
+		t.Errorf("unexpected number of issues: %d: %v", len(errs), errs)
+	}
+	if err := errs.Err(); err == nil || !strings.Contains(err.Error(), "T1062") {
+		t.Fatalf("unexpected error text: %v", err)
+	}
+}
+
+func TestValidateRegistrationRNMCheckDigits(t *testing.T) {
+	req := validRegRequest(t)
+	errs := ValidateRegistration(req, RegValidationOptions{KKTSerial: "065009999999"})
+	if len(errs) != 1 || errs[0].Field != "RNM" || errs[0].Tag != "T1037" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
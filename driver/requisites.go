@@ -0,0 +1,93 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var reFoivID = regexp.MustCompile(`^\d{3}$`)
+
+// IsFFD12 возвращает true, если версия ФФД (T1209 или строка версии) соответствует ФФД 1.2.
+func IsFFD12(ffdVer string) bool {
+	switch strings.TrimSpace(ffdVer) {
+	case "4", "1.2", "1.20":
+		return true
+	}
+	return false
+}
+
+// ValidateIndustryRequisites проверяет отраслевые реквизиты (T1260/T1261).
+// Отраслевые реквизиты поддерживаются только в ФФД 1.2.
+func ValidateIndustryRequisites(ffdVer string, reqs []IndustryRequisite) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	if !IsFFD12(ffdVer) {
+		return fmt.Errorf("отраслевые реквизиты (T1260/T1261) поддерживаются только в ФФД 1.2 (текущая версия: %s)", ffdVer)
+	}
+	for i, r := range reqs {
+		if !reFoivID.MatchString(r.FoivID) || r.FoivID == "000" {
+			return fmt.Errorf("отраслевой реквизит #%d: некорректный идентификатор ФОИВ (T1262): %q", i+1, r.FoivID)
+		}
+		if r.DocDate.IsZero() {
+			return fmt.Errorf("отраслевой реквизит #%d: не задана дата документа основания (T1263)", i+1)
+		}
+		if r.DocNumber == "" || utf8.RuneCountInString(r.DocNumber) > 32 {
+			return fmt.Errorf("отраслевой реквизит #%d: номер документа основания (T1264) должен быть от 1 до 32 символов", i+1)
+		}
+		if r.Value == "" || utf8.RuneCountInString(r.Value) > 256 {
+			return fmt.Errorf("отраслевой реквизит #%d: значение (T1265) должно быть от 1 до 256 символов", i+1)
+		}
+	}
+	return nil
+}
+
+// ValidateOperationalRequisite проверяет операционный реквизит чека (T1270).
+// Операционный реквизит поддерживается только в ФФД 1.2.
+func ValidateOperationalRequisite(ffdVer string, r *OperationalRequisite) error {
+	if r == nil {
+		return nil
+	}
+	if !IsFFD12(ffdVer) {
+		return fmt.Errorf("операционный реквизит (T1270) поддерживается только в ФФД 1.2 (текущая версия: %s)", ffdVer)
+	}
+	if r.OperationID < 0 || r.OperationID > 255 {
+		return fmt.Errorf("идентификатор операции (T1271) должен быть в диапазоне 0..255: %d", r.OperationID)
+	}
+	if r.Data == "" || utf8.RuneCountInString(r.Data) > 64 {
+		return fmt.Errorf("данные операции (T1272) должны быть от 1 до 64 символов")
+	}
+	if r.DateTime.IsZero() {
+		return fmt.Errorf("не задана дата, время операции (T1273)")
+	}
+	return nil
+}
+
+// industryTags формирует составные теги отраслевого реквизита (tag = 1260 или 1261).
+func industryTags(tag int, reqs []IndustryRequisite) string {
+	var sb strings.Builder
+	for _, r := range reqs {
+		var inner strings.Builder
+		writeTag(&inner, 1262, r.FoivID)
+		writeTag(&inner, 1263, r.DocDate.Format("02.01.2006"))
+		writeTag(&inner, 1264, r.DocNumber)
+		writeTag(&inner, 1265, r.Value)
+		sb.WriteString(fmt.Sprintf("<T%d>%s</T%d>", tag, inner.String(), tag))
+	}
+	return sb.String()
+}
+
+// operationalTags формирует составной тег операционного реквизита (T1270).
+func operationalTags(r *OperationalRequisite) string {
+	if r == nil {
+		return ""
+	}
+	var inner strings.Builder
+	writeTag(&inner, 1271, strconv.Itoa(r.OperationID))
+	writeTag(&inner, 1272, r.Data)
+	writeTag(&inner, 1273, r.DateTime.Format("2006-01-02T15:04:05"))
+	return "<T1270>" + inner.String() + "</T1270>"
+}
@@ -0,0 +1,50 @@
+package driver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateIndustryRequisites(t *testing.T) {
+	valid := IndustryRequisite{
+		FoivID:    "030",
+		DocDate:   time.Date(2022, 7, 21, 0, 0, 0, 0, time.UTC),
+		DocNumber: "1944",
+		Value:     "tm=mdlp&sid=00752852194630",
+	}
+
+	if err := ValidateIndustryRequisites("4", []IndustryRequisite{valid}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateIndustryRequisites("2", []IndustryRequisite{valid}); err == nil {
+		t.Error("expected error for FFD 1.05")
+	}
+
+	badFoiv := valid
+	badFoiv.FoivID = "30"
+	if err := ValidateIndustryRequisites("1.2", []IndustryRequisite{badFoiv}); err == nil {
+		t.Error("expected error for bad FOIV id")
+	}
+}
+
+func TestIndustryAndOperationalTags(t *testing.T) {
+	tags := industryTags(1260, []IndustryRequisite{{
+		FoivID:    "030",
+		DocDate:   time.Date(2022, 7, 21, 0, 0, 0, 0, time.UTC),
+		DocNumber: "1944",
+		Value:     "mode=horeca",
+	}})
+	expected := "<T1260><T1262>030</T1262><T1263>21.07.2022</T1263><T1264>1944</T1264><T1265>mode=horeca</T1265></T1260>"
+	if tags != expected {
+		t.Errorf("expected %s, got %s", expected, tags)
+	}
+
+	op := &OperationalRequisite{OperationID: 0, Data: "order-15", DateTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := ValidateOperationalRequisite("4", op); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected = "<T1270><T1271>0</T1271><T1272>order-15</T1272><T1273>2024-01-02T03:04:05</T1273></T1270>"
+	if got := operationalTags(op); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,286 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Коды причин перерегистрации (атрибут BASE команды <REG>, T1101/T1205).
+const (
+	ReasonFnReplace      = 1  // Замена ФН
+	ReasonOfdChange      = 2  // Замена ОФД
+	ReasonUserRequisites = 3  // Изменение реквизитов пользователя
+	ReasonAddress        = 4  // Изменение адреса и (или) места расчетов
+	ReasonAutonomousOff  = 5  // Перевод из автономного режима в режим передачи данных
+	ReasonAutonomousOn   = 6  // Перевод из режима передачи данных в автономный режим
+	ReasonModelVersion   = 7  // Изменение версии модели ККТ
+	ReasonTaxSystems     = 8  // Изменение перечня СНО
+	ReasonAutomatNumber  = 9  // Изменение номера автомата
+	ReasonAutomatOff     = 10 // Отключение автоматического режима
+	ReasonAutomatOn      = 11 // Включение автоматического режима
+	ReasonBSOOn          = 12 // Включение режима БСО
+	ReasonBSOOff         = 13 // Отключение режима БСО
+	ReasonInternetOff    = 14 // Отключение режима расчетов в сети Интернет
+	ReasonInternetOn     = 15 // Включение режима расчетов в сети Интернет
+	ReasonGamblingOff    = 18 // Отключение режима азартных игр
+	ReasonGamblingOn     = 19 // Включение режима азартных игр
+	ReasonLotteryOff     = 20 // Отключение режима лотерей
+	ReasonLotteryOn      = 21 // Включение режима лотерей
+	ReasonFFDVersion     = 22 // Изменение версии ФФД
+	ReasonOther          = 32 // Иные причины
+)
+
+// Коды причин перерегистрации ФФД 1.05 (T1101). Замена ФН, замена ОФД и
+// изменение реквизитов пользователя совпадают с кодами 1-3 ФФД 1.2.
+const (
+	Reason105Settings = 4 // Изменение настроек ККТ
+)
+
+// reason105 приводит код причины ФФД 1.2 (T1205) к коду ФФД 1.05 (T1101).
+func reason105(reason int) int {
+	switch reason {
+	case ReasonFnReplace, ReasonOfdChange, ReasonUserRequisites:
+		return reason
+	case ReasonAddress:
+		// Адрес и место расчетов в ФФД 1.05 - реквизиты пользователя
+		return ReasonUserRequisites
+	default:
+		return Reason105Settings
+	}
+}
+
+// FieldChange - изменение одного параметра регистрации.
+type FieldChange struct {
+	Field  string // Имя поля RegistrationRequest
+	Tag    string // Тег ФФД
+	Old    string
+	New    string
+	Reason int // Код причины перерегистрации
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s (%s): %q -> %q", c.Field, c.Tag, c.Old, c.New)
+}
+
+// ReregistrationPlan - результат сравнения текущих параметров регистрации с требуемыми.
+type ReregistrationPlan struct {
+	Changes []FieldChange
+	Reasons []int // Коды причин по возрастанию, без повторов
+}
+
+// Empty возвращает true, если перерегистрация не требуется.
+func (p *ReregistrationPlan) Empty() bool {
+	return len(p.Reasons) == 0
+}
+
+// Base возвращает коды причин в формате атрибута BASE ("1,4").
+func (p *ReregistrationPlan) Base() string {
+	parts := make([]string, len(p.Reasons))
+	for i, r := range p.Reasons {
+		parts[i] = strconv.Itoa(r)
+	}
+	return strings.Join(parts, ",")
+}
+
+// NormalizeFFDVersion приводит версию ФФД к значению T1209 ("1.2" -> "4", "1.05" -> "2").
+func NormalizeFFDVersion(v string) string {
+	switch v = strings.TrimSpace(v); v {
+	case "1.05":
+		return "2"
+	case "1.1":
+		return "3"
+	case "1.2", "1.20":
+		return "4"
+	}
+	return v
+}
+
+// PlanReregistration сравнивает текущие параметры регистрации cur с требуемыми req
+// и определяет коды причин перерегистрации по версии ФФД, в которой формируется
+// отчет (req.FfdVer, если не задана - cur.FfdVer): для ФФД 1.2 - коды T1205,
+// для ФФД 1.05 - коды T1101 (1-4). fn - статус установленного ФН (nil -
+// замена ФН не проверяется): ФН считается замененным, если он еще не фискализирован
+// или его номер отличается от cur.FnSerial.
+//
+// ИНН пользователя и РНМ при перерегистрации не меняются: если они заданы в req
+// и отличаются от текущих, возвращается ошибка. Изменение режимов, для которых
+// в ФФД нет отдельного кода (маркировка, подакцизные товары и т.п.), указывается
+// как "иные причины" (32).
+func PlanReregistration(cur *RegData, req RegistrationRequest, fn *FnStatus) (*ReregistrationPlan, error) {
+	if cur == nil {
+		return nil, fmt.Errorf("не заданы текущие параметры регистрации")
+	}
+	if inn := strings.TrimSpace(req.Inn); inn != "" && inn != strings.TrimSpace(cur.Inn) {
+		return nil, fmt.Errorf("ИНН пользователя (%s -> %s) не может быть изменен перерегистрацией, требуется снятие ККТ с учета", cur.Inn, inn)
+	}
+	if regNum := strings.TrimSpace(req.RNM); regNum != "" && regNum != strings.TrimSpace(cur.RNM) {
+		return nil, fmt.Errorf("РНМ (%s -> %s) не может быть изменен перерегистрацией", cur.RNM, regNum)
+	}
+
+	plan := &ReregistrationPlan{}
+	add := func(field, tag, from, to string, reason int) {
+		plan.Changes = append(plan.Changes, FieldChange{Field: field, Tag: tag, Old: from, New: to, Reason: reason})
+	}
+	str := func(field, tag, from, to string, reason int) {
+		if strings.TrimSpace(from) != strings.TrimSpace(to) {
+			add(field, tag, strings.TrimSpace(from), strings.TrimSpace(to), reason)
+		}
+	}
+	flag := func(field, tag string, from, to bool, on, off int) {
+		if from == to {
+			return
+		}
+		reason := off
+		if to {
+			reason = on
+		}
+		add(field, tag, strconv.Itoa(boolAttr(from)), strconv.Itoa(boolAttr(to)), reason)
+	}
+
+	if fn != nil {
+		phase, _ := fn.PhaseCode()
+		if phase == FnPhaseReady || (cur.FnSerial != "" && fn.Serial != "" && fn.Serial != cur.FnSerial) {
+			add("FnSerial", "T1041", cur.FnSerial, fn.Serial, ReasonFnReplace)
+		}
+	}
+
+	// ОФД: замена определяется по ИНН, наименование без смены ИНН - иные причины
+	curOfd, newOfd := strings.Trim(cur.OfdInn, " 0"), strings.Trim(req.OfdInn, " 0")
+	if curOfd != newOfd && newOfd != "" && curOfd != "" {
+		add("OfdInn", "T1017", cur.OfdInn, req.OfdInn, ReasonOfdChange)
+	}
+	if curOfd == newOfd || newOfd == "" || curOfd == "" {
+		str("OfdName", "T1046", cur.OfdName, req.OfdName, ReasonOther)
+	} else {
+		str("OfdName", "T1046", cur.OfdName, req.OfdName, ReasonOfdChange)
+	}
+
+	str("OrgName", "T1048", cur.OrgName, req.OrgName, ReasonUserRequisites)
+	str("SenderEmail", "T1117", cur.EmailSender, req.SenderEmail, ReasonUserRequisites)
+	str("FnsSite", "T1060", cur.Site, req.FnsSite, ReasonUserRequisites)
+	str("Address", "T1009", cur.Address, req.Address, ReasonAddress)
+	str("Place", "T1187", cur.Place, req.Place, ReasonAddress)
+
+	curAutomat := cur.AutoNumTag
+	if curAutomat == "" {
+		curAutomat = cur.AutoNumAttr
+	}
+	str("AutomatNumber", "T1036", curAutomat, req.AutomatNumber, ReasonAutomatNumber)
+
+	if !sameTaxSystems(cur.TaxSystems, req.TaxSystems) {
+		add("TaxSystems", "T1062", cur.TaxSystems, req.TaxSystems, ReasonTaxSystems)
+	}
+	if req.TaxSystemBase != "" {
+		str("TaxSystemBase", "T1062", cur.TaxBase, req.TaxSystemBase, ReasonTaxSystems)
+	}
+	if req.FfdVer != "" && cur.FfdVer != "" {
+		str("FfdVer", "T1209", NormalizeFFDVersion(cur.FfdVer), NormalizeFFDVersion(req.FfdVer), ReasonFFDVersion)
+	}
+
+	was, want := cur.Modes(), req.Modes()
+	// Для автономного режима коды обратные: выключение - переход к передаче данных
+	flag("AutonomousMode", "T1002", was.Autonomous, want.Autonomous, ReasonAutonomousOn, ReasonAutonomousOff)
+	flag("AutomatMode", "T1001", was.Automat, want.Automat, ReasonAutomatOn, ReasonAutomatOff)
+	flag("BSO", "T1110", was.BSO, want.BSO, ReasonBSOOn, ReasonBSOOff)
+	flag("InternetCalc", "T1108", was.Internet, want.Internet, ReasonInternetOn, ReasonInternetOff)
+	flag("Gambling", "T1193", was.Gambling, want.Gambling, ReasonGamblingOn, ReasonGamblingOff)
+	flag("Lottery", "T1126", was.Lottery, want.Lottery, ReasonLotteryOn, ReasonLotteryOff)
+	flag("Encryption", "T1056", was.Encryption, want.Encryption, ReasonOther, ReasonOther)
+	flag("Service", "T1109", was.Services, want.Services, ReasonOther, ReasonOther)
+	flag("Excise", "T1207", was.Excise, want.Excise, ReasonOther, ReasonOther)
+	flag("PrinterAutomat", "T1221", was.PrinterAutomat, want.PrinterAutomat, ReasonOther, ReasonOther)
+	flag("Marking", "MARK", was.Marking, want.Marking, ReasonOther, ReasonOther)
+	flag("PawnShop", "PAWN", was.Pawn, want.Pawn, ReasonOther, ReasonOther)
+	flag("Insurance", "INS", was.Insurance, want.Insurance, ReasonOther, ReasonOther)
+	flag("Catering", "DINE", was.Catering, want.Catering, ReasonOther, ReasonOther)
+	flag("Wholesale", "OPT", was.Wholesale, want.Wholesale, ReasonOther, ReasonOther)
+	flag("Vending", "VEND", was.Vending, want.Vending, ReasonOther, ReasonOther)
+
+	ffdVer := req.FfdVer
+	if strings.TrimSpace(ffdVer) == "" {
+		ffdVer = cur.FfdVer
+	}
+	if !IsFFD12(ffdVer) {
+		for i := range plan.Changes {
+			plan.Changes[i].Reason = reason105(plan.Changes[i].Reason)
+		}
+	}
+
+	seen := map[int]bool{}
+	for _, c := range plan.Changes {
+		if !seen[c.Reason] {
+			seen[c.Reason] = true
+			plan.Reasons = append(plan.Reasons, c.Reason)
+		}
+	}
+	sort.Ints(plan.Reasons)
+	return plan, nil
+}
+
+// Request возвращает запрос перерегистрации с текущими параметрами регистрации.
+func (r *RegData) Request() RegistrationRequest {
+	req := RegistrationRequest{
+		IsReregistration: true,
+		RNM:              r.RNM,
+		Inn:              r.Inn,
+		FfdVer:           r.FfdVer,
+		TaxSystems:       r.TaxSystems,
+		TaxSystemBase:    r.TaxBase,
+		AutomatNumber:    r.AutoNumTag,
+		OrgName:          r.OrgName,
+		Address:          r.Address,
+		Place:            r.Place,
+		OfdName:          r.OfdName,
+		OfdInn:           r.OfdInn,
+		FnsSite:          r.Site,
+		SenderEmail:      r.EmailSender,
+	}
+	if req.AutomatNumber == "" {
+		req.AutomatNumber = r.AutoNumAttr
+	}
+	req.SetModes(r.Modes())
+	return req
+}
+
+// sameTaxSystems сравнивает списки СНО без учета порядка и пробелов.
+func sameTaxSystems(a, b string) bool {
+	split := func(s string) []string {
+		var res []string
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				res = append(res, p)
+			}
+		}
+		sort.Strings(res)
+		return res
+	}
+	return strings.Join(split(a), ",") == strings.Join(split(b), ",")
+}
+
+// ReregisterTo перерегистрирует ККТ по требуемым параметрам: коды причин
+// определяются сравнением с текущими данными регистрации. Если изменений нет,
+// перерегистрация не выполняется и возвращается nil ответ.
+func ReregisterTo(drv Driver, req RegistrationRequest) (*RegResponse, *ReregistrationPlan, error) {
+	cur, err := drv.GetRegistrationData()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения данных регистрации: %w", err)
+	}
+	fn, err := drv.GetFnStatus()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+	}
+	plan, err := PlanReregistration(cur, req, fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan.Empty() {
+		return nil, plan, nil
+	}
+	resp, err := drv.Reregister(req, plan.Reasons)
+	if err != nil {
+		return nil, plan, err
+	}
+	return resp, plan, nil
+}
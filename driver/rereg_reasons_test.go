@@ -0,0 +1,110 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanReregistration(t *testing.T) {
+	cur := &RegData{
+		RNM: "0000000001012345", Inn: "7707083893", FfdVer: "4", TaxSystems: "0,1",
+		ModeMask: 0x01, ExtModeMask: 0x10, // Шифрование, маркировка
+		OrgName: "ООО Ромашка", Address: "г. Москва", Place: "Магазин",
+		OfdName: "ОФД-1", OfdInn: "7704211201", Site: "www.nalog.gov.ru", EmailSender: "kkt@example.ru",
+		FnSerial: "9960440300000001",
+	}
+	req := RegistrationRequest{
+		RNM: cur.RNM, Inn: cur.Inn, FfdVer: "1.2", TaxSystems: "1, 0",
+		Encryption: true, Marking: true,
+		OrgName: cur.OrgName, Address: cur.Address, Place: cur.Place,
+		OfdName: cur.OfdName, OfdInn: cur.OfdInn, FnsSite: cur.Site, SenderEmail: cur.EmailSender,
+	}
+	fn := &FnStatus{Serial: cur.FnSerial, Phase: "0x03"}
+
+	plan, err := PlanReregistration(cur, req, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Fatalf("expected no changes, got %v", plan.Changes)
+	}
+
+	req.Address = "г. Казань"
+	req.OfdName, req.OfdInn = "ОФД-2", "7709364346"
+	req.InternetCalc = true
+	req.Marking = false
+	req.TaxSystems = "2"
+	fn = &FnStatus{Serial: "9960440300000002", Phase: "0x01"}
+
+	plan, err = PlanReregistration(cur, req, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{ReasonFnReplace, ReasonOfdChange, ReasonAddress, ReasonTaxSystems, ReasonInternetOn, ReasonOther}
+	if !reflect.DeepEqual(plan.Reasons, want) {
+		t.Fatalf("unexpected reasons: %v (%v)", plan.Reasons, plan.Changes)
+	}
+	if plan.Base() != "1,2,4,8,15,32" {
+		t.Fatalf("unexpected base: %s", plan.Base())
+	}
+	fields := map[string]bool{}
+	for _, c := range plan.Changes {
+		fields[c.Field] = true
+	}
+	for _, f := range []string{"FnSerial", "OfdInn", "OfdName", "Address", "TaxSystems", "InternetCalc", "Marking"} {
+		if !fields[f] {
+			t.Errorf("change of %s not reported: %v", f, plan.Changes)
+		}
+	}
+}
+
+func TestPlanReregistrationAutonomous(t *testing.T) {
+	cur := &RegData{Inn: "7707083893", FfdVer: "4", TaxSystems: "0", AutonomAttr: "1", OfdInn: "0000000000"}
+	req := RegistrationRequest{TaxSystems: "0", OfdInn: "7704211201"}
+	plan, err := PlanReregistration(cur, req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan.Reasons, []int{ReasonAutonomousOff}) {
+		t.Fatalf("unexpected reasons: %v (%v)", plan.Reasons, plan.Changes)
+	}
+}
+
+func TestPlanReregistrationFFD105(t *testing.T) {
+	cur := &RegData{
+		Inn: "7707083893", FfdVer: "2", TaxSystems: "0", Address: "г. Москва",
+		OfdName: "ОФД-1", OfdInn: "7704211201", FnSerial: "9960440300000001",
+	}
+	req := RegistrationRequest{
+		TaxSystems: "0,1", Address: "г. Казань", InternetCalc: true,
+		OfdName: "ОФД-2", OfdInn: "7709364346",
+	}
+	fn := &FnStatus{Serial: "9960440300000002", Phase: "0x03"}
+	plan, err := PlanReregistration(cur, req, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Base() != "1,2,3,4" {
+		t.Fatalf("unexpected base: %s (%v)", plan.Base(), plan.Changes)
+	}
+
+	// Переход на ФФД 1.2 - отчет формируется с кодами T1205
+	req = RegistrationRequest{FfdVer: "1.2", TaxSystems: "0", OfdName: cur.OfdName, OfdInn: cur.OfdInn, Address: cur.Address}
+	plan, err = PlanReregistration(cur, req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan.Reasons, []int{ReasonFFDVersion}) {
+		t.Fatalf("unexpected reasons: %v (%v)", plan.Reasons, plan.Changes)
+	}
+}
+
+func TestPlanReregistrationRejectsInnChange(t *testing.T) {
+	cur := &RegData{Inn: "7707083893", RNM: "0000000001012345"}
+	if _, err := PlanReregistration(cur, RegistrationRequest{Inn: "500100732259"}, nil); err == nil {
+		t.Fatal("expected error for INN change")
+	}
+	if _, err := PlanReregistration(cur, RegistrationRequest{RNM: "0000000002012345"}, nil); err == nil {
+		t.Fatal("expected error for RNM change")
+	}
+}
@@ -1,5 +1,11 @@
 package driver
 
+import (
+	"time"
+
+	"mitsuscanner/pkg/fndoc"
+)
+
 // FiscalInfo содержит агрегированную информацию о фискальном регистраторе.
 type FiscalInfo struct {
 	ModelName        string `json:"modelName"`
@@ -125,6 +131,7 @@ type RegData struct {
 	AutonomAttr   string `xml:"T1002,attr"` // Автономный
 	EncryptAttr   string `xml:"T1056,attr"` // Шифрование
 	PrintAutoAttr string `xml:"T1221,attr"` // Принтер в автомате
+	AgentAttr     string `xml:"T1057,attr"` // Признак агента (битовая маска)
 
 	// Вложенные теги
 	OrgName     string `xml:"T1048"`
@@ -219,6 +226,16 @@ type CloseFnResult struct {
 	FP string // фискальный признак
 }
 
+// CurrentStateReport содержит результат отчета о текущем состоянии расчетов.
+type CurrentStateReport struct {
+	FD              int    `xml:"FD,attr"` // номер фискального документа
+	FP              string `xml:"FP,attr"` // фискальный признак
+	Unsent          int    // количество неотправленных в ОФД документов
+	FirstUnsent     int    // номер первого неотправленного документа
+	FirstUnsentDate string // дата первого неотправленного документа
+	FirstUnsentTime string // время первого неотправленного документа
+}
+
 // ReportFnCloseData содержит данные для отчета о закрытии фискального архива.
 type ReportFnCloseData struct {
 	DateTime  string
@@ -236,6 +253,7 @@ type RegistrationRequest struct {
 	// Атрибуты (Attributes)
 	IsReregistration bool   `json:"-"` // false = Регистрация, true = Перерегистрация
 	Base             string `json:"-"` // Коды причин перерегистрации (через запятую, напр "1,5")
+	SkipValidation   bool   `json:"-"` // Не проверять параметры (ValidateRegistration) перед отправкой
 
 	RNM            string `json:"rnm"`             // T1037 (Рег. номер)
 	Inn            string `json:"inn"`             // T1018 (ИНН Пользователя)
@@ -276,6 +294,112 @@ type ItemPosition struct {
 	Price    float64 `json:"price"`    // Цена
 	Quantity float64 `json:"quantity"` // Количество
 	Tax      int     `json:"tax"`      // Налоговая ставка
+
+	Agent    *ItemAgent          `json:"agent,omitempty"`    // Агентские реквизиты предмета расчета
+	Industry []IndustryRequisite `json:"industry,omitempty"` // T1260 (Отраслевой реквизит предмета расчета)
+	Mark     *ItemMark           `json:"mark,omitempty"`     // Код маркировки (T2000/T1162)
+}
+
+// MarkItemStatus - планируемый статус товара (T2003).
+type MarkItemStatus int
+
+const (
+	MarkStatusPieceSold        MarkItemStatus = 1   // Штучный товар, реализован
+	MarkStatusMeasuredSold     MarkItemStatus = 2   // Мерный товар, в стадии реализации
+	MarkStatusPieceReturned    MarkItemStatus = 3   // Штучный товар, возвращен
+	MarkStatusMeasuredReturned MarkItemStatus = 4   // Часть товара, возвращена
+	MarkStatusUnchanged        MarkItemStatus = 255 // Статус товара не изменился
+)
+
+// MarkFraction - дробное количество маркированного товара (T1291).
+type MarkFraction struct {
+	Numerator   int `json:"numerator"`   // T1293 (Числитель)
+	Denominator int `json:"denominator"` // T1294 (Знаменатель)
+}
+
+// ItemMark - код маркировки предмета расчета.
+type ItemMark struct {
+	Code     string         `json:"code"`               // T2000 (Код маркировки, как считан сканером)
+	Status   MarkItemStatus `json:"status"`             // T2003 (Планируемый статус товара)
+	Measure  int            `json:"measure"`            // T2108 (Мера количества, 0 - штуки)
+	Fraction *MarkFraction  `json:"fraction,omitempty"` // T1291 (Дробное количество)
+}
+
+// MarkCheckResult содержит результат проверки кода маркировки.
+type MarkCheckResult struct {
+	Result     int  `xml:"RESULT,attr"` // T2106 (Результат проверки сведений о товаре, битовая маска)
+	CodeType   int  `xml:"TYPE,attr"`   // T2100 (Тип кода маркировки)
+	Reason     int  `xml:"REASON,attr"` // Причина, по которой КМ не проверен ФН (0 - проверен)
+	OismCode   int  `xml:"CODE,attr"`   // T2105 (Код обработки запроса ОИСМ)
+	ItemStatus int  `xml:"STATUS,attr"` // T2109 (Ответ ОИСМ о статусе товара)
+	Offline    bool `xml:"-"`           // ОИСМ недоступен, результат получен только от ФН
+}
+
+// IndustryRequisite содержит отраслевой реквизит (T1260 для позиции, T1261 для чека).
+type IndustryRequisite struct {
+	FoivID    string    `json:"foiv_id"`    // T1262 (Идентификатор ФОИВ, "001".."999")
+	DocDate   time.Time `json:"doc_date"`   // T1263 (Дата документа основания)
+	DocNumber string    `json:"doc_number"` // T1264 (Номер документа основания)
+	Value     string    `json:"value"`      // T1265 (Значение отраслевого реквизита)
+}
+
+// OperationalRequisite содержит операционный реквизит чека (T1270).
+type OperationalRequisite struct {
+	OperationID int       `json:"operation_id"` // T1271 (Идентификатор операции, 0..255)
+	Data        string    `json:"data"`         // T1272 (Данные операции)
+	DateTime    time.Time `json:"date_time"`    // T1273 (Дата, время операции)
+}
+
+// AgentType - признак агента (T1057/T1222). Битовая маска.
+type AgentType int
+
+const (
+	AgentBankPaying    AgentType = 1 << 0 // Банковский платежный агент
+	AgentBankSubPaying AgentType = 1 << 1 // Банковский платежный субагент
+	AgentPaying        AgentType = 1 << 2 // Платежный агент
+	AgentSubPaying     AgentType = 1 << 3 // Платежный субагент
+	AgentAttorney      AgentType = 1 << 4 // Поверенный
+	AgentCommission    AgentType = 1 << 5 // Комиссионер
+	AgentOther         AgentType = 1 << 6 // Иной агент
+)
+
+// AgentData содержит данные агента (T1223 для позиции, плоские теги для чека).
+type AgentData struct {
+	Operation               string   `json:"operation,omitempty"`                 // T1044 (Операция платежного агента)
+	AgentPhones             []string `json:"agent_phones,omitempty"`              // T1073 (Телефон платежного агента)
+	OperatorPhones          []string `json:"operator_phones,omitempty"`           // T1074 (Телефон оператора по приему платежей)
+	TransferOperatorPhones  []string `json:"transfer_operator_phones,omitempty"`  // T1075 (Телефон оператора перевода)
+	TransferOperatorName    string   `json:"transfer_operator_name,omitempty"`    // T1026 (Наименование оператора перевода)
+	TransferOperatorAddress string   `json:"transfer_operator_address,omitempty"` // T1005 (Адрес оператора перевода)
+	TransferOperatorInn     string   `json:"transfer_operator_inn,omitempty"`     // T1016 (ИНН оператора перевода)
+}
+
+// SupplierData содержит данные поставщика (T1224).
+type SupplierData struct {
+	Name   string   `json:"name,omitempty"`   // T1225 (Наименование поставщика)
+	Phones []string `json:"phones,omitempty"` // T1171 (Телефон поставщика)
+}
+
+// CheckAgent содержит агентские реквизиты уровня чека.
+type CheckAgent struct {
+	Type           AgentType  `json:"type"`                      // T1057
+	Data           *AgentData `json:"data,omitempty"`            // T1044, T1073-T1075, T1026, T1005, T1016
+	SupplierPhones []string   `json:"supplier_phones,omitempty"` // T1171
+}
+
+// ItemAgent содержит агентские реквизиты предмета расчета.
+type ItemAgent struct {
+	Type        AgentType     `json:"type"`               // T1222 (ровно один бит)
+	Data        *AgentData    `json:"data,omitempty"`     // T1223
+	Supplier    *SupplierData `json:"supplier,omitempty"` // T1224
+	SupplierInn string        `json:"supplier_inn"`       // T1226
+}
+
+// OpenCheckOptions содержит дополнительные реквизиты, передаваемые при открытии чека.
+type OpenCheckOptions struct {
+	Agent       *CheckAgent           `json:"agent,omitempty"`       // Агентские реквизиты чека
+	Industry    []IndustryRequisite   `json:"industry,omitempty"`    // T1261 (Отраслевой реквизит чека)
+	Operational *OperationalRequisite `json:"operational,omitempty"` // T1270 (Операционный реквизит чека)
 }
 
 // PaymentInfo содержит параметры оплаты.
@@ -284,6 +408,27 @@ type PaymentInfo struct {
 	Sum  float64 `json:"sum"`  // Сумма
 }
 
+// CloseCheckOptions содержит параметры закрытия чека.
+type CloseCheckOptions struct {
+	CustomerContact string `json:"customer_contact"` // T1008 (Телефон +7... или e-mail покупателя)
+	NoPrint         bool   `json:"no_print"`         // Не печатать чек (только электронная форма)
+}
+
+// CheckResult содержит результат закрытия чека.
+// Данные используются для формирования ссылки/QR-кода электронного чека.
+type CheckResult struct {
+	FD       int    `xml:"FD,attr"`    // Номер фискального документа
+	FP       string `xml:"FP,attr"`    // Фискальный признак
+	ShiftNum int    `xml:"SHIFT,attr"` // Номер смены
+	CheckNum int    `xml:"NUM,attr"`   // Номер чека за смену
+	Date     string `xml:"DATE,attr"`  // гггг-мм-дд
+	Time     string `xml:"TIME,attr"`  // чч:мм:сс
+	Total    string `xml:"TOTAL,attr"` // Итог чека
+
+	CustomerContact string // T1008, переданный в чек
+	Printed         bool   // Чек был напечатан
+}
+
 // DeviceOptions содержит настройки устройства (b0-b9).
 type DeviceOptions struct {
 	B0 int `xml:"b0,attr"` // Разделители
@@ -298,6 +443,26 @@ type DeviceOptions struct {
 	B9 int `xml:"b9,attr"` // Базовая СНО
 }
 
+// Типы фискальных документов (код формы ФД, атрибут FORM в XML документа из ФН).
+const (
+	DocTypeRegistration      = fndoc.FormRegistration      // Отчет о регистрации
+	DocTypeShiftOpen         = fndoc.FormShiftOpen         // Отчет об открытии смены
+	DocTypeReceipt           = fndoc.FormReceipt           // Кассовый чек
+	DocTypeBSO               = fndoc.FormBSO               // Бланк строгой отчетности
+	DocTypeShiftClose        = fndoc.FormShiftClose        // Отчет о закрытии смены
+	DocTypeFnClose           = fndoc.FormFnClose           // Отчет о закрытии фискального накопителя
+	DocTypeOperatorConfirm   = fndoc.FormOperatorConfirm   // Подтверждение оператора
+	DocTypeReregistration    = fndoc.FormReregistration    // Отчет об изменении параметров регистрации
+	DocTypeCurrentState      = fndoc.FormCurrentState      // Отчет о текущем состоянии расчетов
+	DocTypeCorrectionReceipt = fndoc.FormCorrectionReceipt // Кассовый чек коррекции
+	DocTypeCorrectionBSO     = fndoc.FormCorrectionBSO     // БСО коррекции
+)
+
+// IsReceiptDocType возвращает true для чеков и БСО (включая коррекции).
+func IsReceiptDocType(docType int) bool {
+	return fndoc.IsReceiptForm(docType)
+}
+
 // ReportKind определяет тип отчета.
 type ReportKind string
 
@@ -73,6 +73,18 @@ func TestExtractDocDateTime(t *testing.T) {
 			expected: "01.05.2024 01:35",
 			hasError: false,
 		},
+		{
+			name:     "nested T1012 tag",
+			xmlStr:   `<OK><DocXML FORM="2"><T1012>2023-05-01T01:35</T1012></DocXML></OK>`,
+			expected: "01.05.2023 01:35",
+			hasError: false,
+		},
+		{
+			name:     "not well-formed XML",
+			xmlStr:   `FORM="2"><T1012>2023-05-01T01:35</T1012><T1040>`,
+			expected: "01.05.2023 01:35",
+			hasError: false,
+		},
 		{
 			name:     "missing T1012 tag",
 			xmlStr:   `<DocXML FORM="2"></DocXML>`,
@@ -249,3 +261,49 @@ func TestDecodeHexBlock(t *testing.T) {
 		})
 	}
 }
+
+func TestParseErrorDeviceError(t *testing.T) {
+	err := parseError([]byte(`<ERROR No='37' FSE='22'/>`))
+	if !IsDeviceError(err, "37") {
+		t.Errorf("expected device error #37, got %v", err)
+	}
+	if !IsDeviceError(fmt.Errorf("wrapped: %w", err), "37") {
+		t.Error("expected device error #37 through wrapping")
+	}
+	// Коды ККТ и ФН - разные пространства кодов
+	if IsDeviceError(err, "22") || IsDeviceError(err, "38") {
+		t.Error("unexpected match for code 22 or 38")
+	}
+	if !IsFnError(fmt.Errorf("wrapped: %w", err), "22") || IsFnError(err, "37") {
+		t.Error("unexpected FN error match")
+	}
+	expected := "Ошибка ККТ #37: ошибка: незакрытый документ отсутствует, ошибка ФН #22: ошибка ФН: имеется незакрытый документ"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestFnStatusWarnings(t *testing.T) {
+	s := &FnStatus{Flag: "0x85", Valid: "2026-05-12"}
+	w, err := s.Warnings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !w.Has(FnWarnUrgentReplace|FnWarnMemoryFull|FnWarnCriticalError) || w.Has(FnWarnOfdTimeout) || !w.Critical() {
+		t.Fatalf("unexpected flags: %08b", w)
+	}
+	if len(w.Messages()) != 3 {
+		t.Fatalf("unexpected messages: %v", w.Messages())
+	}
+	if _, err := ParseFnWarnings("zz"); err == nil {
+		t.Fatal("expected error for bad mask")
+	}
+	if w, _ := ParseFnWarnings("08"); w != FnWarnOfdTimeout || w.Critical() {
+		t.Fatalf("unexpected flags: %08b", w)
+	}
+
+	valid, err := s.ValidUntil()
+	if err != nil || valid.Format("2006-01-02") != "2026-05-12" {
+		t.Fatalf("unexpected valid date: %v, %v", valid, err)
+	}
+}
@@ -89,5 +89,51 @@ func parseError(data []byte) error {
 		msg += fmt.Sprintf(" [TAG: %s]", e.TAG)
 	}
 
-	return errors.New(msg)
+	return &DeviceError{Code: e.No, FnCode: e.FSE, Tag: e.TAG, Param: e.PAR, msg: msg}
+}
+
+// DeviceError - логическая ошибка, возвращенная ККТ (<ERROR No='...'/>).
+type DeviceError struct {
+	Code   string // Код ошибки ККТ (No)
+	FnCode string // Код ошибки ФН (FSE)
+	Tag    string // Тег, вызвавший ошибку (TAG)
+	Param  string // Параметр команды (PAR)
+	msg    string
+}
+
+func (e *DeviceError) Error() string {
+	if e.msg == "" {
+		return fmt.Sprintf("ошибка ККТ: код %s", e.Code)
+	}
+	return e.msg
+}
+
+// IsDeviceError проверяет, что err (или обернутая в нее ошибка) является ошибкой ККТ
+// с одним из указанных кодов ККТ (No). Коды ФН проверяет IsFnError.
+func IsDeviceError(err error, codes ...string) bool {
+	var de *DeviceError
+	if !errors.As(err, &de) {
+		return false
+	}
+	for _, c := range codes {
+		if de.Code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFnError проверяет, что err (или обернутая в нее ошибка) является ошибкой ККТ
+// с одним из указанных кодов ошибки ФН (FSE).
+func IsFnError(err error, codes ...string) bool {
+	var de *DeviceError
+	if !errors.As(err, &de) || de.FnCode == "" {
+		return false
+	}
+	for _, c := range codes {
+		if de.FnCode == c {
+			return true
+		}
+	}
+	return false
 }
@@ -4,6 +4,7 @@ go 1.25.1
 
 require (
 	github.com/lxn/walk v0.0.0-20210112085537-c389da54e794
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	go.bug.st/serial v1.6.4
 	golang.org/x/image v0.34.0
 	golang.org/x/net v0.47.0
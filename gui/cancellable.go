@@ -0,0 +1,45 @@
+package gui
+
+import (
+	"context"
+
+	"github.com/lxn/walk"
+)
+
+// cancellableOp - длительная операция, запускаемая кнопкой. Пока операция
+// выполняется, кнопка показывает "Остановить", и повторное нажатие отменяет
+// контекст операции. Методы вызываются из потока интерфейса.
+type cancellableOp struct {
+	btn    *walk.PushButton
+	text   string
+	cancel context.CancelFunc
+}
+
+// toggle запускает run в фоне или, если операция уже выполняется, отменяет ее.
+func (op *cancellableOp) toggle(run func(ctx context.Context)) {
+	if op.cancel != nil {
+		op.cancel()
+		if op.btn != nil {
+			op.btn.SetEnabled(false)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	op.cancel = cancel
+	if op.btn != nil {
+		op.text = op.btn.Text()
+		op.btn.SetText("Остановить")
+	}
+	go func() {
+		defer cancel()
+		run(ctx)
+		mw.Synchronize(func() {
+			op.cancel = nil
+			if op.btn != nil {
+				op.btn.SetText(op.text)
+				op.btn.SetEnabled(true)
+			}
+		})
+	}()
+}
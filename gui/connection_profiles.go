@@ -43,26 +43,30 @@ var (
 	once           sync.Once
 )
 
+// appDir возвращает папку исполняемого файла (при запуске через 'go run' - рабочую папку).
+func appDir() string {
+	exePath, err := os.Executable()
+	if err != nil {
+		log.Printf("[PROFILES] Ошибка получения пути к исполняемому файлу: %v", err)
+		exePath = "." // fallback to current directory
+	}
+	// Обработка запуска через 'go run' (временная папка)
+	if strings.Contains(exePath, "Temp") || strings.Contains(exePath, "go-build") {
+		dir, err := os.Getwd()
+		if err != nil {
+			log.Printf("[PROFILES] Ошибка получения рабочей директории: %v", err)
+			return "."
+		}
+		return dir
+	}
+	return filepath.Dir(exePath)
+}
+
 // initProfilesStorage инициализирует хранилище профилей
 func initProfilesStorage() {
 	once.Do(func() {
 		// Определяем путь к profiles.json рядом с исполняемым файлом
-		exePath, err := os.Executable()
-		if err != nil {
-			log.Printf("[PROFILES] Ошибка получения пути к исполняемому файлу: %v", err)
-			exePath = "." // fallback to current directory
-		}
-		var dir string
-		// Обработка запуска через 'go run' (временная папка)
-		if strings.Contains(exePath, "Temp") || strings.Contains(exePath, "go-build") {
-			dir, err = os.Getwd()
-			if err != nil {
-				log.Printf("[PROFILES] Ошибка получения рабочей директории: %v", err)
-				dir = "."
-			}
-		} else {
-			dir = filepath.Dir(exePath)
-		}
+		dir := appDir()
 		filePath := filepath.Join(dir, "profiles.json")
 
 		profileStorage = &ProfilesStorage{
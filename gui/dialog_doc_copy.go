@@ -0,0 +1,102 @@
+package gui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mitsuscanner/driver"
+
+	"github.com/lxn/walk"
+	d "github.com/lxn/walk/declarative"
+)
+
+// RunDocCopyDialog открывает диалог печати/сохранения копии документа из архива ФН.
+// lastFD - номер последнего документа, подставляется по умолчанию.
+func RunDocCopyDialog(owner walk.Form, drv driver.Driver, lastFD int) {
+	var dlg *walk.Dialog
+	var fdEdit *walk.NumberEdit
+	var closePB *walk.PushButton
+
+	currentFD := func() int { return int(fdEdit.Value()) }
+
+	onPrint := func() {
+		fd := currentFD()
+		go func() {
+			var err error
+			if fd == lastFD {
+				// Последний документ печатается штатной копией ККТ
+				err = drv.PrintLastDocument()
+			} else {
+				err = drv.PrintDocumentCopy(fd)
+			}
+			if err != nil {
+				dlg.Synchronize(func() { walk.MsgBox(dlg, "Ошибка", err.Error(), walk.MsgBoxIconError) })
+			}
+		}()
+	}
+
+	onSave := func() {
+		fd := currentFD()
+		fileDlg := new(walk.FileDialog)
+		fileDlg.FilePath = fmt.Sprintf("FD_%d.html", fd)
+		fileDlg.Filter = "HTML (*.html)|*.html|Text Files (*.txt)|*.txt"
+		fileDlg.Title = "Сохранить копию документа"
+		if home, err := os.UserHomeDir(); err == nil {
+			fileDlg.InitialDirPath = filepath.Join(home, "Documents")
+		}
+		if ok, _ := fileDlg.ShowSave(dlg); !ok {
+			return
+		}
+		format := driver.CopyHTML
+		if strings.EqualFold(filepath.Ext(fileDlg.FilePath), ".txt") {
+			format = driver.CopyText
+		}
+		path := fileDlg.FilePath
+		go func() {
+			text, err := drv.GetDocumentCopy(fd, format)
+			if err == nil {
+				err = os.WriteFile(path, []byte(text), 0644)
+			}
+			dlg.Synchronize(func() {
+				if err != nil {
+					walk.MsgBox(dlg, "Ошибка", err.Error(), walk.MsgBoxIconError)
+					return
+				}
+				walk.MsgBox(dlg, "Успех", "Файл успешно сохранен.", walk.MsgBoxIconInformation)
+			})
+		}()
+	}
+
+	err := d.Dialog{
+		AssignTo:     &dlg,
+		Title:        "Копия документа из ФН",
+		MinSize:      d.Size{Width: 300, Height: 120},
+		Layout:       d.VBox{},
+		CancelButton: &closePB,
+		Children: []d.Widget{
+			d.Composite{
+				Layout: d.HBox{MarginsZero: true},
+				Children: []d.Widget{
+					d.Label{Text: "Номер ФД:"},
+					d.NumberEdit{AssignTo: &fdEdit, Value: float64(lastFD), MinValue: 1, MaxValue: 4294967295, Decimals: 0},
+				},
+			},
+			d.Composite{
+				Layout: d.HBox{MarginsZero: true},
+				Children: []d.Widget{
+					d.PushButton{Text: "Печать", OnClicked: onPrint},
+					d.PushButton{Text: "Сохранить...", OnClicked: onSave},
+					d.HSpacer{},
+					d.PushButton{AssignTo: &closePB, Text: "Закрыть", OnClicked: func() { dlg.Cancel() }},
+				},
+			},
+		},
+	}.Create(owner)
+	if err != nil {
+		walk.MsgBox(owner, "Ошибка", err.Error(), walk.MsgBoxIconError)
+		return
+	}
+	dlg.Run()
+}
@@ -0,0 +1,174 @@
+package gui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/ofdcatalog"
+
+	"github.com/lxn/walk"
+	d "github.com/lxn/walk/declarative"
+)
+
+// openOfdCatalog загружает справочник ОФД (ofd_catalog.json рядом с программой
+// исправляет и дополняет встроенный справочник).
+func openOfdCatalog(owner walk.Form) *ofdcatalog.Catalog {
+	c, err := ofdcatalog.LoadWithOverride(filepath.Join(appDir(), "ofd_catalog.json"))
+	if err != nil && owner != nil {
+		walk.MsgBox(owner, "Справочник ОФД", err.Error()+"\nИспользуется встроенный справочник.", walk.MsgBoxIconWarning)
+	}
+	return c
+}
+
+// onSelectOfd заполняет реквизиты ОФД на вкладке регистрации из справочника и
+// предлагает записать адрес сервера ОФД в настройки ККТ.
+func onSelectOfd() {
+	if err := regBinder.Submit(); err != nil {
+		return
+	}
+	catalog := openOfdCatalog(mw)
+	p, ok := RunOfdCatalogDialog(mw, catalog, regModel.OFDINN)
+	if !ok {
+		return
+	}
+	regModel.OFDINN = p.INN
+	regModel.OFDName = p.Name
+	if p.FnsSite != "" {
+		regModel.Site = p.FnsSite
+	}
+	if err := regBinder.Reset(); err != nil {
+		fmt.Println("Binder reset error:", err)
+	}
+
+	drv := driver.Active
+	if drv == nil {
+		return
+	}
+	msg := fmt.Sprintf("Записать адрес сервера ОФД %s:%d в настройки ККТ?", p.Host, p.Port)
+	if walk.MsgBox(mw, "Справочник ОФД", msg, walk.MsgBoxYesNo|walk.MsgBoxIconQuestion) != walk.DlgCmdYes {
+		return
+	}
+	go func() {
+		settings, err := drv.GetOfdSettings()
+		if err == nil {
+			p.ApplySettings(settings)
+			err = drv.SetOfdSettings(*settings)
+		}
+		mw.Synchronize(func() {
+			if err != nil {
+				walk.MsgBox(mw, "Ошибка", "Ошибка записи настроек ОФД: "+err.Error(), walk.MsgBoxIconError)
+				return
+			}
+			logMsg("Настройки ОФД: %s:%d (%s)", p.Host, p.Port, p.Title())
+		})
+	}()
+}
+
+// confirmOfdConsistency сверяет настройки ОФД в ККТ с реквизитами регистрации.
+// При расхождениях спрашивает оператора, продолжать ли. Вызывается не из UI-потока.
+func confirmOfdConsistency(drv driver.Driver, req driver.RegistrationRequest) bool {
+	settings, err := drv.GetOfdSettings()
+	if err != nil {
+		logMsg("Не удалось прочитать настройки ОФД: %v", err)
+		return true
+	}
+	mismatches := openOfdCatalog(nil).Check(*settings, req)
+	if len(mismatches) == 0 {
+		return true
+	}
+	lines := make([]string, len(mismatches))
+	for i, m := range mismatches {
+		lines[i] = "• " + m.Message
+	}
+	res := make(chan int, 1)
+	mw.Synchronize(func() {
+		res <- walk.MsgBox(mw, "Проверка ОФД", "Настройки ОФД не соответствуют реквизитам регистрации:\n\n"+strings.Join(lines, "\n")+"\n\nПродолжить?", walk.MsgBoxYesNo|walk.MsgBoxIconWarning)
+	})
+	return <-res == walk.DlgCmdYes
+}
+
+// RunOfdCatalogDialog показывает справочник ОФД. currentINN - ИНН ОФД, выделяемого при открытии.
+func RunOfdCatalogDialog(owner walk.Form, catalog *ofdcatalog.Catalog, currentINN string) (ofdcatalog.Provider, bool) {
+	var dlg *walk.Dialog
+	var listBox *walk.ListBox
+	var infoLabel *walk.Label
+	var selectPB, cancelPB *walk.PushButton
+
+	providers := catalog.List()
+	titles := make([]string, len(providers))
+	current := -1
+	for i, p := range providers {
+		titles[i] = p.Title()
+		if p.INN == strings.TrimSpace(currentINN) {
+			current = i
+		}
+	}
+	var selected ofdcatalog.Provider
+	selectCurrent := func() bool {
+		idx := listBox.CurrentIndex()
+		if idx < 0 || idx >= len(providers) {
+			return false
+		}
+		selected = providers[idx]
+		return true
+	}
+
+	err := d.Dialog{
+		AssignTo:      &dlg,
+		Title:         "Справочник ОФД",
+		MinSize:       d.Size{Width: 460, Height: 360},
+		Layout:        d.VBox{},
+		DefaultButton: &selectPB,
+		CancelButton:  &cancelPB,
+		Children: []d.Widget{
+			d.ListBox{
+				AssignTo: &listBox,
+				Model:    titles,
+				OnCurrentIndexChanged: func() {
+					if selectCurrent() {
+						infoLabel.SetText(fmt.Sprintf("ИНН: %s   Сервер: %s:%d   Сайт ФНС: %s", selected.INN, selected.Host, selected.Port, selected.FnsSite))
+					}
+				},
+				OnItemActivated: func() {
+					if selectCurrent() {
+						dlg.Accept()
+					}
+				},
+			},
+			d.Label{AssignTo: &infoLabel},
+			d.Composite{
+				Layout: d.HBox{MarginsZero: true},
+				Children: []d.Widget{
+					d.HSpacer{},
+					d.PushButton{
+						AssignTo: &selectPB,
+						Text:     "Выбрать",
+						OnClicked: func() {
+							if selectCurrent() {
+								dlg.Accept()
+							}
+						},
+					},
+					d.PushButton{
+						AssignTo:  &cancelPB,
+						Text:      "Отмена",
+						OnClicked: func() { dlg.Cancel() },
+					},
+				},
+			},
+		},
+	}.Create(owner)
+	if err != nil {
+		fmt.Println("Error creating dialog:", err)
+		return ofdcatalog.Provider{}, false
+	}
+	if current >= 0 {
+		listBox.SetCurrentIndex(current)
+	}
+	if dlg.Run() == walk.DlgCmdOK && selected.INN != "" {
+		return selected, true
+	}
+	return ofdcatalog.Provider{}, false
+}
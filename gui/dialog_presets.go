@@ -0,0 +1,209 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/internal/service"
+
+	"github.com/lxn/walk"
+	d "github.com/lxn/walk/declarative"
+)
+
+// openPresetStore открывает пресеты регистрации (reg_presets.json рядом с программой).
+// Некорректные пресеты пропускаются с предупреждением.
+func openPresetStore(owner walk.Form) (*service.PresetStore, error) {
+	store, err := service.OpenPresetStore(filepath.Join(appDir(), "reg_presets.json"))
+	var loadErr *service.PresetLoadError
+	if errors.As(err, &loadErr) {
+		walk.MsgBox(owner, "Пресеты регистрации", loadErr.Error(), walk.MsgBoxIconWarning)
+		return store, nil
+	}
+	return store, err
+}
+
+// onRegPresets открывает библиотеку пресетов регистрации и заполняет форму
+// выбранным пресетом (РНМ и номер автомата из формы сохраняются).
+func onRegPresets() {
+	if err := regBinder.Submit(); err != nil {
+		return
+	}
+	store, err := openPresetStore(mw)
+	if err != nil {
+		walk.MsgBox(mw, "Ошибка", err.Error(), walk.MsgBoxIconError)
+		return
+	}
+	preset, ok := RunPresetsDialog(mw, store, fillRequestFromModel(false))
+	if !ok {
+		return
+	}
+	req := preset.Build(service.DeviceFields{RNM: regModel.RNM, AutomatNumber: regModel.AutomatNumber})
+	applyRequestToModel(req)
+	if err := regBinder.Reset(); err != nil {
+		fmt.Println("Binder reset error:", err)
+	}
+}
+
+// RunPresetsDialog показывает список пресетов. current - параметры формы для
+// сохранения в новый пресет. Возвращает выбранный для загрузки пресет.
+func RunPresetsDialog(owner walk.Form, store *service.PresetStore, current driver.RegistrationRequest) (*service.RegPreset, bool) {
+	var dlg *walk.Dialog
+	var listBox *walk.ListBox
+	var nameEdit *walk.LineEdit
+	var loadPB, closePB *walk.PushButton
+	var selected *service.RegPreset
+
+	names := func() []string {
+		var res []string
+		for _, p := range store.List() {
+			res = append(res, p.Name)
+		}
+		return res
+	}
+	refresh := func() {
+		listBox.SetModel(names())
+	}
+	selectedName := func() string {
+		idx := listBox.CurrentIndex()
+		list := names()
+		if idx < 0 || idx >= len(list) {
+			return ""
+		}
+		return list[idx]
+	}
+
+	err := d.Dialog{
+		AssignTo:      &dlg,
+		Title:         "Пресеты регистрации",
+		MinSize:       d.Size{Width: 420, Height: 360},
+		Layout:        d.VBox{},
+		DefaultButton: &loadPB,
+		CancelButton:  &closePB,
+		Children: []d.Widget{
+			d.ListBox{
+				AssignTo: &listBox,
+				Model:    names(),
+				OnCurrentIndexChanged: func() {
+					if name := selectedName(); name != "" {
+						nameEdit.SetText(name)
+					}
+				},
+				OnItemActivated: func() {
+					if selected = store.Get(selectedName()); selected != nil {
+						dlg.Accept()
+					}
+				},
+			},
+			d.Composite{
+				Layout: d.HBox{MarginsZero: true},
+				Children: []d.Widget{
+					d.Label{Text: "Имя:"},
+					d.LineEdit{AssignTo: &nameEdit},
+					d.PushButton{
+						Text: "Сохранить текущие",
+						OnClicked: func() {
+							err := store.Put(service.RegPreset{Name: nameEdit.Text(), Request: current})
+							if err != nil {
+								walk.MsgBox(dlg, "Ошибка", err.Error(), walk.MsgBoxIconError)
+								return
+							}
+							refresh()
+						},
+					},
+				},
+			},
+			d.Composite{
+				Layout: d.HBox{MarginsZero: true},
+				Children: []d.Widget{
+					d.PushButton{
+						Text: "Удалить",
+						OnClicked: func() {
+							name := selectedName()
+							if name == "" {
+								return
+							}
+							if walk.MsgBox(dlg, "Подтверждение", fmt.Sprintf("Удалить пресет %q?", name), walk.MsgBoxYesNo|walk.MsgBoxIconQuestion) != walk.DlgCmdYes {
+								return
+							}
+							if err := store.Delete(name); err != nil {
+								walk.MsgBox(dlg, "Ошибка", err.Error(), walk.MsgBoxIconError)
+							}
+							refresh()
+						},
+					},
+					d.PushButton{
+						Text: "Импорт...",
+						OnClicked: func() {
+							fd := new(walk.FileDialog)
+							fd.Filter = "JSON (*.json)|*.json"
+							fd.Title = "Импорт пресетов"
+							if ok, _ := fd.ShowOpen(dlg); !ok {
+								return
+							}
+							f, err := os.Open(fd.FilePath)
+							if err != nil {
+								walk.MsgBox(dlg, "Ошибка", err.Error(), walk.MsgBoxIconError)
+								return
+							}
+							defer f.Close()
+							n, err := store.Import(f, true)
+							refresh()
+							if err != nil {
+								walk.MsgBox(dlg, "Импорт пресетов", fmt.Sprintf("Импортировано: %d\n%v", n, err), walk.MsgBoxIconWarning)
+								return
+							}
+							walk.MsgBox(dlg, "Импорт пресетов", fmt.Sprintf("Импортировано: %d", n), walk.MsgBoxIconInformation)
+						},
+					},
+					d.PushButton{
+						Text: "Экспорт...",
+						OnClicked: func() {
+							fd := new(walk.FileDialog)
+							fd.FilePath = "reg_presets_export.json"
+							fd.Filter = "JSON (*.json)|*.json"
+							fd.Title = "Экспорт пресетов"
+							if ok, _ := fd.ShowSave(dlg); !ok {
+								return
+							}
+							f, err := os.Create(fd.FilePath)
+							if err != nil {
+								walk.MsgBox(dlg, "Ошибка", err.Error(), walk.MsgBoxIconError)
+								return
+							}
+							defer f.Close()
+							if err := store.Export(f); err != nil {
+								walk.MsgBox(dlg, "Ошибка", err.Error(), walk.MsgBoxIconError)
+							}
+						},
+					},
+					d.HSpacer{},
+					d.PushButton{
+						AssignTo: &loadPB,
+						Text:     "Загрузить",
+						OnClicked: func() {
+							if selected = store.Get(selectedName()); selected != nil {
+								dlg.Accept()
+							}
+						},
+					},
+					d.PushButton{
+						AssignTo:  &closePB,
+						Text:      "Закрыть",
+						OnClicked: func() { dlg.Cancel() },
+					},
+				},
+			},
+		},
+	}.Create(owner)
+	if err != nil {
+		fmt.Println("Error creating dialog:", err)
+		return nil, false
+	}
+	if dlg.Run() == walk.DlgCmdOK && selected != nil {
+		return selected, true
+	}
+	return nil, false
+}
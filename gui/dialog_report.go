@@ -96,20 +96,10 @@ func buildRegistrationLines(regData driver.RegData) []kv {
 	lines = append(lines, kv{"Наименование ОФД", regData.OfdName})
 	lines = append(lines, kv{"Коды причин регистрации", regData.Base})
 
-	// Флаги — добавляем только если есть
-	appendIfNotEmpty(&lines, "Маркировка товаров", regData.MarkAttr)
-	appendIfNotEmpty(&lines, "Подакцизные товары", regData.ExciseAttr)
-	appendIfNotEmpty(&lines, "Расчеты в сети Интернет", regData.InternetAttr)
-	appendIfNotEmpty(&lines, "Услуги", regData.ServiceAttr)
-	appendIfNotEmpty(&lines, "БСО", regData.BsoAttr)
-	appendIfNotEmpty(&lines, "Лотерея", regData.LotteryAttr)
-	appendIfNotEmpty(&lines, "Азартные игры", regData.GamblingAttr)
-	appendIfNotEmpty(&lines, "Ломбард", regData.PawnAttr)
-	appendIfNotEmpty(&lines, "Страхование", regData.InsAttr)
-	appendIfNotEmpty(&lines, "Общепит", regData.DineAttr)
-	appendIfNotEmpty(&lines, "Оптовая торговля", regData.OptAttr)
-	appendIfNotEmpty(&lines, "Вендинг", regData.VendAttr)
-	appendIfNotEmpty(&lines, "Автоматический режим", regData.AutoModeAttr)
+	// Режимы работы — добавляем только включенные
+	for _, label := range regData.Modes().Labels(driver.LabelsRU) {
+		lines = append(lines, kv{label, "да"})
+	}
 	appendIfNotEmpty(&lines, "Номер автомата", regData.AutoNumAttr)
 
 	return lines
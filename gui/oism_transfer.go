@@ -0,0 +1,53 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mitsuscanner/driver"
+	"mitsuscanner/internal/service"
+	"time"
+)
+
+// oismDrainOptions - параметры отправки уведомлений в ОИСМ из интерфейса:
+// журнал клиента и прогресс пишутся в лог.
+func oismDrainOptions() service.OismDrainOptions {
+	return service.OismDrainOptions{
+		Logger: func(msg string) {
+			log.Printf("[OISMClient] %s", msg)
+		},
+		OnProgress: func(p service.OismDrainProgress) {
+			log.Printf("[OISM] Уведомление %d/%d отправлено (%d байт)", p.Number, p.Total, p.Size)
+		},
+	}
+}
+
+// SendFirstUnsentNotice отправляет в ОИСМ первое непереданное уведомление
+// о реализации маркированного товара и записывает квитанцию в ФН.
+func SendFirstUnsentNotice(drv driver.Driver) (*OfdTransferResult, error) {
+	opts := oismDrainOptions()
+	opts.Limit = 1
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+
+	summary, err := service.DrainOismQueue(ctx, drv, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &OfdTransferResult{Success: true, DocumentsSent: summary.Sent}
+	if summary.Sent == 0 {
+		result.ErrorMessage = "Нет непереданных уведомлений"
+	} else {
+		result.ErrorMessage = fmt.Sprintf("Уведомление отправлено в ОИСМ.\nОсталось непереданных: %d", summary.Remaining)
+	}
+	return result, nil
+}
+
+// oismDrainReport формирует текст итога отправки уведомлений в ОИСМ.
+func oismDrainReport(s *service.OismDrainSummary) string {
+	if s.Initial == 0 {
+		return "Нет непереданных уведомлений"
+	}
+	return fmt.Sprintf("Отправлено уведомлений: %d из %d\nОсталось непереданных: %d\nВремя: %s",
+		s.Sent, s.Initial, s.Remaining, s.Elapsed.Round(time.Second))
+}
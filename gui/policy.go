@@ -0,0 +1,68 @@
+package gui
+
+import (
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+
+	"mitsuscanner/driver"
+)
+
+var (
+	auditOnce   sync.Once
+	auditRecord func(driver.AuditRecord)
+)
+
+// auditLog возвращает функцию записи журнала опасных операций (audit.jsonl рядом с программой).
+func auditLog() func(driver.AuditRecord) {
+	auditOnce.Do(func() {
+		path := filepath.Join(appDir(), "audit.jsonl")
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Printf("[AUDIT] Ошибка открытия журнала %s: %v", path, err)
+			auditRecord = func(r driver.AuditRecord) {
+				log.Printf("[AUDIT] %s %s %s %s", r.Event, r.Operation, r.Approver, r.Error)
+			}
+			return
+		}
+		auditRecord = driver.JSONAuditWriter(f)
+	})
+	return auditRecord
+}
+
+// operatorName возвращает имя пользователя ОС для журнала.
+func operatorName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "Оператор"
+}
+
+// wrapWithPolicy оборачивает драйвер сервисной политикой: операции высокого
+// риска выполняются только после подтверждения в диалогах программы.
+func wrapWithPolicy(drv driver.Driver) driver.Driver {
+	return driver.NewPolicyDriver(drv, driver.EngineerPolicy(), driver.Principal{Name: operatorName(), Role: "engineer"}, auditLog())
+}
+
+// approveOp подтверждает операцию высокого риска от имени оператора, ответившего
+// "Да" в диалоге. Подтверждение действует только для возвращенного драйвера
+// (см. PolicyDriver.WithApproval); функция отзывает его, если оно не было использовано.
+func approveOp(drv driver.Driver, op driver.Operation, reason string) (driver.Driver, func()) {
+	var approved *driver.ApprovedDriver
+	switch d := drv.(type) {
+	case *driver.ApprovedDriver:
+		approved = d
+	case *driver.PolicyDriver:
+		approved = d.WithApproval()
+	default:
+		return drv, func() {}
+	}
+	token, err := approved.Approve(op, operatorName(), reason)
+	if err != nil {
+		log.Printf("[AUDIT] Ошибка подтверждения %s: %v", op, err)
+		return approved, func() {}
+	}
+	return approved, func() { approved.Revoke(token) }
+}
@@ -13,6 +13,7 @@ import (
 	"go.bug.st/serial"
 
 	"mitsuscanner/driver"
+	"mitsuscanner/internal/service"
 )
 
 // Global state
@@ -541,7 +542,8 @@ func onActionBtnClicked() {
 	setControlsEnabled(false)
 
 	go func() {
-		drv := driver.NewMitsuDriver(cfg)
+		// Операции высокого риска выполняются только после подтверждения в диалогах
+		drv := wrapWithPolicy(driver.NewMitsuDriver(cfg))
 		if err := drv.Connect(); err != nil {
 			mw.Synchronize(func() {
 				logMsg("ОШИБКА: %v", err)
@@ -574,6 +576,9 @@ func getConnString(c *driver.Config) string {
 	return fmt.Sprintf("%s:%d", c.IPAddress, c.TCPPort)
 }
 
+// fnForecaster накапливает замеры номера ФД за сеанс работы для прогноза окончания ФН.
+var fnForecaster = service.NewFnForecaster(service.FnForecastConfig{})
+
 // --- Утилиты ---
 func refreshInfo() {
 	drv := driver.Active
@@ -608,6 +613,18 @@ func refreshInfo() {
 		lines = append(lines, kv{"Срок действия ФН", info.FnEndDate})
 		lines = append(lines, kv{"Исполнение ФН", info.FnEdition})
 
+		// Предупреждения ФН и прогноз окончания работы
+		if forecast, err := fnForecaster.Poll(drv); err == nil {
+			warn := forecast.Flags.String()
+			if forecast.Horizon > 0 {
+				warn = strings.Join(forecast.Warnings, "; ")
+			}
+			lines = append(lines, kv{"Предупреждения ФН", warn})
+			if !forecast.EndAt.IsZero() {
+				lines = append(lines, kv{"Прогноз окончания ФН", fmt.Sprintf("%s (%s)", forecast.EndAt.Format("02.01.2006"), forecast.EndReason)})
+			}
+		}
+
 		sh, err := drv.GetShiftStatus()
 		if err == nil {
 			st := "Закрыта"
@@ -629,6 +646,13 @@ func refreshInfo() {
 			lines = append(lines, kv{"Смена", "Ошибка получения статуса"})
 		}
 
+		// Итоги смены: внесения, изъятия и остаток наличных
+		if totals, err := drv.GetShiftTotals(); err == nil {
+			lines = append(lines, kv{"Внесения", fmt.Sprintf("%s (%s оп.)", totals.Income.Total, totals.Income.Count)})
+			lines = append(lines, kv{"Изъятия", fmt.Sprintf("%s (%s оп.)", totals.Payout.Total, totals.Payout.Count)})
+			lines = append(lines, kv{"Наличные в кассе", totals.Cash.Total})
+		}
+
 		var sb strings.Builder
 		maxKeyLen := 0
 		for _, item := range lines {
@@ -671,9 +695,15 @@ func onPrintZ() {
 	}
 }
 func onPrintCopy() {
-	if driver.Active != nil {
-		go driver.Active.PrintLastDocument()
+	drv := driver.Active
+	if drv == nil {
+		return
+	}
+	lastFD := 0
+	if fn, err := drv.GetFnStatus(); err == nil {
+		lastFD = fn.LastFD
 	}
+	RunDocCopyDialog(mw, drv, lastFD)
 }
 func onFeedAndCut() {
 	if driver.Active != nil {
@@ -1,9 +1,15 @@
 package gui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html"
 	"mitsuscanner/driver"
+	"mitsuscanner/internal/service"
+	"mitsuscanner/pkg/rnm"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -12,11 +18,6 @@ import (
 	d "github.com/lxn/walk/declarative"
 )
 
-// hasBit проверяет, установлен ли бит в целом числе
-func hasBit(value int, bit int) bool {
-	return (value & (1 << bit)) != 0
-}
-
 // RegViewModel - модель данных для формы регистрации
 type RegViewModel struct {
 	RNM           string
@@ -222,10 +223,18 @@ func GetRegistrationTab() d.TabPage {
 										Layout: d.HBox{MarginsZero: true, Spacing: 5, Alignment: d.AlignHCenterVCenter},
 										Children: []d.Widget{
 											d.PushButton{
+												AssignTo:    &sendOfdOp.btn,
 												Text:        "Отправить в ОФД",
 												OnClicked:   onSendToOfd,
 												MinSize:     d.Size{Width: 110},
-												ToolTipText: "Отправить первый неотправленный документ в ОФД",
+												ToolTipText: "Отправить все неотправленные документы в ОФД",
+											},
+											d.PushButton{
+												AssignTo:    &sendOismOp.btn,
+												Text:        "Отправить в ОИСМ",
+												OnClicked:   onSendToOism,
+												MinSize:     d.Size{Width: 110},
+												ToolTipText: "Отправить непереданные уведомления о реализации маркированных товаров в ОИСМ",
 											},
 											d.PushButton{
 												Text:        "↻", // Unicode символ обновления
@@ -259,6 +268,7 @@ func GetRegistrationTab() d.TabPage {
 								Children: []d.Widget{
 									d.Label{Text: "ИНН ОФД:", TextAlignment: d.AlignFar},
 									d.LineEdit{Text: d.Bind("OFDINN")},
+									d.PushButton{Text: "Выбрать...", OnClicked: onSelectOfd},
 								},
 							},
 							d.Composite{
@@ -290,6 +300,12 @@ func GetRegistrationTab() d.TabPage {
 										MinSize:   d.Size{Width: 100}, // Фиксируем размер кнопки
 										MaxSize:   d.Size{Width: 100},
 									},
+									d.PushButton{
+										Text:      "Определить",
+										OnClicked: onDetectReasons,
+										MinSize:   d.Size{Width: 100},
+										MaxSize:   d.Size{Width: 100},
+									},
 								},
 							},
 						},
@@ -302,6 +318,7 @@ func GetRegistrationTab() d.TabPage {
 				Layout: d.HBox{Margins: d.Margins{Left: 8, Top: 8, Right: 8, Bottom: 8}, Spacing: 5},
 				Children: []d.Widget{
 					d.PushButton{Text: "Считать из ККТ", OnClicked: onReadRegistration},
+					d.PushButton{Text: "Пресеты...", OnClicked: onRegPresets},
 					d.HSpacer{},
 					d.PushButton{Text: "Закрытие ФН", OnClicked: onCloseFn},
 					d.PushButton{Text: "Замена ФН", OnClicked: onReplaceFn},
@@ -364,68 +381,10 @@ func onReadRegistration() {
 
 		mw.Synchronize(func() {
 
+			req := regData.Request()
+			req.OfdName = html.UnescapeString(req.OfdName)
+			applyRequestToModel(req)
 			regModel.RNM = regData.RNM
-			regModel.INN = regData.Inn
-			regModel.OrgName = regData.OrgName
-			regModel.Address = regData.Address
-			regModel.Place = regData.Place
-			regModel.Email = regData.EmailSender
-			regModel.Site = regData.Site
-			regModel.OFDINN = regData.OfdInn
-			regModel.OFDName = html.UnescapeString(regData.OfdName)
-
-			// --- Парсинг атрибутов режимов работы ---
-
-			// Парсинг MODE и ExtMODE с использованием hasBit
-			modeInt := int(regData.ModeMask)
-			extModeInt := int(regData.ExtModeMask)
-
-			regModel.ModeEncryption = hasBit(modeInt, 0) // Шифрование
-			regModel.ModeAutonomous = hasBit(modeInt, 1) // Автономный режим
-			regModel.ModeService = hasBit(modeInt, 3)    // Услуги
-			regModel.ModeBSO = hasBit(modeInt, 4)        // БСО
-			regModel.ModeInternet = hasBit(modeInt, 5)   // Интернет
-			regModel.ModeCatering = hasBit(modeInt, 6)   // Общепит
-			regModel.ModeWholesale = hasBit(modeInt, 7)  // Опт
-
-			regModel.ModeExcise = hasBit(extModeInt, 0)    // Подакцизные
-			regModel.ModeGambling = hasBit(extModeInt, 1)  // Азартные
-			regModel.ModeLottery = hasBit(extModeInt, 2)   // Лотереи
-			regModel.ModeAutomat = hasBit(extModeInt, 3)   // Принтер в автомате
-			regModel.ModeMarking = hasBit(extModeInt, 4)   // Маркированные товары
-			regModel.ModePawn = hasBit(extModeInt, 5)      // Ломбард
-			regModel.ModeInsurance = hasBit(extModeInt, 6) // Страхование
-			regModel.ModeVending = hasBit(extModeInt, 7)   // Вендинг
-
-			// Парсинг СНО
-			regModel.TaxOSN = false
-			regModel.TaxUSN = false
-			regModel.TaxUSN_M = false
-			regModel.TaxENVD = false
-			regModel.TaxESHN = false
-			regModel.TaxPat = false
-
-			taxParts := strings.Split(regData.TaxSystems, ",")
-			for _, t := range taxParts {
-				trimmedT := strings.TrimSpace(t)
-				switch trimmedT {
-				case "0":
-					regModel.TaxOSN = true
-				case "1":
-					regModel.TaxUSN = true
-				case "2":
-					regModel.TaxUSN_M = true
-				case "3":
-					regModel.TaxENVD = true
-				case "4":
-					regModel.TaxESHN = true
-				case "5":
-					regModel.TaxPat = true
-				}
-			}
-
-			// Установка TaxSystemBase на первую зарегистрированную СНО по умолчанию
-			regModel.TaxSystemBase = regData.TaxBase
 
 			if err := regBinder.Reset(); err != nil {
 				walk.MsgBox(mw, "Ошибка биндинга", fmt.Sprintf("Ошибка обновления UI: %v", err), walk.MsgBoxIconError)
@@ -494,7 +453,12 @@ func onRegister() {
 		if err := drv.SetCashier("Администратор", ""); err != nil {
 			return
 		}
-		resp, err := drv.Register(req)
+		if !confirmOfdConsistency(drv, req) {
+			return
+		}
+		approved, revoke := approveOp(drv, driver.OpRegister, "регистрация ККТ")
+		defer revoke()
+		resp, err := approved.Register(req)
 		if err != nil {
 			mw.Synchronize(func() { walk.MsgBox(mw, "Ошибка регистрации", err.Error(), walk.MsgBoxIconError) })
 			return
@@ -575,7 +539,12 @@ func onReregister() {
 		if err := drv.SetCashier("Администратор", ""); err != nil {
 			return
 		}
-		_, err := drv.Reregister(req, reasons)
+		if !confirmOfdConsistency(drv, req) {
+			return
+		}
+		approved, revoke := approveOp(drv, driver.OpReregister, "перерегистрация ККТ, причины "+regModel.Reasons)
+		defer revoke()
+		_, err := approved.Reregister(req, reasons)
 		if err != nil {
 			mw.Synchronize(func() {
 				walk.MsgBox(mw, "Ошибка перерегистрации", err.Error(), walk.MsgBoxIconError)
@@ -614,7 +583,9 @@ func onSelectReasons() {
 	}
 }
 
-func onReplaceFn() {
+// onDetectReasons определяет причины перерегистрации, сравнивая параметры в форме
+// с текущими данными регистрации ККТ.
+func onDetectReasons() {
 	drv := driver.Active
 	if drv == nil {
 		return
@@ -622,24 +593,106 @@ func onReplaceFn() {
 	if err := regBinder.Submit(); err != nil {
 		return
 	}
-
-	req := fillRequestFromModel(false)
-	reasons := []int{1}
+	req := fillRequestFromModel(true)
 
 	go func() {
-		if err := drv.SetCashier("Администратор", ""); err != nil {
+		cur, err := drv.GetRegistrationData()
+		if err != nil {
+			mw.Synchronize(func() { walk.MsgBox(mw, "Ошибка", err.Error(), walk.MsgBoxIconError) })
 			return
 		}
-		resp, err := drv.Reregister(req, reasons)
-		if err != nil {
-			mw.Synchronize(func() {
-				walk.MsgBox(mw, "Ошибка замены ФН", err.Error(), walk.MsgBoxIconError)
-			})
-		} else {
+		fn, _ := drv.GetFnStatus()
+		plan, err := driver.PlanReregistration(cur, req, fn)
+		mw.Synchronize(func() {
+			if err != nil {
+				walk.MsgBox(mw, "Ошибка", err.Error(), walk.MsgBoxIconError)
+				return
+			}
+			if plan.Empty() {
+				walk.MsgBox(mw, "Причины перерегистрации", "Параметры не изменились, перерегистрация не требуется.", walk.MsgBoxIconInformation)
+				return
+			}
+			regModel.Reasons = plan.Base()
+			if err := regBinder.Reset(); err != nil {
+				fmt.Println("Binder reset error:", err)
+			}
+			var lines []string
+			for _, c := range plan.Changes {
+				lines = append(lines, fmt.Sprintf("[%d] %s", c.Reason, c))
+			}
+			walk.MsgBox(mw, "Причины перерегистрации", "Изменения:\n"+strings.Join(lines, "\n"), walk.MsgBoxIconInformation)
+		})
+	}()
+}
+
+// onReplaceFn запускает процедуру замены ФН (см. service.RunFnReplacement).
+// Состояние процедуры сохраняется рядом с файлом выгрузки архива, поэтому
+// прерванную замену можно продолжить, выбрав тот же файл.
+func onReplaceFn() {
+	drv := driver.Active
+	if drv == nil {
+		return
+	}
+	if err := regBinder.Submit(); err != nil {
+		return
+	}
+	if walk.MsgBox(mw, "Замена ФН", "Будет выгружен и закрыт архив текущего ФН, после чего потребуется установить новый ФН.\nПродолжить?", walk.MsgBoxYesNo|walk.MsgBoxIconWarning) != walk.DlgCmdYes {
+		return
+	}
+	dlg := new(walk.FileDialog)
+	dlg.FilePath = "fn_archive.jsonl"
+	dlg.Filter = "JSON Lines (*.jsonl)|*.jsonl|CSV (*.csv)|*.csv"
+	dlg.Title = "Файл выгрузки архива ФН"
+	if ok, _ := dlg.ShowSave(mw); !ok {
+		return
+	}
+
+	req := fillRequestFromModel(true)
+	// Подтверждения действуют только для approved. Подтверждение перерегистрации
+	// выдается после установки нового ФН и отзывается по окончании процедуры,
+	// если не было использовано
+	approved, revokeClose := approveOp(drv, driver.OpCloseFiscalArchive, "закрытие архива ФН при замене ФН")
+	revokeRereg := func() {}
+	opts := service.FnReplaceOptions{
+		StatePath:  strings.TrimSuffix(dlg.FilePath, filepath.Ext(dlg.FilePath)) + ".state.json",
+		ExportPath: dlg.FilePath,
+		Request:    &req,
+		DrainOfd:   drainOfdQueue,
+		WaitSwap: func(ctx context.Context, s *service.FnReplaceState) error {
+			res := make(chan int, 1)
 			mw.Synchronize(func() {
-				walk.MsgBox(mw, "Успех", fmt.Sprintf("ФН заменен!\nФД: %s\nФП: %s", resp.FdNumber, resp.FpNumber), walk.MsgBoxIconInformation)
+				res <- walk.MsgBox(mw, "Замена ФН", fmt.Sprintf("Архив ФН %s закрыт.\nВыключите ККТ, установите новый ФН, включите ККТ и нажмите ОК.", s.OldFnSerial), walk.MsgBoxOKCancel|walk.MsgBoxIconInformation)
 			})
-		}
+			if <-res != walk.DlgCmdOK {
+				return service.ErrFnSwapPending
+			}
+			revokeRereg()
+			_, revokeRereg = approveOp(approved, driver.OpReregister, "перерегистрация после замены ФН "+s.OldFnSerial)
+			return nil
+		},
+		OnStep: func(s *service.FnReplaceState) {
+			logMsg("Замена ФН: шаг %s", s.Step)
+		},
+	}
+	if strings.EqualFold(filepath.Ext(dlg.FilePath), ".csv") {
+		opts.ExportFormat = service.ExportCSV
+	}
+
+	go func() {
+		defer revokeClose()
+		state, err := service.RunFnReplacement(context.Background(), approved, opts)
+		revokeRereg()
+		mw.Synchronize(func() {
+			if err != nil {
+				text := err.Error()
+				if state != nil {
+					text += "\n\n" + state.Report()
+				}
+				walk.MsgBox(mw, "Замена ФН", text, walk.MsgBoxIconError)
+				return
+			}
+			walk.MsgBox(mw, "Замена ФН", state.Report(), walk.MsgBoxIconInformation)
+		})
 	}()
 }
 
@@ -652,8 +705,29 @@ func onCloseFn() {
 		return
 	}
 	go func() {
-		// 1. Закрытие ФН (включает PRINT)
-		result, err := drv.CloseFiscalArchive()
+		// 1. Закрытие ФН (включает PRINT) после проверок смены, очереди ОФД и уведомлений ОИСМ
+		approved, revoke := approveOp(drv, driver.OpCloseFiscalArchive, "закрытие архива ФН подтверждено в диалоге")
+		defer revoke()
+		opts := service.CloseGuardOptions{DrainOfd: drainOfdQueue}
+		result, err := service.CloseFiscalArchiveGuarded(context.Background(), approved, opts)
+		var refused *service.CloseRefusedError
+		if errors.As(err, &refused) {
+			answer := make(chan int, 1)
+			mw.Synchronize(func() {
+				var lines []string
+				for _, r := range refused.Reasons {
+					lines = append(lines, "- "+r.Message)
+				}
+				answer <- walk.MsgBox(mw, "Закрытие архива ФН",
+					"Закрытие архива не рекомендуется:\n"+strings.Join(lines, "\n")+"\n\nЗакрыть архив принудительно?",
+					walk.MsgBoxYesNo|walk.MsgBoxIconWarning)
+			})
+			if <-answer != walk.DlgCmdYes {
+				return
+			}
+			opts.Force = true
+			result, err = service.CloseFiscalArchiveGuarded(context.Background(), approved, opts)
+		}
 		if err != nil {
 			mw.Synchronize(func() { walk.MsgBox(mw, "Ошибка", err.Error(), walk.MsgBoxIconError) })
 			return
@@ -676,32 +750,65 @@ func onCloseFn() {
 	}()
 }
 
-// onSendToOfd отправляет первый неотправленный документ в ОФД
+// sendOfdOp - выполняющаяся отправка очереди ОФД (повторное нажатие кнопки останавливает ее).
+var sendOfdOp cancellableOp
+
+// onSendToOfd отправляет в ОФД все неотправленные документы
 func onSendToOfd() {
+	if sendOfdOp.cancel != nil {
+		sendOfdOp.toggle(nil)
+		return
+	}
 	drv := driver.Active
 	if drv == nil {
 		walk.MsgBox(mw, "Ошибка", "Нет подключения к ККТ", walk.MsgBoxIconError)
 		return
 	}
 
-	go func() {
-		result, err := SendFirstUnsentDocument(drv)
+	sendOfdOp.toggle(func(ctx context.Context) {
+		summary, err := service.DrainOfdQueue(ctx, drv, ofdDrainOptions())
 		mw.Synchronize(func() {
-			if err != nil {
-				walk.MsgBox(mw, "Ошибка", err.Error(), walk.MsgBoxIconError)
-				return
+			switch {
+			case errors.Is(err, context.Canceled):
+				walk.MsgBox(mw, "ОФД", ofdDrainReport(summary)+"\n\nОтправка остановлена.", walk.MsgBoxIconWarning)
+			case err != nil:
+				walk.MsgBox(mw, "Ошибка", ofdDrainReport(summary)+"\n\n"+err.Error(), walk.MsgBoxIconError)
+			default:
+				walk.MsgBox(mw, "ОФД", ofdDrainReport(summary), walk.MsgBoxIconInformation)
 			}
+		})
+	})
+}
 
-			if result.Success {
-				walk.MsgBox(mw, "Успех",
-					fmt.Sprintf("Отправлено документов: %d", result.DocumentsSent),
-					walk.MsgBoxIconInformation)
-			} else {
-				walk.MsgBox(mw, "Информация", result.ErrorMessage, walk.MsgBoxIconWarning)
-			}
+// sendOismOp - выполняющаяся отправка уведомлений в ОИСМ (повторное нажатие кнопки останавливает ее).
+var sendOismOp cancellableOp
+
+// onSendToOism отправляет в ОИСМ все непереданные уведомления о реализации
+// маркированных товаров
+func onSendToOism() {
+	if sendOismOp.cancel != nil {
+		sendOismOp.toggle(nil)
+		return
+	}
+	drv := driver.Active
+	if drv == nil {
+		walk.MsgBox(mw, "Ошибка", "Нет подключения к ККТ", walk.MsgBoxIconError)
+		return
+	}
 
+	sendOismOp.toggle(func(ctx context.Context) {
+		summary, err := service.DrainOismQueue(ctx, drv, oismDrainOptions())
+		mw.Synchronize(func() {
+			switch {
+			case errors.Is(err, context.Canceled):
+				walk.MsgBox(mw, "ОИСМ", oismDrainReport(summary)+"\n\nОтправка остановлена.", walk.MsgBoxIconWarning)
+			case err != nil:
+				walk.MsgBox(mw, "Ошибка", oismDrainReport(summary)+"\n\n"+err.Error(), walk.MsgBoxIconError)
+			default:
+				walk.MsgBox(mw, "ОИСМ", oismDrainReport(summary), walk.MsgBoxIconInformation)
+			}
 		})
-	}()
+	})
 }
 
 // onRefreshFnInfo обновляет информацию о ФН
@@ -721,6 +828,64 @@ func onRefreshFnInfo() {
 	}()
 }
 
+// applyRequestToModel заполняет форму параметрами регистрации (кроме РНМ).
+func applyRequestToModel(req driver.RegistrationRequest) {
+	regModel.INN = req.Inn
+	regModel.OrgName = req.OrgName
+	regModel.Address = req.Address
+	regModel.Place = req.Place
+	regModel.Email = req.SenderEmail
+	regModel.Site = req.FnsSite
+	regModel.FFD = req.FfdVer
+	regModel.OFDINN = req.OfdInn
+	regModel.OFDName = req.OfdName
+
+	// --- Режимы работы ---
+	modes := req.Modes()
+	regModel.ModeEncryption = modes.Encryption
+	regModel.ModeAutonomous = modes.Autonomous
+	regModel.ModeService = modes.Services
+	regModel.ModeBSO = modes.BSO
+	regModel.ModeInternet = modes.Internet
+	regModel.ModeCatering = modes.Catering
+	regModel.ModeWholesale = modes.Wholesale
+
+	regModel.ModeExcise = modes.Excise
+	regModel.ModeGambling = modes.Gambling
+	regModel.ModeLottery = modes.Lottery
+	regModel.ModeAutomat = modes.PrinterAutomat
+	regModel.ModeMarking = modes.Marking
+	regModel.ModePawn = modes.Pawn
+	regModel.ModeInsurance = modes.Insurance
+	regModel.ModeVending = modes.Vending
+
+	// Парсинг СНО
+	regModel.TaxOSN = false
+	regModel.TaxUSN = false
+	regModel.TaxUSN_M = false
+	regModel.TaxENVD = false
+	regModel.TaxESHN = false
+	regModel.TaxPat = false
+
+	for _, t := range strings.Split(req.TaxSystems, ",") {
+		switch strings.TrimSpace(t) {
+		case "0":
+			regModel.TaxOSN = true
+		case "1":
+			regModel.TaxUSN = true
+		case "2":
+			regModel.TaxUSN_M = true
+		case "3":
+			regModel.TaxENVD = true
+		case "4":
+			regModel.TaxESHN = true
+		case "5":
+			regModel.TaxPat = true
+		}
+	}
+	regModel.TaxSystemBase = req.TaxSystemBase
+}
+
 func fillRequestFromModel(isRereg bool) driver.RegistrationRequest {
 	req := driver.RegistrationRequest{
 		IsReregistration: isRereg,
@@ -734,23 +899,25 @@ func fillRequestFromModel(isRereg bool) driver.RegistrationRequest {
 		FfdVer:           regModel.FFD,
 		OfdName:          regModel.OFDName,
 		OfdInn:           regModel.OFDINN,
-		AutonomousMode:   regModel.ModeAutonomous,
-		Encryption:       regModel.ModeEncryption,
-		Service:          regModel.ModeService,
-		InternetCalc:     regModel.ModeInternet,
-		BSO:              regModel.ModeBSO,
-		Gambling:         regModel.ModeGambling,
-		Lottery:          regModel.ModeLottery,
-		Excise:           regModel.ModeExcise,
-		Marking:          regModel.ModeMarking,
-		PawnShop:         regModel.ModePawn,
-		Insurance:        regModel.ModeInsurance,
-		Catering:         regModel.ModeCatering,
-		Wholesale:        regModel.ModeWholesale,
-		Vending:          regModel.ModeVending,
-		PrinterAutomat:   regModel.ModeAutomat,
 		TaxSystemBase:    regModel.TaxSystemBase,
 	}
+	req.SetModes(driver.RegistrationModes{
+		Encryption:     regModel.ModeEncryption,
+		Autonomous:     regModel.ModeAutonomous,
+		Services:       regModel.ModeService,
+		BSO:            regModel.ModeBSO,
+		Internet:       regModel.ModeInternet,
+		Catering:       regModel.ModeCatering,
+		Wholesale:      regModel.ModeWholesale,
+		Excise:         regModel.ModeExcise,
+		Gambling:       regModel.ModeGambling,
+		Lottery:        regModel.ModeLottery,
+		PrinterAutomat: regModel.ModeAutomat,
+		Marking:        regModel.ModeMarking,
+		Pawn:           regModel.ModePawn,
+		Insurance:      regModel.ModeInsurance,
+		Vending:        regModel.ModeVending,
+	})
 
 	var taxCodes []string
 	if regModel.TaxOSN {
@@ -817,6 +984,10 @@ func runRnmGenerationDialog(inn, serial string) {
 			d.Composite{
 				Layout: d.HBox{},
 				Children: []d.Widget{
+					d.PushButton{
+						Text:      "Проверить CSV...",
+						OnClicked: func() { runRnmBulkCheck(dlg) },
+					},
 					d.HSpacer{},
 					d.PushButton{
 						AssignTo: &acceptPB,
@@ -831,14 +1002,14 @@ func runRnmGenerationDialog(inn, serial string) {
 							}
 
 							// Расчет
-							rnm, err := calculateRNM(dlgModel.OrderNum, inn, dlgModel.Serial)
+							generated, err := rnm.GenerateRNM(dlgModel.OrderNum, inn, dlgModel.Serial)
 							if err != nil {
 								walk.MsgBox(dlg, "Ошибка расчета", err.Error(), walk.MsgBoxIconError)
 								return
 							}
 
 							// Применяем результат
-							regModel.RNM = rnm
+							regModel.RNM = generated
 							if err := regBinder.Reset(); err != nil {
 								fmt.Println("Binder reset error:", err)
 							}
@@ -863,58 +1034,46 @@ func runRnmGenerationDialog(inn, serial string) {
 	dlg.Run()
 }
 
-// calculateRNM выполняет расчет РНМ по алгоритму CRC16-CCITT.
-// Формат входных данных для CRC:
-// Pad(Order, 10) + Pad(INN, 12) + Pad(Serial, 20)
-// Результат: Pad(Order, 10) + Pad(CRC, 6)
-func calculateRNM(orderNum, inn, serial string) (string, error) {
-	// 1. Формируем строку для расчета
-	paddedOrder := padLeft(orderNum, 10, '0')
-	paddedInn := padLeft(inn, 12, '0')
-	paddedSerial := padLeft(serial, 20, '0')
-
-	// Строка: 0000000001 + 007804437548 + 00000000000000000156 (пример)
-	calcString := paddedOrder + paddedInn + paddedSerial
-
-	// 2. Считаем CRC
-	crc := crc16ccitt([]byte(calcString))
-
-	// 3. Формируем хвост (CRC дополненный до 6 цифр нулями)
-	// Пример: CRC 33271 -> "033271"
-	crcStr := fmt.Sprintf("%d", crc)
-	paddedCrc := padLeft(crcStr, 6, '0')
-
-	// 4. Итоговый РНМ
-	finalRnm := paddedOrder + paddedCrc
-
-	return finalRnm, nil
-}
+// runRnmBulkCheck проверяет РНМ списка ККТ из CSV (колонки РНМ, ИНН, Заводской номер)
+// и сохраняет результаты в файл рядом с исходным.
+func runRnmBulkCheck(owner walk.Form) {
+	fd := new(walk.FileDialog)
+	fd.Filter = "CSV (*.csv)|*.csv"
+	fd.Title = "Проверка РНМ по списку ККТ"
+	if ok, _ := fd.ShowOpen(owner); !ok {
+		return
+	}
+	f, err := os.Open(fd.FilePath)
+	if err != nil {
+		walk.MsgBox(owner, "Ошибка", err.Error(), walk.MsgBoxIconError)
+		return
+	}
+	results, err := rnm.VerifyCSV(f)
+	f.Close()
+	if err != nil {
+		walk.MsgBox(owner, "Ошибка", err.Error(), walk.MsgBoxIconError)
+		return
+	}
 
-// crc16ccitt вычисляет CRC-16 (CCITT False)
-// Poly: 0x1021, Init: 0xFFFF
-func crc16ccitt(data []byte) uint16 {
-	crc := uint16(0xFFFF)
-	for _, b := range data {
-		crc ^= uint16(b) << 8
-		for i := 0; i < 8; i++ {
-			if (crc & 0x8000) != 0 {
-				crc = (crc << 1) ^ 0x1021
-			} else {
-				crc <<= 1
-			}
+	outPath := strings.TrimSuffix(fd.FilePath, filepath.Ext(fd.FilePath)) + "_rnm_check.csv"
+	out, err := os.Create(outPath)
+	if err == nil {
+		err = rnm.WriteResultsCSV(out, results)
+		if cerr := out.Close(); err == nil {
+			err = cerr
 		}
 	}
-	return crc
-}
-
-// padLeft дополняет строку символом padChar слева до длины length
-func padLeft(s string, length int, padChar byte) string {
-	if len(s) >= length {
-		return s // Или обрезать, если требуется строго length
+	if err != nil {
+		walk.MsgBox(owner, "Ошибка", "Ошибка записи результатов: "+err.Error(), walk.MsgBoxIconError)
+		return
 	}
-	padding := make([]byte, length-len(s))
-	for i := range padding {
-		padding[i] = padChar
+
+	invalid := rnm.CountInvalid(results)
+	msg := fmt.Sprintf("Проверено ККТ: %d\nНекорректных РНМ: %d\n\nРезультаты: %s", len(results), invalid, outPath)
+	icon := walk.MsgBoxIconInformation
+	if invalid > 0 {
+		icon = walk.MsgBoxIconWarning
 	}
-	return string(padding) + s
+	logMsg("Проверка РНМ по списку %s: %d из %d некорректны", filepath.Base(fd.FilePath), invalid, len(results))
+	walk.MsgBox(owner, "Проверка РНМ", msg, icon)
 }
@@ -1,8 +1,11 @@
 package gui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -949,6 +952,8 @@ func GetServiceTab() d.TabPage {
 							d.PushButton{Text: "Тех. сброс", OnClicked: onTechReset, MinSize: d.Size{Width: 90}},
 							d.PushButton{Text: "Ден. ящик", OnClicked: onOpenDrawer, MinSize: d.Size{Width: 90}},
 							d.PushButton{Text: "X-отчёт", OnClicked: onPrintXReport, MinSize: d.Size{Width: 90}},
+							d.PushButton{Text: "Отчёт о расчётах", OnClicked: onReportCurrentState, MinSize: d.Size{Width: 90}},
+							d.PushButton{AssignTo: &exportArchiveOp.btn, Text: "Экспорт архива ФН", OnClicked: onExportArchive, MinSize: d.Size{Width: 90}},
 							d.PushButton{Text: "Сброс МГМ", OnClicked: onMGMReset, MinSize: d.Size{Width: 90}},
 							// ОСНОВНЫЕ КНОПКИ УПРАВЛЕНИЯ НАСТРОЙКАМИ
 							d.PushButton{
@@ -1459,7 +1464,9 @@ func onTechReset() {
 		return
 	}
 	go func() {
-		err := drv.TechReset()
+		approved, revoke := approveOp(drv, driver.OpTechReset, "технологическое обнуление подтверждено в диалоге")
+		defer revoke()
+		err := approved.TechReset()
 		mw.Synchronize(func() {
 			if err != nil {
 				walk.MsgBox(mw, "Ошибка", "Сбой тех. обнуления: "+err.Error(), walk.MsgBoxIconError)
@@ -1493,9 +1500,21 @@ func onSyncTime() {
 		return
 	}
 
+	// Перевод времени вперед необратим: ФН не примет документы с более ранней датой
+	future := targetTime.After(time.Now().Add(10 * time.Minute))
+	if future && walk.MsgBox(mw, "Подтверждение",
+		"Время ККТ будет переведено вперед относительно текущего времени.\nВернуть время назад после формирования документов будет невозможно. Продолжить?",
+		walk.MsgBoxYesNo|walk.MsgBoxIconWarning) != walk.DlgCmdYes {
+		return
+	}
+
 	go func() {
+		// Подтверждение нужно и при отставании часов ККТ от текущего времени:
+		// политика сравнивает новое время с временем ККТ
+		approved, revoke := approveOp(drv, driver.OpSetDateTimeFuture, "синхронизация времени ККТ: "+timeService.FormatTime(targetTime))
+		defer revoke()
 		// 3. Отправляем команду драйверу
-		err := drv.SetDateTime(targetTime)
+		err := approved.SetDateTime(targetTime)
 		mw.Synchronize(func() {
 			if err != nil {
 				walk.MsgBox(mw, "Ошибка", "Ошибка синхронизации: "+err.Error(), walk.MsgBoxIconError)
@@ -1552,12 +1571,99 @@ func onPrintXReport() {
 	go func() { drv.PrintXReport() }()
 }
 
+func onReportCurrentState() {
+	drv := driver.Active
+	if drv == nil {
+		return
+	}
+	go func() {
+		rep, err := drv.ReportCurrentState()
+		mw.Synchronize(func() {
+			if err != nil {
+				walk.MsgBox(mw, "Ошибка", err.Error(), walk.MsgBoxIconError)
+				return
+			}
+			msg := fmt.Sprintf("ФД: %d\nФП: %s\nНеотправлено документов: %d", rep.FD, rep.FP, rep.Unsent)
+			if rep.Unsent > 0 {
+				msg += fmt.Sprintf("\nПервый неотправленный: №%d от %s %s", rep.FirstUnsent, rep.FirstUnsentDate, rep.FirstUnsentTime)
+			}
+			walk.MsgBox(mw, "Отчёт о текущем состоянии расчётов", msg, walk.MsgBoxIconInformation)
+		})
+	}()
+}
+
+// exportArchiveOp - выполняющийся экспорт архива ФН (повторное нажатие кнопки останавливает его).
+var exportArchiveOp cancellableOp
+
+// onExportArchive выгружает архив ФН в JSONL/CSV. Повторный запуск с тем же
+// файлом продолжает выгрузку с последнего выгруженного документа. Документы,
+// которые не удалось прочитать, записываются с ошибкой и читаются повторно
+// при следующем запуске.
+func onExportArchive() {
+	if exportArchiveOp.cancel != nil {
+		exportArchiveOp.toggle(nil)
+		return
+	}
+	drv := driver.Active
+	if drv == nil {
+		return
+	}
+	dlg := new(walk.FileDialog)
+	dlg.FilePath = "fn_archive.jsonl"
+	dlg.Filter = "JSON Lines (*.jsonl)|*.jsonl|CSV (*.csv)|*.csv"
+	dlg.Title = "Экспорт архива ФН"
+	if ok, _ := dlg.ShowSave(mw); !ok {
+		return
+	}
+	opts := service.ExportOptions{Format: service.ExportJSONL}
+	if strings.EqualFold(filepath.Ext(dlg.FilePath), ".csv") {
+		opts.Format = service.ExportCSV
+	}
+	failed := 0
+	opts.OnProgress = func(p service.ExportProgress) {
+		failed = p.Failed
+		if p.Err != nil {
+			logMsg("Экспорт архива ФН: ФД %d пропущен: %v", p.FD, p.Err)
+		}
+		if p.Exported%50 == 0 || p.FD == p.LastFD {
+			logMsg("Экспорт архива ФН: ФД %d из %d", p.FD, p.LastFD)
+		}
+	}
+	path := dlg.FilePath
+	exportArchiveOp.toggle(func(ctx context.Context) {
+		n, err := service.ExportArchiveFile(ctx, drv, path, opts)
+		mw.Synchronize(func() {
+			text := fmt.Sprintf("Выгружено документов: %d", n)
+			if failed > 0 {
+				text += fmt.Sprintf("\nПропущено (ошибка чтения): %d", failed)
+			}
+			switch {
+			case errors.Is(err, context.Canceled):
+				walk.MsgBox(mw, "Экспорт архива ФН", text+"\nЭкспорт остановлен, повторный запуск продолжит выгрузку.", walk.MsgBoxIconWarning)
+			case errors.Is(err, service.ErrArchiveIncomplete):
+				walk.MsgBox(mw, "Экспорт архива ФН", text+"\nПовторный запуск с тем же файлом повторит чтение пропущенных документов.", walk.MsgBoxIconWarning)
+			case err != nil:
+				walk.MsgBox(mw, "Ошибка", fmt.Sprintf("%s\n%v", text, err), walk.MsgBoxIconError)
+			default:
+				walk.MsgBox(mw, "Экспорт архива ФН", text, walk.MsgBoxIconInformation)
+			}
+		})
+	})
+}
+
 func onMGMReset() {
 	drv := driver.Active
 	if drv == nil {
 		return
 	}
-	go func() { drv.ResetMGM() }()
+	if walk.MsgBox(mw, "Подтверждение", "Выполнить сброс МГМ?", walk.MsgBoxYesNo|walk.MsgBoxIconWarning) != walk.DlgCmdYes {
+		return
+	}
+	go func() {
+		approved, revoke := approveOp(drv, driver.OpResetMGM, "сброс МГМ подтвержден в диалоге")
+		defer revoke()
+		approved.ResetMGM()
+	}()
 }
 
 // reloadEditor загружает данные из выбранной строки списка во временный объект редактора.
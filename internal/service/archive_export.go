@@ -0,0 +1,300 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/fndoc"
+)
+
+// ExportFormat определяет формат выгрузки архива ФН.
+type ExportFormat int
+
+const (
+	ExportJSONL ExportFormat = iota // Один JSON-объект на строку
+	ExportCSV                       // CSV с заголовком
+)
+
+// exportTimeLayout - формат даты/времени документа в выгрузке.
+const exportTimeLayout = "2006-01-02T15:04:05"
+
+// ErrArchiveIncomplete возвращается ExportArchive, если часть документов не
+// удалось прочитать или разобрать. Повторный запуск ExportArchiveFile
+// начинает выгрузку с первого такого документа.
+var ErrArchiveIncomplete = errors.New("архив ФН выгружен не полностью")
+
+var csvHeader = []string{"fd", "form", "type", "datetime", "shift", "total", "fp", "error"}
+
+// ArchiveRecord - строка выгрузки архива ФН.
+type ArchiveRecord struct {
+	FD       int     `json:"fd"`
+	Form     int     `json:"form"`
+	Type     string  `json:"type"`
+	DateTime string  `json:"datetime"`
+	ShiftNum int     `json:"shift,omitempty"`
+	Total    float64 `json:"total"`
+	FP       string  `json:"fp"`
+	// Error - ошибка чтения или разбора документа; остальные поля, кроме FD, не заполнены
+	Error string `json:"error,omitempty"`
+}
+
+// ExportProgress передается в ExportOptions.OnProgress после каждого документа.
+type ExportProgress struct {
+	FD       int // Номер выгруженного документа
+	FirstFD  int
+	LastFD   int
+	Exported int   // Количество выгруженных документов с начала вызова
+	Failed   int   // Количество документов, которые не удалось прочитать или разобрать
+	Err      error // Ошибка чтения или разбора документа FD (nil - документ выгружен)
+}
+
+// ExportOptions задает параметры выгрузки архива ФН.
+type ExportOptions struct {
+	Format ExportFormat
+	// FromFD - первый выгружаемый документ (0 - с первого).
+	FromFD int
+	// ToFD - последний выгружаемый документ (0 - FnStatus.LastFD).
+	ToFD int
+	// OnProgress вызывается после выгрузки каждого документа.
+	OnProgress func(ExportProgress)
+}
+
+// ExportArchive выгружает документы архива ФН с FromFD по ToFD в w.
+// Для CSV заголовок записывается только при FromFD <= 1.
+// Документ, который не удалось прочитать или разобрать, записывается строкой
+// с номером ФД и ошибкой (поле error) и пропускается, ошибка передается в
+// ExportProgress.Err. Возвращает количество выгруженных документов (без
+// пропущенных); если были пропущенные документы, возвращается ошибка
+// ErrArchiveIncomplete. При отмене ctx возвращает уже выгруженное количество
+// и ошибку контекста.
+func ExportArchive(ctx context.Context, drv driver.Driver, w io.Writer, opts ExportOptions) (int, error) {
+	first := opts.FromFD
+	if first < 1 {
+		first = 1
+	}
+	last := opts.ToFD
+	if last <= 0 {
+		fn, err := drv.GetFnStatus()
+		if err != nil {
+			return 0, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+		}
+		last = fn.LastFD
+	}
+
+	bw := bufio.NewWriter(w)
+	var cw *csv.Writer
+	if opts.Format == ExportCSV {
+		cw = csv.NewWriter(bw)
+		if first == 1 {
+			if err := cw.Write(csvHeader); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	exported, failed, firstFailed := 0, 0, 0
+	var exportErr error
+	for fd := first; fd <= last; fd++ {
+		if err := ctx.Err(); err != nil {
+			exportErr = err
+			break
+		}
+		rec, readErr := readArchiveRecord(drv, fd)
+		if readErr != nil {
+			rec = ArchiveRecord{FD: fd, Error: readErr.Error()}
+		}
+		var err error
+		if cw != nil {
+			err = cw.Write(rec.csvRow())
+		} else {
+			err = writeJSONLine(bw, rec)
+		}
+		if err != nil {
+			exportErr = fmt.Errorf("ошибка записи ФД %d: %w", fd, err)
+			break
+		}
+		if readErr != nil {
+			if failed == 0 {
+				firstFailed = fd
+			}
+			failed++
+		} else {
+			exported++
+		}
+		if opts.OnProgress != nil {
+			opts.OnProgress(ExportProgress{FD: fd, FirstFD: first, LastFD: last, Exported: exported, Failed: failed, Err: readErr})
+		}
+	}
+
+	// Сбрасываем уже выгруженные строки и при ошибке, чтобы выгрузку можно было продолжить
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil && exportErr == nil {
+			exportErr = err
+		}
+	}
+	if err := bw.Flush(); err != nil && exportErr == nil {
+		exportErr = err
+	}
+	if exportErr == nil && failed > 0 {
+		exportErr = fmt.Errorf("%w: не прочитано документов: %d (первый ФД %d)", ErrArchiveIncomplete, failed, firstFailed)
+	}
+	return exported, exportErr
+}
+
+// ExportArchiveFile выгружает архив ФН в файл, продолжая с документа,
+// следующего за последним уже выгруженным в этот файл. Если в файле есть
+// строки с ошибкой, файл обрезается с первой из них и выгрузка повторяется
+// с этого документа. Неполная последняя строка (прерванная запись) отбрасывается.
+func ExportArchiveFile(ctx context.Context, drv driver.Driver, path string, opts ExportOptions) (int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка открытия файла выгрузки: %w", err)
+	}
+	defer f.Close()
+
+	lastFD, size, err := scanExport(f, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+	if lastFD == 0 {
+		// В файле нет ни одного документа (например, только заголовок CSV) - начинаем заново
+		size = 0
+	} else if lastFD >= opts.FromFD {
+		opts.FromFD = lastFD + 1
+	}
+	if err := f.Truncate(size); err != nil {
+		return 0, fmt.Errorf("ошибка подготовки файла выгрузки: %w", err)
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return ExportArchive(ctx, drv, f, opts)
+}
+
+// LastExportedFD возвращает номер последнего документа в существующей выгрузке,
+// до которого все документы выгружены без ошибок (0, если таких нет).
+// Неполная последняя строка (прерванная запись) игнорируется.
+func LastExportedFD(r io.Reader, format ExportFormat) (int, error) {
+	lastFD, _, err := scanExport(r, format)
+	return lastFD, err
+}
+
+// scanExport возвращает номер последнего выгруженного документа и размер
+// полностью записанных строк до первой строки с ошибкой.
+func scanExport(r io.Reader, format ExportFormat) (int, int64, error) {
+	lastFD := 0
+	var size int64
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("ошибка чтения файла выгрузки: %w", err)
+		}
+		fd, failed := exportedLine(strings.TrimSpace(line), format)
+		if failed {
+			break
+		}
+		size += int64(len(line))
+		if fd > lastFD {
+			lastFD = fd
+		}
+	}
+	return lastFD, size, nil
+}
+
+// exportedLine возвращает номер документа в строке выгрузки (0 для заголовка
+// и пустых строк) и признак строки с ошибкой.
+func exportedLine(line string, format ExportFormat) (int, bool) {
+	if line == "" {
+		return 0, false
+	}
+	if format == ExportCSV {
+		row, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil || len(row) == 0 {
+			return 0, false
+		}
+		fd, _ := strconv.Atoi(row[0])
+		return fd, fd > 0 && len(row) == len(csvHeader) && row[len(row)-1] != ""
+	}
+	var rec ArchiveRecord
+	if json.Unmarshal([]byte(line), &rec) != nil {
+		return 0, false
+	}
+	return rec.FD, rec.Error != ""
+}
+
+// readArchiveRecord читает документ из ФН и формирует строку выгрузки.
+func readArchiveRecord(drv driver.Driver, fd int) (ArchiveRecord, error) {
+	xmlDoc, err := drv.GetDocumentXMLFromFN(fd)
+	if err != nil {
+		return ArchiveRecord{}, fmt.Errorf("ошибка чтения ФД %d: %w", fd, err)
+	}
+	doc, err := fndoc.Parse(xmlDoc)
+	if err != nil {
+		return ArchiveRecord{}, fmt.Errorf("ошибка разбора ФД %d: %w", fd, err)
+	}
+
+	h := doc.DocHeader()
+	rec := ArchiveRecord{
+		FD:   fd,
+		Form: h.Form,
+		Type: driver.DocTypeName(h.Form),
+		FP:   h.FP,
+	}
+	if !h.DateTime.IsZero() {
+		rec.DateTime = h.DateTime.Format(exportTimeLayout)
+	}
+	switch d := doc.(type) {
+	case *fndoc.Receipt:
+		rec.ShiftNum = d.ShiftNum
+		rec.Total = d.Total
+	case *fndoc.ShiftReport:
+		rec.ShiftNum = d.ShiftNum
+	case *fndoc.CurrentStateReport:
+		rec.ShiftNum = d.ShiftNum
+	}
+	return rec, nil
+}
+
+func (r ArchiveRecord) csvRow() []string {
+	if r.Error != "" {
+		return []string{strconv.Itoa(r.FD), "", "", "", "", "", "", r.Error}
+	}
+	shift := ""
+	if r.ShiftNum > 0 {
+		shift = strconv.Itoa(r.ShiftNum)
+	}
+	return []string{
+		strconv.Itoa(r.FD),
+		strconv.Itoa(r.Form),
+		r.Type,
+		r.DateTime,
+		shift,
+		strconv.FormatFloat(r.Total, 'f', 2, 64),
+		r.FP,
+		"",
+	}
+}
+
+func writeJSONLine(w io.Writer, rec ArchiveRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mitsuscanner/driver"
+)
+
+func archiveDriver() *fakeDriver {
+	return &fakeDriver{
+		fn: driver.FnStatus{LastFD: 4},
+		docs: map[int]string{
+			1: `<DocXML FORM="1"><T1012>2024-03-01T09:00:00</T1012><T1077>111</T1077></DocXML>`,
+			2: `<DocXML FORM="2"><T1012>2024-03-01T09:05:00</T1012><T1038>1</T1038><T1077>222</T1077></DocXML>`,
+			3: `<DocXML FORM="3"><T1012>2024-03-01T09:10:00</T1012><T1038>1</T1038><T1020>150.5</T1020><T1077>333</T1077></DocXML>`,
+			// ККТ может вернуть документ в обертке ответа
+			4: `<OK><DocXML FORM="5"><T1012>2024-03-01T20:00:00</T1012><T1038>1</T1038><T1077>444</T1077></DocXML></OK>`,
+		},
+	}
+}
+
+func TestExportArchiveCSV(t *testing.T) {
+	var buf bytes.Buffer
+	var progress []int
+	n, err := ExportArchive(context.Background(), archiveDriver(), &buf, ExportOptions{
+		Format:     ExportCSV,
+		OnProgress: func(p ExportProgress) { progress = append(progress, p.FD) },
+	})
+	if err != nil || n != 4 {
+		t.Fatalf("unexpected result: %d, %v", n, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || lines[0] != "fd,form,type,datetime,shift,total,fp,error" {
+		t.Fatalf("unexpected csv:\n%s", buf.String())
+	}
+	if lines[3] != "3,3,Кассовый чек,2024-03-01T09:10:00,1,150.50,333," {
+		t.Fatalf("unexpected receipt row: %s", lines[3])
+	}
+	if len(progress) != 4 || progress[3] != 4 {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+}
+
+func TestExportArchiveFileResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	drv := archiveDriver()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n, err := ExportArchiveFile(ctx, drv, path, ExportOptions{
+		OnProgress: func(p ExportProgress) {
+			if p.FD == 2 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) || n != 2 {
+		t.Fatalf("expected cancellation after 2 documents, got %d, %v", n, err)
+	}
+
+	// Имитируем прерванную запись строки
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"fd":3,"fo`)
+	f.Close()
+
+	n, err = ExportArchiveFile(context.Background(), drv, path, ExportOptions{})
+	if err != nil || n != 2 {
+		t.Fatalf("unexpected resume result: %d, %v", n, err)
+	}
+
+	data, _ := os.ReadFile(path)
+	lastFD, err := LastExportedFD(bytes.NewReader(data), ExportJSONL)
+	if err != nil || lastFD != 4 {
+		t.Fatalf("unexpected last FD: %d, %v", lastFD, err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 4 ||
+		lines[2] != `{"fd":3,"form":3,"type":"Кассовый чек","datetime":"2024-03-01T09:10:00","shift":1,"total":150.5,"fp":"333"}` {
+		t.Fatalf("unexpected export:\n%s", data)
+	}
+}
+
+func TestExportArchiveSkipsBrokenDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	drv := archiveDriver()
+	broken := drv.docs[2]
+	delete(drv.docs, 2)
+
+	var failed []int
+	n, err := ExportArchiveFile(context.Background(), drv, path, ExportOptions{
+		OnProgress: func(p ExportProgress) {
+			if p.Err != nil {
+				failed = append(failed, p.FD)
+			}
+		},
+	})
+	if !errors.Is(err, ErrArchiveIncomplete) || n != 3 {
+		t.Fatalf("unexpected result: %d, %v", n, err)
+	}
+	if len(failed) != 1 || failed[0] != 2 {
+		t.Fatalf("unexpected failures: %v", failed)
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], `{"fd":2,"form":0,"type":"","datetime":"","total":0,"fp":"","error":"`) {
+		t.Fatalf("unexpected export:\n%s", data)
+	}
+	// Нулевой итог выгружается
+	if !strings.Contains(lines[0], `"total":0`) {
+		t.Fatalf("zero total dropped: %s", lines[0])
+	}
+	if lastFD, _ := LastExportedFD(bytes.NewReader(data), ExportJSONL); lastFD != 1 {
+		t.Fatalf("document with error counted as exported: %d", lastFD)
+	}
+
+	// Повторный запуск начинается с документа, который не удалось прочитать
+	drv.docs[2] = broken
+	n, err = ExportArchiveFile(context.Background(), drv, path, ExportOptions{})
+	if err != nil || n != 3 {
+		t.Fatalf("unexpected resume result: %d, %v", n, err)
+	}
+	data, _ = os.ReadFile(path)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 4 || strings.Contains(string(data), `"error"`) {
+		t.Fatalf("unexpected export after retry:\n%s", data)
+	}
+}
+
+func TestExportArchiveCSVResumeFromError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.csv")
+	drv := archiveDriver()
+	broken := drv.docs[3]
+	delete(drv.docs, 3)
+
+	opts := ExportOptions{Format: ExportCSV}
+	if n, err := ExportArchiveFile(context.Background(), drv, path, opts); !errors.Is(err, ErrArchiveIncomplete) || n != 3 {
+		t.Fatalf("unexpected result: %d, %v", n, err)
+	}
+	drv.docs[3] = broken
+	if n, err := ExportArchiveFile(context.Background(), drv, path, opts); err != nil || n != 2 {
+		t.Fatalf("unexpected resume result: %d, %v", n, err)
+	}
+	data, _ := os.ReadFile(path)
+	lastFD, err := LastExportedFD(bytes.NewReader(data), ExportCSV)
+	if err != nil || lastFD != 4 || strings.Count(string(data), "\n") != 5 {
+		t.Fatalf("unexpected export (%d, %v):\n%s", lastFD, err, data)
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"fmt"
+
+	"mitsuscanner/driver"
+)
+
+// fakeDriver - минимальная реализация driver.Driver для тестов сервисов.
+// Неиспользуемые методы вызывают панику через встроенный nil-интерфейс.
+type fakeDriver struct {
+	driver.Driver
+
+	shift    driver.ShiftStatus
+	fn       driver.FnStatus
+	ofd      driver.OfdExchangeStatus
+	marking  driver.MarkingStatus
+	reg      *driver.RegData
+	docs     map[int]string
+	docReads int // Количество вызовов GetDocumentXMLFromFN
+	commands []string
+
+	docType   int   // Результат GetCurrentDocumentType
+	checkOpen bool  // Есть незавершенный чек
+	checkErr  error // Ошибка CancelCheck/CloseCheck
+
+	markFn   driver.MarkCheckResult // Результат MarkCheck
+	markOism driver.MarkCheckResult // Результат MarkRequestOism
+	oismErr  error                  // Ошибка MarkRequestOism (ОИСМ недоступен)
+
+	ofdSettings  driver.OfdSettings
+	oismSettings driver.OismSettings
+	cashier      string // Результат GetCashier
+}
+
+func (f *fakeDriver) GetShiftStatus() (*driver.ShiftStatus, error) {
+	s := f.shift
+	return &s, nil
+}
+
+func (f *fakeDriver) GetFnStatus() (*driver.FnStatus, error) {
+	fn := f.fn
+	return &fn, nil
+}
+
+func (f *fakeDriver) GetDocumentXMLFromFN(fd int) (string, error) {
+	f.docReads++
+	doc, ok := f.docs[fd]
+	if !ok {
+		return "", fmt.Errorf("документ %d не найден", fd)
+	}
+	return doc, nil
+}
+
+func (f *fakeDriver) OpenShift(operator string) error {
+	f.commands = append(f.commands, "OPEN")
+	f.shift.State = "1"
+	f.shift.ShiftNum++
+	return nil
+}
+
+func (f *fakeDriver) CloseShift(operator string) error {
+	f.commands = append(f.commands, "CLOSE")
+	f.shift.State = "0"
+	return nil
+}
+
+func (f *fakeDriver) PrintLastDocument() error {
+	f.commands = append(f.commands, "PRINT")
+	return nil
+}
+
+func (f *fakeDriver) GetCurrentDocumentType() (int, error) {
+	return f.docType, nil
+}
+
+func (f *fakeDriver) CancelCheck() error {
+	if f.checkErr != nil {
+		return f.checkErr
+	}
+	if !f.checkOpen {
+		return &driver.DeviceError{Code: "37"}
+	}
+	f.commands = append(f.commands, "CHECK_CANCEL")
+	f.checkOpen = false
+	return nil
+}
+
+func (f *fakeDriver) CloseCheck() error {
+	if f.checkErr != nil {
+		return f.checkErr
+	}
+	if !f.checkOpen {
+		return &driver.DeviceError{Code: "37"}
+	}
+	f.commands = append(f.commands, "CHECK_CLOSE")
+	f.checkOpen = false
+	f.fn.LastFD++
+	return nil
+}
+
+func (f *fakeDriver) GetOfdExchangeStatus() (*driver.OfdExchangeStatus, error) {
+	s := f.ofd
+	return &s, nil
+}
+
+func (f *fakeDriver) GetRegistrationData() (*driver.RegData, error) {
+	if f.reg == nil {
+		return nil, fmt.Errorf("ККТ не зарегистрирована")
+	}
+	r := *f.reg
+	return &r, nil
+}
+
+func (f *fakeDriver) GetCashier() (string, string, error) {
+	return f.cashier, "", nil
+}
+
+func (f *fakeDriver) SetCashier(name string, inn string) error {
+	return nil
+}
+
+func (f *fakeDriver) CloseFiscalArchive() (*driver.CloseFnResult, error) {
+	f.commands = append(f.commands, "FN_CLOSE")
+	f.fn.LastFD++
+	f.fn.Phase = "0x07"
+	f.addDoc(fmt.Sprintf(`<DocXML FORM="6"><T1040>%d</T1040><T1077>1234567890</T1077></DocXML>`, f.fn.LastFD))
+	return &driver.CloseFnResult{FD: f.fn.LastFD, FP: "1234567890"}, nil
+}
+
+func (f *fakeDriver) Reregister(req driver.RegistrationRequest, reasons []int) (*driver.RegResponse, error) {
+	f.commands = append(f.commands, fmt.Sprintf("REREG %v", reasons))
+	f.fn.LastFD++
+	f.fn.Phase = "0x03"
+	f.addDoc(fmt.Sprintf(`<DocXML FORM="11"><T1040>%d</T1040><T1077>987654321</T1077></DocXML>`, f.fn.LastFD))
+	return &driver.RegResponse{FdNumber: fmt.Sprint(f.fn.LastFD), FpNumber: "987654321"}, nil
+}
+
+// addDoc добавляет в архив документ с номером LastFD.
+func (f *fakeDriver) addDoc(doc string) {
+	if f.docs == nil {
+		f.docs = map[int]string{}
+	}
+	f.docs[f.fn.LastFD] = doc
+}
+
+func (f *fakeDriver) GetMarkingStatus() (*driver.MarkingStatus, error) {
+	m := f.marking
+	return &m, nil
+}
+
+func (f *fakeDriver) MarkCheck(mark driver.ItemMark, quantity float64) (*driver.MarkCheckResult, error) {
+	if mark.Fraction != nil && quantity == 0 {
+		quantity = mark.Fraction.FractionQuantity()
+	}
+	if err := driver.ValidateItemMark("1.2", &mark, quantity); err != nil {
+		return nil, err
+	}
+	f.commands = append(f.commands, "MARK_CHECK")
+	r := f.markFn
+	return &r, nil
+}
+
+func (f *fakeDriver) MarkRequestOism() (*driver.MarkCheckResult, error) {
+	f.commands = append(f.commands, "MARK_ONLINE")
+	if f.oismErr != nil {
+		return nil, f.oismErr
+	}
+	r := f.markOism
+	return &r, nil
+}
+
+func (f *fakeDriver) MarkAccept() error {
+	f.commands = append(f.commands, "MARK_ACCEPT")
+	return nil
+}
+
+func (f *fakeDriver) MarkReject() error {
+	f.commands = append(f.commands, "MARK_REJECT")
+	return nil
+}
+
+func (f *fakeDriver) GetOfdSettings() (*driver.OfdSettings, error) {
+	s := f.ofdSettings
+	return &s, nil
+}
+
+func (f *fakeDriver) SetOfdSettings(s driver.OfdSettings) error {
+	f.commands = append(f.commands, "OFD_CLIENT="+s.Client)
+	f.ofdSettings = s
+	return nil
+}
+
+func (f *fakeDriver) OfdReadFullDocument() ([]byte, error) {
+	if f.ofd.Count == 0 {
+		return nil, fmt.Errorf("документ пуст или отсутствует")
+	}
+	return []byte{0x01, 0x02, byte(f.ofd.FirstDoc)}, nil
+}
+
+func (f *fakeDriver) OfdLoadReceipt(receipt []byte) error {
+	f.commands = append(f.commands, "OFD_LOAD")
+	f.ofd.Count--
+	f.ofd.FirstDoc++
+	return nil
+}
+
+func (f *fakeDriver) GetOismSettings() (*driver.OismSettings, error) {
+	s := f.oismSettings
+	return &s, nil
+}
+
+func (f *fakeDriver) OismReadFullNotice() ([]byte, error) {
+	if f.marking.Notice == 0 {
+		return nil, fmt.Errorf("уведомление отсутствует")
+	}
+	return []byte{0x03, 0x04, byte(f.marking.Notice)}, nil
+}
+
+func (f *fakeDriver) OismLoadReceipt(receipt []byte) error {
+	f.commands = append(f.commands, "OISM_LOAD")
+	f.marking.Notice--
+	return nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mitsuscanner/driver"
+)
+
+// CloseBlockCode - причина, по которой закрытие архива ФН не рекомендуется.
+type CloseBlockCode string
+
+const (
+	CloseBlockShiftOpen      CloseBlockCode = "shift_open"      // Смена не закрыта
+	CloseBlockOfdUnsent      CloseBlockCode = "ofd_unsent"      // Есть документы, не переданные в ОФД
+	CloseBlockMarkingNotices CloseBlockCode = "marking_notices" // Есть непереданные уведомления о реализации маркированных товаров
+	CloseBlockMarkingPending CloseBlockCode = "marking_pending" // Есть коды маркировки, ожидающие результата проверки
+)
+
+// CloseBlockReason - одна причина отказа в закрытии архива ФН.
+type CloseBlockReason struct {
+	Code    CloseBlockCode
+	Count   int // Количество документов/уведомлений (0 - не применимо)
+	Message string
+}
+
+// CloseRefusedError возвращается CloseFiscalArchiveGuarded, если проверки не пройдены.
+type CloseRefusedError struct {
+	Reasons []CloseBlockReason
+}
+
+func (e *CloseRefusedError) Error() string {
+	msgs := make([]string, len(e.Reasons))
+	for i, r := range e.Reasons {
+		msgs[i] = r.Message
+	}
+	return "закрытие архива ФН отклонено: " + strings.Join(msgs, "; ")
+}
+
+// Has возвращает true, если среди причин есть указанная.
+func (e *CloseRefusedError) Has(code CloseBlockCode) bool {
+	for _, r := range e.Reasons {
+		if r.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// CloseGuardOptions задает поведение CloseFiscalArchiveGuarded.
+type CloseGuardOptions struct {
+	// Force - закрыть архив, несмотря на непройденные проверки.
+	Force bool
+	// DrainOfd, если задана, вызывается перед проверками при наличии
+	// непереданных в ОФД документов и должна отправить их.
+	DrainOfd func(ctx context.Context, drv driver.Driver) error
+}
+
+// CheckCloseFiscalArchive проверяет, можно ли закрыть архив ФН: смена закрыта,
+// все документы переданы в ОФД, уведомления о реализации маркированных товаров
+// переданы в ОИСМ. Возвращает список причин (пустой - закрытие возможно).
+func CheckCloseFiscalArchive(drv driver.Driver) ([]CloseBlockReason, error) {
+	var reasons []CloseBlockReason
+
+	shift, err := drv.GetShiftStatus()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статуса смены: %w", err)
+	}
+	if shift.State != ShiftStateClosed {
+		reasons = append(reasons, CloseBlockReason{
+			Code:    CloseBlockShiftOpen,
+			Message: fmt.Sprintf("смена %d не закрыта", shift.ShiftNum),
+		})
+	}
+
+	ofd, err := drv.GetOfdExchangeStatus()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статуса обмена с ОФД: %w", err)
+	}
+	if ofd.Count > 0 {
+		reasons = append(reasons, CloseBlockReason{
+			Code:    CloseBlockOfdUnsent,
+			Count:   ofd.Count,
+			Message: fmt.Sprintf("в ОФД не переданы документы: %d (первый ФД %d)", ofd.Count, ofd.FirstDoc),
+		})
+	}
+
+	mark, err := drv.GetMarkingStatus()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статуса маркировки: %w", err)
+	}
+	if mark.Notice > 0 {
+		reasons = append(reasons, CloseBlockReason{
+			Code:    CloseBlockMarkingNotices,
+			Count:   mark.Notice,
+			Message: fmt.Sprintf("в ОИСМ не переданы уведомления о реализации маркированных товаров: %d", mark.Notice),
+		})
+	}
+	if mark.Pending > 0 {
+		reasons = append(reasons, CloseBlockReason{
+			Code:    CloseBlockMarkingPending,
+			Count:   mark.Pending,
+			Message: fmt.Sprintf("коды маркировки ожидают результата проверки: %d", mark.Pending),
+		})
+	}
+	return reasons, nil
+}
+
+// CloseFiscalArchiveGuarded закрывает архив ФН только после проверок
+// CheckCloseFiscalArchive. Если проверки не пройдены и opts.Force не задан,
+// возвращается *CloseRefusedError со списком причин.
+func CloseFiscalArchiveGuarded(ctx context.Context, drv driver.Driver, opts CloseGuardOptions) (*driver.CloseFnResult, error) {
+	if opts.DrainOfd != nil {
+		ofd, err := drv.GetOfdExchangeStatus()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения статуса обмена с ОФД: %w", err)
+		}
+		if ofd.Count > 0 {
+			if err := opts.DrainOfd(ctx, drv); err != nil && !opts.Force {
+				return nil, fmt.Errorf("ошибка отправки документов в ОФД: %w", err)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	reasons, err := CheckCloseFiscalArchive(drv)
+	if err != nil {
+		return nil, err
+	}
+	if len(reasons) > 0 && !opts.Force {
+		return nil, &CloseRefusedError{Reasons: reasons}
+	}
+	return drv.CloseFiscalArchive()
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"mitsuscanner/driver"
+)
+
+func TestCloseFiscalArchiveGuardedRefuses(t *testing.T) {
+	drv := &fakeDriver{
+		shift:   driver.ShiftStatus{State: ShiftStateOpen, ShiftNum: 7},
+		ofd:     driver.OfdExchangeStatus{Count: 3, FirstDoc: 120},
+		marking: driver.MarkingStatus{Notice: 2},
+	}
+	_, err := CloseFiscalArchiveGuarded(context.Background(), drv, CloseGuardOptions{})
+	var refused *CloseRefusedError
+	if !errors.As(err, &refused) {
+		t.Fatalf("expected CloseRefusedError, got %v", err)
+	}
+	var codes []CloseBlockCode
+	for _, r := range refused.Reasons {
+		codes = append(codes, r.Code)
+	}
+	if !reflect.DeepEqual(codes, []CloseBlockCode{CloseBlockShiftOpen, CloseBlockOfdUnsent, CloseBlockMarkingNotices}) {
+		t.Fatalf("unexpected reasons: %v", refused.Reasons)
+	}
+	if len(drv.commands) != 0 {
+		t.Fatalf("archive must not be closed: %v", drv.commands)
+	}
+
+	res, err := CloseFiscalArchiveGuarded(context.Background(), drv, CloseGuardOptions{Force: true})
+	if err != nil || res == nil || !reflect.DeepEqual(drv.commands, []string{"FN_CLOSE"}) {
+		t.Fatalf("forced close failed: %v, %v", err, drv.commands)
+	}
+}
+
+func TestCloseFiscalArchiveGuardedDrainsOfd(t *testing.T) {
+	drv := &fakeDriver{
+		shift: driver.ShiftStatus{State: ShiftStateClosed},
+		ofd:   driver.OfdExchangeStatus{Count: 3},
+	}
+	drained := false
+	res, err := CloseFiscalArchiveGuarded(context.Background(), drv, CloseGuardOptions{
+		DrainOfd: func(ctx context.Context, d driver.Driver) error {
+			drained = true
+			drv.ofd.Count = 0
+			return nil
+		},
+	})
+	if err != nil || res == nil || !drained {
+		t.Fatalf("unexpected result: %v, %v, drained=%v", res, err, drained)
+	}
+}
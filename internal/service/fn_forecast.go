@@ -0,0 +1,193 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/fndoc"
+)
+
+// DefaultFnCapacity - ориентировочная емкость архива ФН в документах,
+// используемая, если FnForecastConfig.Capacity не задана.
+const DefaultFnCapacity = 250000
+
+// DefaultFnHorizons - горизонты предупреждений по умолчанию.
+var DefaultFnHorizons = []time.Duration{30 * 24 * time.Hour, 14 * 24 * time.Hour, 7 * 24 * time.Hour, 3 * 24 * time.Hour}
+
+// FnEndReason - причина окончания работы ФН.
+type FnEndReason string
+
+const (
+	FnEndValidity FnEndReason = "истечение срока действия"
+	FnEndMemory   FnEndReason = "заполнение архива"
+)
+
+// FnSample - номер последнего ФД в момент времени.
+type FnSample struct {
+	At     time.Time
+	LastFD int
+}
+
+// FnForecastConfig задает параметры прогноза.
+type FnForecastConfig struct {
+	// Capacity - емкость архива ФН в документах (0 - DefaultFnCapacity).
+	Capacity int
+	// Horizons - за сколько до окончания работы ФН предупреждать (nil - DefaultFnHorizons).
+	Horizons []time.Duration
+	// Now - источник текущего времени (для тестов).
+	Now func() time.Time
+}
+
+// FnForecast - прогноз окончания работы ФН.
+type FnForecast struct {
+	Flags      driver.FnWarnings
+	ValidUntil time.Time // Окончание срока действия (нулевое, если неизвестно)
+	FullAt     time.Time // Прогноз заполнения архива (нулевое, если не хватает данных)
+	EndAt      time.Time // Ближайшая из дат ValidUntil и FullAt
+	EndReason  FnEndReason
+	DocsPerDay float64
+	Remaining  time.Duration // Время до EndAt
+	// Horizon - наименьший достигнутый горизонт предупреждения (0 - не достигнут).
+	Horizon  time.Duration
+	Warnings []string
+}
+
+// NeedsReplacement возвращает true, если достигнут горизонт предупреждения
+// или ФН сообщает о критическом состоянии.
+func (f *FnForecast) NeedsReplacement() bool {
+	return f.Horizon > 0 || f.Flags.Critical()
+}
+
+// FnForecaster накапливает значения LastFD и прогнозирует дату окончания работы ФН.
+type FnForecaster struct {
+	cfg     FnForecastConfig
+	mu      sync.Mutex
+	samples []FnSample
+	serial  string // Номер ФН, к которому относятся замеры
+}
+
+// NewFnForecaster создает прогнозировщик с начальными замерами (могут быть nil).
+func NewFnForecaster(cfg FnForecastConfig, samples ...FnSample) *FnForecaster {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = DefaultFnCapacity
+	}
+	if cfg.Horizons == nil {
+		cfg.Horizons = DefaultFnHorizons
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	f := &FnForecaster{cfg: cfg}
+	for _, s := range samples {
+		f.AddSample(s)
+	}
+	return f
+}
+
+// AddSample добавляет замер номера последнего ФД.
+func (f *FnForecaster) AddSample(s FnSample) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.samples = append(f.samples, s)
+	sort.Slice(f.samples, func(i, j int) bool { return f.samples[i].At.Before(f.samples[j].At) })
+}
+
+// Samples возвращает копию накопленных замеров (для сохранения между запусками).
+func (f *FnForecaster) Samples() []FnSample {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FnSample(nil), f.samples...)
+}
+
+// Poll считывает статус ФН, добавляет замер и возвращает прогноз.
+// При смене ФН накопленные замеры сбрасываются. Если замеров нет, в качестве
+// начальной точки используется дата первого документа в архиве ФН (отчета о регистрации).
+func (f *FnForecaster) Poll(drv driver.Driver) (*FnForecast, error) {
+	fn, err := drv.GetFnStatus()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+	}
+	f.mu.Lock()
+	if f.serial != "" && f.serial != fn.Serial {
+		f.samples = nil
+	}
+	f.serial = fn.Serial
+	f.mu.Unlock()
+	if len(f.Samples()) == 0 && fn.LastFD > 1 {
+		if xmlDoc, err := drv.GetDocumentXMLFromFN(1); err == nil {
+			if doc, err := fndoc.Parse(xmlDoc); err == nil && !doc.DocHeader().DateTime.IsZero() {
+				f.AddSample(FnSample{At: doc.DocHeader().DateTime, LastFD: 1})
+			}
+		}
+	}
+	f.AddSample(FnSample{At: f.cfg.Now(), LastFD: fn.LastFD})
+	return f.Forecast(fn)
+}
+
+// Forecast рассчитывает прогноз по статусу ФН и накопленным замерам.
+func (f *FnForecaster) Forecast(fn *driver.FnStatus) (*FnForecast, error) {
+	flags, err := fn.Warnings()
+	if err != nil {
+		return nil, err
+	}
+	now := f.cfg.Now()
+	res := &FnForecast{Flags: flags}
+	res.Warnings = append(res.Warnings, flags.Messages()...)
+
+	if fn.Valid != "" {
+		valid, err := fn.ValidUntil()
+		if err != nil {
+			return nil, err
+		}
+		res.ValidUntil = valid
+		res.EndAt = valid
+		res.EndReason = FnEndValidity
+	}
+
+	res.DocsPerDay = f.docsPerDay()
+	if res.DocsPerDay > 0 {
+		left := float64(f.cfg.Capacity - fn.LastFD)
+		if left < 0 {
+			left = 0
+		}
+		res.FullAt = now.Add(time.Duration(left / res.DocsPerDay * float64(24*time.Hour)))
+		if res.EndAt.IsZero() || res.FullAt.Before(res.EndAt) {
+			res.EndAt = res.FullAt
+			res.EndReason = FnEndMemory
+		}
+	}
+
+	if res.EndAt.IsZero() {
+		return res, nil
+	}
+	res.Remaining = res.EndAt.Sub(now)
+	for _, h := range f.cfg.Horizons {
+		if res.Remaining <= h && (res.Horizon == 0 || h < res.Horizon) {
+			res.Horizon = h
+		}
+	}
+	if res.Horizon > 0 {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("до окончания работы ФН (%s) осталось %d дн., дата: %s",
+			res.EndReason, int(res.Remaining.Hours()/24), res.EndAt.Format("02.01.2006")))
+	}
+	return res, nil
+}
+
+// docsPerDay возвращает среднюю интенсивность документооборота по крайним замерам.
+func (f *FnForecaster) docsPerDay() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.samples) < 2 {
+		return 0
+	}
+	first, last := f.samples[0], f.samples[len(f.samples)-1]
+	days := last.At.Sub(first.At).Hours() / 24
+	docs := last.LastFD - first.LastFD
+	if days <= 0 || docs <= 0 {
+		return 0
+	}
+	return float64(docs) / days
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mitsuscanner/driver"
+)
+
+func TestFnForecastMemory(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	drv := &fakeDriver{
+		fn: driver.FnStatus{LastFD: 9001, Valid: "2025-03-01", Flag: "08"},
+		docs: map[int]string{
+			1: `<DocXML FORM="1"><T1012>2024-01-01T12:00:00</T1012></DocXML>`,
+		},
+	}
+	// 9000 документов за 60 дней = 150 документов в день; до 10000 - 6.66 дня
+	f := NewFnForecaster(FnForecastConfig{Capacity: 10000, Now: func() time.Time { return now }})
+	res, err := f.Poll(drv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.DocsPerDay != 150 || res.EndReason != FnEndMemory {
+		t.Fatalf("unexpected forecast: %+v", res)
+	}
+	if res.Horizon != 7*24*time.Hour || !res.NeedsReplacement() {
+		t.Fatalf("unexpected horizon: %v", res.Horizon)
+	}
+	if len(res.Warnings) != 2 || !res.Flags.Has(driver.FnWarnOfdTimeout) {
+		t.Fatalf("unexpected warnings: %v", res.Warnings)
+	}
+	if len(f.Samples()) != 2 {
+		t.Fatalf("unexpected samples: %v", f.Samples())
+	}
+}
+
+func TestFnForecastValidity(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFnForecaster(FnForecastConfig{Now: func() time.Time { return now }},
+		FnSample{At: now.Add(-24 * time.Hour), LastFD: 100})
+	res, err := f.Forecast(&driver.FnStatus{LastFD: 110, Valid: "2024-03-20"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.EndReason != FnEndValidity || res.Horizon != 30*24*time.Hour {
+		t.Fatalf("unexpected forecast: %+v", res)
+	}
+
+	res, err = f.Forecast(&driver.FnStatus{LastFD: 110, Valid: "2025-03-20"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Horizon != 0 || res.NeedsReplacement() || len(res.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %+v", res)
+	}
+}
@@ -0,0 +1,479 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/fndoc"
+)
+
+// ErrFnSwapPending возвращается RunFnReplacement, если архив ФН закрыт, а новый
+// ФН еще не установлен. После установки ФН процедуру нужно запустить повторно.
+var ErrFnSwapPending = errors.New("ожидается установка нового ФН")
+
+// ErrFnReplaceDone возвращается RunFnReplacement, если файл состояния относится
+// к уже завершенной замене ФН. Для новой замены нужен другой файл состояния
+// (и файл выгрузки архива).
+var ErrFnReplaceDone = errors.New("замена ФН по этому файлу состояния уже завершена")
+
+// FnReplaceStep - шаг процедуры замены ФН.
+type FnReplaceStep string
+
+const (
+	FnReplaceStepPreflight    FnReplaceStep = "preflight"     // Проверки перед закрытием архива
+	FnReplaceStepCloseArchive FnReplaceStep = "close_archive" // Закрытие архива старого ФН
+	FnReplaceStepSwap         FnReplaceStep = "swap"          // Физическая замена ФН
+	FnReplaceStepVerify       FnReplaceStep = "verify"        // Проверка нового ФН
+	FnReplaceStepReregister   FnReplaceStep = "reregister"    // Перерегистрация
+	FnReplaceStepDone         FnReplaceStep = "done"
+)
+
+// FnReplaceState - сохраняемое состояние процедуры замены ФН.
+type FnReplaceState struct {
+	Step      FnReplaceStep `json:"step"`
+	StartedAt time.Time     `json:"started_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+
+	OldFnSerial string          `json:"old_fn_serial"`
+	OldLastFD   int             `json:"old_last_fd"`
+	RegData     *driver.RegData `json:"reg_data,omitempty"` // Параметры регистрации до замены ФН
+	ExportPath  string          `json:"export_path"`
+	ExportedFD  int             `json:"exported_fd"` // Последний выгруженный ФД
+
+	CloseFD int    `json:"close_fd"` // Отчет о закрытии ФН
+	CloseFP string `json:"close_fp"`
+
+	NewFnSerial string `json:"new_fn_serial"`
+	Reasons     []int  `json:"reasons"`
+	RegFD       string `json:"reg_fd"` // Отчет об изменении параметров регистрации
+	RegFP       string `json:"reg_fp"`
+
+	Log []string `json:"log"`
+}
+
+// LoadFnReplaceState загружает состояние процедуры из файла.
+// Если файла нет, возвращается новое состояние (шаг FnReplaceStepPreflight).
+func LoadFnReplaceState(path string) (*FnReplaceState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &FnReplaceState{Step: FnReplaceStepPreflight}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения состояния замены ФН: %w", err)
+	}
+	var s FnReplaceState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("ошибка разбора состояния замены ФН: %w", err)
+	}
+	if s.Step == "" {
+		s.Step = FnReplaceStepPreflight
+	}
+	return &s, nil
+}
+
+// Save атомарно сохраняет состояние в файл.
+func (s *FnReplaceState) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("ошибка сохранения состояния замены ФН: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("ошибка сохранения состояния замены ФН: %w", err)
+	}
+	return nil
+}
+
+// Report возвращает текстовый отчет о замене ФН.
+func (s *FnReplaceState) Report() string {
+	var b strings.Builder
+	b.WriteString("Замена ФН\n")
+	fmt.Fprintf(&b, "Начало: %s\n", s.StartedAt.Format("02.01.2006 15:04:05"))
+	if s.Step == FnReplaceStepDone {
+		fmt.Fprintf(&b, "Завершение: %s\n", s.UpdatedAt.Format("02.01.2006 15:04:05"))
+	} else {
+		fmt.Fprintf(&b, "Текущий шаг: %s\n", s.Step)
+	}
+	fmt.Fprintf(&b, "Старый ФН: %s (последний ФД: %d)\n", s.OldFnSerial, s.OldLastFD)
+	if s.ExportPath != "" {
+		fmt.Fprintf(&b, "Архив выгружен: %s (по ФД %d)\n", s.ExportPath, s.ExportedFD)
+	}
+	if s.CloseFD > 0 {
+		fmt.Fprintf(&b, "Отчет о закрытии ФН: ФД %d, ФП %s\n", s.CloseFD, s.CloseFP)
+	}
+	if s.NewFnSerial != "" {
+		fmt.Fprintf(&b, "Новый ФН: %s\n", s.NewFnSerial)
+	}
+	if len(s.Reasons) > 0 {
+		reasons := make([]string, len(s.Reasons))
+		for i, r := range s.Reasons {
+			reasons[i] = fmt.Sprint(r)
+		}
+		fmt.Fprintf(&b, "Причины перерегистрации: %s\n", strings.Join(reasons, ","))
+	}
+	if s.RegFD != "" {
+		fmt.Fprintf(&b, "Отчет об изменении параметров регистрации: ФД %s, ФП %s\n", s.RegFD, s.RegFP)
+	}
+	if len(s.Log) > 0 {
+		b.WriteString("\nЖурнал:\n")
+		for _, l := range s.Log {
+			b.WriteString(l + "\n")
+		}
+	}
+	return b.String()
+}
+
+// FnReplaceOptions задает параметры процедуры замены ФН.
+type FnReplaceOptions struct {
+	// StatePath - файл состояния процедуры (обязателен).
+	StatePath string
+	// ExportPath - файл выгрузки архива старого ФН (обязателен).
+	// Повторный запуск продолжает выгрузку (см. ExportArchiveFile).
+	ExportPath   string
+	ExportFormat ExportFormat
+	// Request - параметры перерегистрации (nil - текущие параметры регистрации).
+	Request *driver.RegistrationRequest
+	// Cashier - ФИО кассира для отчетов (пустое - текущий кассир ККТ, см. GetCashier).
+	Cashier    string
+	CashierINN string
+	// WaitSwap вызывается после закрытия архива, пока в ККТ установлен старый ФН.
+	// Должна вернуть управление после установки нового ФН. Если nil,
+	// RunFnReplacement возвращает ErrFnSwapPending.
+	WaitSwap func(ctx context.Context, state *FnReplaceState) error
+	// DrainOfd, если задана, вызывается на шаге проверок при наличии
+	// непереданных в ОФД документов (см. CloseGuardOptions.DrainOfd).
+	DrainOfd func(ctx context.Context, drv driver.Driver) error
+	// OnStep вызывается при переходе к следующему шагу.
+	OnStep func(state *FnReplaceState)
+	// Now - источник текущего времени (для тестов).
+	Now func() time.Time
+}
+
+// RunFnReplacement выполняет замену ФН: проверки (см. CheckCloseFiscalArchive,
+// архив выгружен), закрытие архива, ожидание установки нового ФН,
+// проверку нового ФН и перерегистрацию. Состояние сохраняется после каждого шага,
+// поэтому прерванную процедуру можно продолжить повторным вызовом с тем же StatePath.
+func RunFnReplacement(ctx context.Context, drv driver.Driver, opts FnReplaceOptions) (*FnReplaceState, error) {
+	if opts.StatePath == "" || opts.ExportPath == "" {
+		return nil, fmt.Errorf("не заданы файлы состояния и выгрузки архива")
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	state, err := LoadFnReplaceState(opts.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	if state.Step == FnReplaceStepDone {
+		return state, fmt.Errorf("%w (ФН %s заменен на %s): %s", ErrFnReplaceDone, state.OldFnSerial, state.NewFnSerial, opts.StatePath)
+	}
+	if opts.Cashier == "" {
+		name, inn, err := drv.GetCashier()
+		if err != nil {
+			return state, fmt.Errorf("ошибка получения кассира: %w", err)
+		}
+		if strings.TrimSpace(name) == "" {
+			return state, fmt.Errorf("не задан кассир для отчетов замены ФН")
+		}
+		opts.Cashier = name
+		if opts.CashierINN == "" {
+			opts.CashierINN = inn
+		}
+	}
+	if state.StartedAt.IsZero() {
+		state.StartedAt = opts.Now()
+	}
+	r := &fnReplacer{drv: drv, opts: opts, state: state}
+
+	for state.Step != FnReplaceStepDone {
+		if err := ctx.Err(); err != nil {
+			return state, err
+		}
+		var next FnReplaceStep
+		switch state.Step {
+		case FnReplaceStepPreflight:
+			next, err = r.preflight(ctx)
+		case FnReplaceStepCloseArchive:
+			next, err = r.closeArchive(ctx)
+		case FnReplaceStepSwap:
+			next, err = r.waitSwap(ctx)
+		case FnReplaceStepVerify:
+			next, err = r.verify()
+		case FnReplaceStepReregister:
+			next, err = r.reregister()
+		default:
+			err = fmt.Errorf("неизвестный шаг замены ФН: %s", state.Step)
+		}
+		if err != nil {
+			r.logf("%s: %v", state.Step, err)
+			if saveErr := r.save(); saveErr != nil {
+				return state, saveErr
+			}
+			return state, err
+		}
+		state.Step = next
+		if err := r.save(); err != nil {
+			return state, err
+		}
+		if opts.OnStep != nil {
+			opts.OnStep(state)
+		}
+	}
+	return state, nil
+}
+
+type fnReplacer struct {
+	drv   driver.Driver
+	opts  FnReplaceOptions
+	state *FnReplaceState
+}
+
+func (r *fnReplacer) logf(format string, args ...interface{}) {
+	r.state.Log = append(r.state.Log, r.opts.Now().Format("15:04:05")+" "+fmt.Sprintf(format, args...))
+}
+
+func (r *fnReplacer) save() error {
+	r.state.UpdatedAt = r.opts.Now()
+	return r.state.Save(r.opts.StatePath)
+}
+
+func (r *fnReplacer) fnStatus() (*driver.FnStatus, int, error) {
+	fn, err := r.drv.GetFnStatus()
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+	}
+	phase, err := fn.PhaseCode()
+	if err != nil {
+		return nil, 0, err
+	}
+	return fn, phase, nil
+}
+
+func (r *fnReplacer) preflight(ctx context.Context) (FnReplaceStep, error) {
+	fn, phase, err := r.fnStatus()
+	if err != nil {
+		return "", err
+	}
+	if phase != driver.FnPhaseFiscal && phase != driver.FnPhaseClosed {
+		return "", fmt.Errorf("ФН %s не находится в фискальном режиме (фаза %s)", fn.Serial, fn.Phase)
+	}
+	r.state.OldFnSerial = fn.Serial
+	r.state.OldLastFD = fn.LastFD
+
+	if r.state.RegData == nil {
+		reg, err := r.drv.GetRegistrationData()
+		if err != nil {
+			return "", fmt.Errorf("ошибка получения данных регистрации: %w", err)
+		}
+		reg.FnSerial = fn.Serial
+		r.state.RegData = reg
+	}
+
+	// Архив уже закрытого ФН проверять не нужно
+	if phase == driver.FnPhaseFiscal {
+		reasons, err := CheckCloseFiscalArchive(r.drv)
+		if err != nil {
+			return "", err
+		}
+		if r.opts.DrainOfd != nil && (&CloseRefusedError{Reasons: reasons}).Has(CloseBlockOfdUnsent) {
+			if err := r.opts.DrainOfd(ctx, r.drv); err != nil {
+				return "", fmt.Errorf("ошибка отправки документов в ОФД: %w", err)
+			}
+			if reasons, err = CheckCloseFiscalArchive(r.drv); err != nil {
+				return "", err
+			}
+		}
+		if len(reasons) > 0 {
+			return "", &CloseRefusedError{Reasons: reasons}
+		}
+		// Документы могли быть сформированы во время проверок (например, при отправке в ОФД)
+		if fn, _, err = r.fnStatus(); err != nil {
+			return "", err
+		}
+		r.state.OldLastFD = fn.LastFD
+	}
+
+	n, err := r.exportArchive(ctx, fn.LastFD)
+	if err != nil {
+		return "", err
+	}
+	r.logf("проверки пройдены, выгружено документов: %d (всего по ФД %d)", n, r.state.ExportedFD)
+
+	if phase == driver.FnPhaseClosed {
+		r.logf("архив ФН %s уже закрыт", fn.Serial)
+		return FnReplaceStepSwap, nil
+	}
+	return FnReplaceStepCloseArchive, nil
+}
+
+func (r *fnReplacer) closeArchive(ctx context.Context) (FnReplaceStep, error) {
+	// Архив мог быть закрыт до прерывания процедуры
+	fn, phase, err := r.fnStatus()
+	if err != nil {
+		return "", err
+	}
+	if fn.Serial != r.state.OldFnSerial {
+		if r.state.ExportedFD < r.state.CloseFD {
+			r.logf("отчет о закрытии ФН (ФД %d) не выгружен: ФН %s уже извлечен", r.state.CloseFD, r.state.OldFnSerial)
+		}
+		return FnReplaceStepSwap, nil
+	}
+	if phase == driver.FnPhaseClosed {
+		r.logf("архив ФН %s уже закрыт", r.state.OldFnSerial)
+		return r.exportCloseReport(ctx, fn.LastFD)
+	}
+	if err := r.drv.SetCashier(r.opts.Cashier, r.opts.CashierINN); err != nil {
+		return "", fmt.Errorf("ошибка установки кассира: %w", err)
+	}
+	res, err := CloseFiscalArchiveGuarded(ctx, r.drv, CloseGuardOptions{})
+	if err != nil {
+		return "", fmt.Errorf("ошибка закрытия архива ФН: %w", err)
+	}
+	r.state.CloseFD, r.state.CloseFP = res.FD, res.FP
+	r.logf("архив ФН %s закрыт: ФД %d, ФП %s", r.state.OldFnSerial, res.FD, res.FP)
+	// Сохраняем номер отчета до выгрузки: при ошибке выгрузки шаг повторяется
+	// без повторного закрытия архива
+	if err := r.save(); err != nil {
+		return "", err
+	}
+	return r.exportCloseReport(ctx, res.FD)
+}
+
+// exportCloseReport дописывает в выгрузку архива документы по lastFD
+// включительно (отчет о закрытии ФН).
+func (r *fnReplacer) exportCloseReport(ctx context.Context, lastFD int) (FnReplaceStep, error) {
+	if r.state.ExportedFD >= lastFD {
+		return FnReplaceStepSwap, nil
+	}
+	if _, err := r.exportArchive(ctx, lastFD); err != nil {
+		return "", err
+	}
+	r.logf("выгружен архив ФН по ФД %d", lastFD)
+	return FnReplaceStepSwap, nil
+}
+
+// exportArchive выгружает архив ФН по lastFD включительно и проверяет, что
+// в выгрузке нет пропущенных документов.
+func (r *fnReplacer) exportArchive(ctx context.Context, lastFD int) (int, error) {
+	n, err := ExportArchiveFile(ctx, r.drv, r.opts.ExportPath, ExportOptions{Format: r.opts.ExportFormat, ToFD: lastFD})
+	if err != nil {
+		return n, fmt.Errorf("ошибка выгрузки архива ФН: %w", err)
+	}
+	f, err := os.Open(r.opts.ExportPath)
+	if err != nil {
+		return n, fmt.Errorf("ошибка проверки выгрузки архива: %w", err)
+	}
+	exported, err := LastExportedFD(f, r.opts.ExportFormat)
+	f.Close()
+	if err != nil {
+		return n, err
+	}
+	if exported < lastFD {
+		return n, fmt.Errorf("архив ФН выгружен не полностью: ФД %d из %d", exported, lastFD)
+	}
+	r.state.ExportPath = r.opts.ExportPath
+	r.state.ExportedFD = exported
+	return n, nil
+}
+
+func (r *fnReplacer) waitSwap(ctx context.Context) (FnReplaceStep, error) {
+	fn, err := r.drv.GetFnStatus()
+	if err == nil && fn.Serial != "" && fn.Serial != r.state.OldFnSerial {
+		return FnReplaceStepVerify, nil
+	}
+	if r.opts.WaitSwap == nil {
+		return "", ErrFnSwapPending
+	}
+	if err := r.opts.WaitSwap(ctx, r.state); err != nil {
+		return "", err
+	}
+	return FnReplaceStepVerify, nil
+}
+
+func (r *fnReplacer) verify() (FnReplaceStep, error) {
+	fn, phase, err := r.fnStatus()
+	if err != nil {
+		return "", err
+	}
+	if fn.Serial == r.state.OldFnSerial {
+		return "", fmt.Errorf("установлен прежний ФН %s", fn.Serial)
+	}
+	r.state.NewFnSerial = fn.Serial
+	// Перерегистрация могла быть выполнена до прерывания процедуры
+	if phase == driver.FnPhaseFiscal {
+		return r.alreadyRegistered(fn)
+	}
+	if phase != driver.FnPhaseReady {
+		return "", fmt.Errorf("новый ФН %s не готов к фискализации (фаза %s)", fn.Serial, fn.Phase)
+	}
+	r.logf("установлен новый ФН %s", fn.Serial)
+	return FnReplaceStepReregister, nil
+}
+
+func (r *fnReplacer) reregister() (FnReplaceStep, error) {
+	fn, phase, err := r.fnStatus()
+	if err != nil {
+		return "", err
+	}
+	if phase == driver.FnPhaseFiscal {
+		return r.alreadyRegistered(fn)
+	}
+	if r.state.RegData == nil {
+		return "", fmt.Errorf("не сохранены параметры регистрации")
+	}
+	req := r.state.RegData.Request()
+	if r.opts.Request != nil {
+		req = *r.opts.Request
+	}
+	plan, err := driver.PlanReregistration(r.state.RegData, req, fn)
+	if err != nil {
+		return "", err
+	}
+	r.state.Reasons = plan.Reasons
+	if err := r.drv.SetCashier(r.opts.Cashier, r.opts.CashierINN); err != nil {
+		return "", fmt.Errorf("ошибка установки кассира: %w", err)
+	}
+	resp, err := r.drv.Reregister(req, plan.Reasons)
+	if err != nil {
+		return "", fmt.Errorf("ошибка перерегистрации: %w", err)
+	}
+	r.state.RegFD, r.state.RegFP = resp.FdNumber, resp.FpNumber
+	r.logf("ККТ перерегистрирована (причины %s): ФД %s, ФП %s", plan.Base(), resp.FdNumber, resp.FpNumber)
+	return FnReplaceStepDone, nil
+}
+
+// alreadyRegistered завершает процедуру, если новый ФН уже зарегистрирован
+// (перерегистрация выполнена до прерывания), и находит в его архиве отчет о
+// регистрации для итогового отчета.
+func (r *fnReplacer) alreadyRegistered(fn *driver.FnStatus) (FnReplaceStep, error) {
+	r.logf("новый ФН %s уже зарегистрирован", fn.Serial)
+	if r.state.RegFD != "" {
+		return FnReplaceStepDone, nil
+	}
+	for fd := fn.LastFD; fd >= 1; fd-- {
+		xmlDoc, err := r.drv.GetDocumentXMLFromFN(fd)
+		if err != nil {
+			return "", fmt.Errorf("ошибка чтения ФД %d нового ФН: %w", fd, err)
+		}
+		doc, err := fndoc.Parse(xmlDoc)
+		if err != nil {
+			return "", fmt.Errorf("ошибка разбора ФД %d нового ФН: %w", fd, err)
+		}
+		if h := doc.DocHeader(); h.Form == fndoc.FormRegistration || h.Form == fndoc.FormReregistration {
+			r.state.RegFD, r.state.RegFP = strconv.Itoa(fd), h.FP
+			r.logf("отчет о регистрации нового ФН: ФД %s, ФП %s", r.state.RegFD, r.state.RegFP)
+			return FnReplaceStepDone, nil
+		}
+	}
+	return "", fmt.Errorf("в архиве нового ФН %s не найден отчет о регистрации", fn.Serial)
+}
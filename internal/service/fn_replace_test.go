@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mitsuscanner/driver"
+)
+
+func fnReplaceDriver() *fakeDriver {
+	drv := archiveDriver()
+	drv.fn.Serial = "9960440300000001"
+	drv.fn.Phase = "0x03"
+	drv.shift.State = ShiftStateClosed
+	drv.reg = &driver.RegData{RNM: "0000000001012345", Inn: "7707083893", TaxSystems: "0", OfdInn: "7704211201"}
+	drv.cashier = "Иванов И.И."
+	return drv
+}
+
+func TestRunFnReplacementResume(t *testing.T) {
+	dir := t.TempDir()
+	opts := FnReplaceOptions{
+		StatePath:  filepath.Join(dir, "fn_replace.json"),
+		ExportPath: filepath.Join(dir, "archive.jsonl"),
+	}
+	drv := fnReplaceDriver()
+
+	state, err := RunFnReplacement(context.Background(), drv, opts)
+	if !errors.Is(err, ErrFnSwapPending) {
+		t.Fatalf("expected swap pending, got %v", err)
+	}
+	// Отчет о закрытии ФН выгружается вместе с архивом
+	if state.Step != FnReplaceStepSwap || state.CloseFD != 5 || state.ExportedFD != 5 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+
+	// Повторный запуск без замены ФН не закрывает архив еще раз
+	if _, err := RunFnReplacement(context.Background(), drv, opts); !errors.Is(err, ErrFnSwapPending) {
+		t.Fatalf("expected swap pending, got %v", err)
+	}
+
+	drv.fn = driver.FnStatus{Serial: "9960440300000002", Phase: "0x01"}
+	state, err = RunFnReplacement(context.Background(), drv, opts)
+	if err != nil {
+		t.Fatalf("RunFnReplacement: %v", err)
+	}
+	if state.Step != FnReplaceStepDone || state.NewFnSerial != "9960440300000002" || state.RegFD != "1" || state.RegFP != "987654321" {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	if !reflect.DeepEqual(drv.commands, []string{"FN_CLOSE", "REREG [1]"}) {
+		t.Fatalf("unexpected commands: %v", drv.commands)
+	}
+	saved, err := LoadFnReplaceState(opts.StatePath)
+	if err != nil || saved.Step != FnReplaceStepDone {
+		t.Fatalf("unexpected saved state: %+v, %v", saved, err)
+	}
+	if report := state.Report(); !strings.Contains(report, "Новый ФН: 9960440300000002") {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+	// Завершенная процедура не выполняется повторно
+	if _, err := RunFnReplacement(context.Background(), drv, opts); !errors.Is(err, ErrFnReplaceDone) {
+		t.Fatalf("expected finished state error, got %v", err)
+	}
+}
+
+func TestRunFnReplacementRequiresCashier(t *testing.T) {
+	dir := t.TempDir()
+	drv := fnReplaceDriver()
+	drv.cashier = ""
+	opts := FnReplaceOptions{
+		StatePath:  filepath.Join(dir, "fn_replace.json"),
+		ExportPath: filepath.Join(dir, "archive.jsonl"),
+	}
+	if _, err := RunFnReplacement(context.Background(), drv, opts); err == nil || !strings.Contains(err.Error(), "кассир") {
+		t.Fatalf("expected cashier error, got %v", err)
+	}
+	if len(drv.commands) != 0 {
+		t.Fatalf("unexpected commands: %v", drv.commands)
+	}
+}
+
+func TestRunFnReplacementWaitSwap(t *testing.T) {
+	dir := t.TempDir()
+	drv := fnReplaceDriver()
+	opts := FnReplaceOptions{
+		StatePath:    filepath.Join(dir, "fn_replace.json"),
+		ExportPath:   filepath.Join(dir, "archive.csv"),
+		ExportFormat: ExportCSV,
+		WaitSwap: func(ctx context.Context, s *FnReplaceState) error {
+			drv.fn = driver.FnStatus{Serial: "9960440300000002", Phase: "0x01"}
+			return nil
+		},
+	}
+	state, err := RunFnReplacement(context.Background(), drv, opts)
+	if err != nil || state.Step != FnReplaceStepDone {
+		t.Fatalf("unexpected result: %+v, %v", state, err)
+	}
+}
+
+func TestRunFnReplacementPreflight(t *testing.T) {
+	dir := t.TempDir()
+	drv := fnReplaceDriver()
+	drv.shift.State = "1"
+	drv.ofd.Count = 2
+	opts := FnReplaceOptions{
+		StatePath:  filepath.Join(dir, "fn_replace.json"),
+		ExportPath: filepath.Join(dir, "archive.jsonl"),
+	}
+	state, err := RunFnReplacement(context.Background(), drv, opts)
+	if err == nil || !strings.Contains(err.Error(), "смена") || !strings.Contains(err.Error(), "ОФД") {
+		t.Fatalf("expected preflight error, got %v", err)
+	}
+	if state.Step != FnReplaceStepPreflight || len(drv.commands) != 0 {
+		t.Fatalf("unexpected state: %+v, commands %v", state, drv.commands)
+	}
+}
+
+func TestRunFnReplacementBrokenExport(t *testing.T) {
+	dir := t.TempDir()
+	drv := fnReplaceDriver()
+	delete(drv.docs, 2)
+	opts := FnReplaceOptions{
+		StatePath:  filepath.Join(dir, "fn_replace.json"),
+		ExportPath: filepath.Join(dir, "archive.jsonl"),
+	}
+	// Архив не закрывается, пока хотя бы один документ не выгружен
+	state, err := RunFnReplacement(context.Background(), drv, opts)
+	if !errors.Is(err, ErrArchiveIncomplete) || state.Step != FnReplaceStepPreflight || len(drv.commands) != 0 {
+		t.Fatalf("unexpected result: %v, %+v, commands %v", err, state, drv.commands)
+	}
+}
+
+func TestRunFnReplacementAlreadyRegistered(t *testing.T) {
+	dir := t.TempDir()
+	drv := fnReplaceDriver()
+	opts := FnReplaceOptions{
+		StatePath:  filepath.Join(dir, "fn_replace.json"),
+		ExportPath: filepath.Join(dir, "archive.jsonl"),
+	}
+	if _, err := RunFnReplacement(context.Background(), drv, opts); !errors.Is(err, ErrFnSwapPending) {
+		t.Fatalf("expected swap pending, got %v", err)
+	}
+
+	// Перерегистрация выполнена, но процедура прервана до сохранения состояния
+	drv.fn = driver.FnStatus{Serial: "9960440300000002", Phase: "0x03", LastFD: 2}
+	drv.docs = map[int]string{
+		1: `<DocXML FORM="11"><T1040>1</T1040><T1077>555000111</T1077></DocXML>`,
+		2: `<DocXML FORM="2"><T1040>2</T1040><T1038>1</T1038><T1077>555000222</T1077></DocXML>`,
+	}
+	state, err := RunFnReplacement(context.Background(), drv, opts)
+	if err != nil || state.Step != FnReplaceStepDone {
+		t.Fatalf("unexpected result: %+v, %v", state, err)
+	}
+	if state.RegFD != "1" || state.RegFP != "555000111" {
+		t.Fatalf("registration report not recorded: %+v", state)
+	}
+	if report := state.Report(); !strings.Contains(report, "ФД 1, ФП 555000111") {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+}
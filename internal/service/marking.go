@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"mitsuscanner/driver"
+)
+
+// MarkCheckOptions задает поведение CheckMarkCode.
+type MarkCheckOptions struct {
+	// Online - запросить статус КМ в ОИСМ после проверки в ФН.
+	Online bool
+	// AllowOffline - если ОИСМ недоступен, принять КМ по результату проверки ФН
+	// (товар продается, уведомление будет передано позже).
+	AllowOffline bool
+	// Decide, если задана, принимает решение о принятии КМ по результатам
+	// проверки (например, спрашивает кассира). По умолчанию КМ принимается,
+	// если нет отрицательных результатов (MarkCheckResult.Problems).
+	Decide func(res *driver.MarkCheckResult) bool
+}
+
+// MarkRejectedError возвращается CheckMarkCode, если КМ отклонен.
+type MarkRejectedError struct {
+	Result   driver.MarkCheckResult
+	Problems []string
+}
+
+func (e *MarkRejectedError) Error() string {
+	if len(e.Problems) == 0 {
+		return "код маркировки отклонен"
+	}
+	return "код маркировки отклонен: " + strings.Join(e.Problems, "; ")
+}
+
+// CheckMarkCode выполняет проверку кода маркировки перед добавлением позиции
+// в чек: проверка в ФН, при opts.Online - запрос в ОИСМ, затем принятие или
+// отклонение КМ. Принятый КМ добавляется в позицию через ItemPosition.Mark.
+// Если КМ отклонен, возвращается *MarkRejectedError вместе с результатом проверки.
+func CheckMarkCode(ctx context.Context, drv driver.Driver, mark driver.ItemMark, quantity float64, opts MarkCheckOptions) (*driver.MarkCheckResult, error) {
+	res, err := drv.MarkCheck(mark, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Отрицательный результат ФН окончателен, запрос в ОИСМ не нужен
+	if res.FnChecked() && !res.FnValid() {
+		return res, rejectMark(drv, res)
+	}
+
+	if opts.Online {
+		if err := ctx.Err(); err != nil {
+			return res, rejectMarkWith(drv, err)
+		}
+		online, err := drv.MarkRequestOism()
+		switch {
+		case err == nil:
+			online.Reason = res.Reason
+			if online.CodeType == 0 {
+				online.CodeType = res.CodeType
+			}
+			res = online
+		case opts.AllowOffline:
+			res.Offline = true
+		default:
+			return res, rejectMarkWith(drv, err)
+		}
+	}
+
+	accept := len(res.Problems()) == 0
+	if opts.Decide != nil {
+		accept = opts.Decide(res)
+	}
+	if !accept {
+		return res, rejectMark(drv, res)
+	}
+	if err := drv.MarkAccept(); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// rejectMark отклоняет КМ и возвращает *MarkRejectedError.
+func rejectMark(drv driver.Driver, res *driver.MarkCheckResult) error {
+	if err := drv.MarkReject(); err != nil {
+		return err
+	}
+	return &MarkRejectedError{Result: *res, Problems: res.Problems()}
+}
+
+// rejectMarkWith отклоняет КМ после ошибки проверки и возвращает исходную ошибку.
+func rejectMarkWith(drv driver.Driver, cause error) error {
+	if err := drv.MarkReject(); err != nil {
+		return fmt.Errorf("%w (ошибка отклонения КМ: %v)", cause, err)
+	}
+	return cause
+}
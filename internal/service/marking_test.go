@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"mitsuscanner/driver"
+)
+
+const testMarkCode = "0104601234567890215abcDEF123456\x1d91EE07\x1d92abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQR="
+
+func TestCheckMarkCodeAccepted(t *testing.T) {
+	drv := &fakeDriver{
+		markFn:   driver.MarkCheckResult{Result: 0x03, CodeType: 5},
+		markOism: driver.MarkCheckResult{Result: 0x0F, OismCode: 0, ItemStatus: driver.OismItemStatusCorrect},
+	}
+	mark := driver.ItemMark{Code: testMarkCode, Status: driver.MarkStatusPieceSold}
+	res, err := CheckMarkCode(context.Background(), drv, mark, 1, MarkCheckOptions{Online: true})
+	if err != nil {
+		t.Fatalf("CheckMarkCode: %v", err)
+	}
+	if !res.OismValid() || res.CodeType != 5 || res.Offline {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if !reflect.DeepEqual(drv.commands, []string{"MARK_CHECK", "MARK_ONLINE", "MARK_ACCEPT"}) {
+		t.Errorf("unexpected commands: %v", drv.commands)
+	}
+}
+
+func TestCheckMarkCodeRejected(t *testing.T) {
+	// ФН отклонил КМ: ОИСМ не запрашивается
+	drv := &fakeDriver{markFn: driver.MarkCheckResult{Result: 0x01}}
+	mark := driver.ItemMark{Code: testMarkCode, Status: driver.MarkStatusPieceSold}
+	_, err := CheckMarkCode(context.Background(), drv, mark, 1, MarkCheckOptions{Online: true})
+	var rejected *MarkRejectedError
+	if !errors.As(err, &rejected) || len(rejected.Problems) != 1 {
+		t.Fatalf("expected MarkRejectedError, got %v", err)
+	}
+	if !reflect.DeepEqual(drv.commands, []string{"MARK_CHECK", "MARK_REJECT"}) {
+		t.Errorf("unexpected commands: %v", drv.commands)
+	}
+
+	// ОИСМ: оборот товара приостановлен
+	drv = &fakeDriver{
+		markFn:   driver.MarkCheckResult{Result: 0x03},
+		markOism: driver.MarkCheckResult{Result: 0x07, ItemStatus: driver.OismItemStatusSuspended},
+	}
+	_, err = CheckMarkCode(context.Background(), drv, mark, 1, MarkCheckOptions{Online: true})
+	if !errors.As(err, &rejected) || len(rejected.Problems) != 2 {
+		t.Fatalf("expected MarkRejectedError with 2 problems, got %v", err)
+	}
+
+	// Кассир принимает решение сам
+	drv.commands = nil
+	_, err = CheckMarkCode(context.Background(), drv, mark, 1, MarkCheckOptions{
+		Online: true,
+		Decide: func(res *driver.MarkCheckResult) bool { return true },
+	})
+	if err != nil || drv.commands[len(drv.commands)-1] != "MARK_ACCEPT" {
+		t.Fatalf("expected accept by Decide: %v, %v", err, drv.commands)
+	}
+}
+
+func TestCheckMarkCodeOffline(t *testing.T) {
+	drv := &fakeDriver{
+		markFn:  driver.MarkCheckResult{Result: 0x03},
+		oismErr: errors.New("нет связи с ОИСМ"),
+	}
+	mark := driver.ItemMark{Code: testMarkCode, Status: driver.MarkStatusMeasuredSold, Fraction: &driver.MarkFraction{Numerator: 1, Denominator: 4}}
+
+	if _, err := CheckMarkCode(context.Background(), drv, mark, 0, MarkCheckOptions{Online: true}); err == nil {
+		t.Fatal("expected error without AllowOffline")
+	}
+	if drv.commands[len(drv.commands)-1] != "MARK_REJECT" {
+		t.Errorf("КМ должен быть отклонен: %v", drv.commands)
+	}
+
+	drv.commands = nil
+	res, err := CheckMarkCode(context.Background(), drv, mark, 0, MarkCheckOptions{Online: true, AllowOffline: true})
+	if err != nil || !res.Offline {
+		t.Fatalf("offline accept: %+v, %v", res, err)
+	}
+	if !reflect.DeepEqual(drv.commands, []string{"MARK_CHECK", "MARK_ONLINE", "MARK_ACCEPT"}) {
+		t.Errorf("unexpected commands: %v", drv.commands)
+	}
+}
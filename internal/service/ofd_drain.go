@@ -0,0 +1,252 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/ofdclient"
+)
+
+// OfdDrainOptions задает поведение DrainOfdQueue.
+type OfdDrainOptions struct {
+	// Client - клиент ОФД (nil - TCP-клиент с таймаутом 300 с).
+	Client ofdclient.Client
+	// Logger - журнал клиента по умолчанию (если Client не задан).
+	Logger func(msg string)
+	// Limit - максимальное количество отправляемых документов
+	// (0 - столько, сколько было в очереди при запуске).
+	Limit int
+	// MaxAttempts - количество попыток отправки одного документа (0 - 5).
+	MaxAttempts int
+	// InitialBackoff - пауза после первой ошибки ОФД (0 - 2 с), далее удваивается.
+	InitialBackoff time.Duration
+	// MaxBackoff - максимальная пауза между попытками (0 - 2 мин).
+	MaxBackoff time.Duration
+	// OnProgress вызывается после каждой попытки отправки документа.
+	OnProgress func(p OfdDrainProgress)
+
+	// sleep - ожидание между попытками (для тестов).
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// OfdDrainProgress - результат одной попытки отправки документа.
+type OfdDrainProgress struct {
+	Number  int           // Порядковый номер документа в текущей отправке (с 1)
+	Total   int           // Сколько документов планируется отправить
+	Attempt int           // Номер попытки для этого документа (с 1)
+	Size    int           // Размер документа в байтах
+	Queued  int           // Документов в очереди ФН, включая этот (Total ограничен opts.Limit)
+	Err     error         // nil - документ отправлен, квитанция записана в ФН
+	Backoff time.Duration // Пауза перед следующей попыткой (при ошибке)
+}
+
+// OfdDrainSummary - итог отправки очереди ОФД.
+type OfdDrainSummary struct {
+	Initial   int     // Документов в очереди при запуске
+	Sent      int     // Отправлено документов
+	Failures  int     // Неудачных попыток отправки
+	Remaining int     // Документов в очереди после отправки
+	Errors    []error // Ошибки неудачных попыток
+	Elapsed   time.Duration
+}
+
+// DrainOfdQueue отправляет в ОФД неотправленные документы, пока очередь не
+// опустеет или не будет достигнут opts.Limit. Режим внешнего клиента
+// (OfdSettings.Client = "1") включается один раз на всю отправку и
+// восстанавливается по окончании. При ошибках ОФД документ отправляется
+// повторно с экспоненциально растущей паузой; после opts.MaxAttempts
+// неудач отправка прекращается. Ошибки ККТ прекращают отправку сразу.
+// Итог возвращается и при ошибке.
+func DrainOfdQueue(ctx context.Context, drv driver.Driver, opts OfdDrainOptions) (*OfdDrainSummary, error) {
+	start := time.Now()
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = 2 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 2 * time.Minute
+	}
+	if opts.sleep == nil {
+		opts.sleep = sleepContext
+	}
+
+	summary := &OfdDrainSummary{}
+	finish := func(err error) (*OfdDrainSummary, error) {
+		if status, serr := drv.GetOfdExchangeStatus(); serr == nil {
+			summary.Remaining = status.Count
+		}
+		summary.Elapsed = time.Since(start)
+		return summary, err
+	}
+
+	status, err := drv.GetOfdExchangeStatus()
+	if err != nil {
+		return summary, fmt.Errorf("ошибка получения статуса обмена с ОФД: %w", err)
+	}
+	summary.Initial = status.Count
+	if status.Count == 0 {
+		summary.Elapsed = time.Since(start)
+		return summary, nil
+	}
+	total, queued := status.Count, status.Count
+	if opts.Limit > 0 && opts.Limit < total {
+		total = opts.Limit
+	}
+
+	settings, restore, err := useExternalClient(drv)
+	if err != nil {
+		return summary, err
+	}
+	defer restore()
+	addr := fmt.Sprintf("%s:%d", settings.Addr, settings.Port)
+
+	fn, err := drv.GetFnStatus()
+	if err != nil {
+		return summary, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = ofdclient.New(ofdclient.Config{Timeout: 300 * time.Second, Logger: opts.Logger})
+		defer client.Close()
+	}
+
+	for summary.Sent < total {
+		if summary.Sent > 0 {
+			status, err := drv.GetOfdExchangeStatus()
+			if err != nil {
+				return finish(fmt.Errorf("ошибка получения статуса обмена с ОФД: %w", err))
+			}
+			if status.Count == 0 {
+				break
+			}
+			queued = status.Count
+		}
+
+		backoff := opts.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			if err := ctx.Err(); err != nil {
+				return finish(err)
+			}
+			doc, err := drv.OfdReadFullDocument()
+			if err != nil {
+				return finish(fmt.Errorf("ошибка чтения документа для ОФД: %w", err))
+			}
+
+			progress := OfdDrainProgress{Number: summary.Sent + 1, Total: total, Attempt: attempt, Size: len(doc), Queued: queued}
+			resp, err := sendOfdDocument(ctx, client, addr, fn, doc)
+			if err == nil {
+				if err := drv.OfdLoadReceipt(resp.RawMessage); err != nil {
+					return finish(fmt.Errorf("ошибка записи квитанции ОФД: %w", err))
+				}
+				summary.Sent++
+				if opts.OnProgress != nil {
+					opts.OnProgress(progress)
+				}
+				break
+			}
+
+			summary.Failures++
+			summary.Errors = append(summary.Errors, err)
+			progress.Err = err
+			if attempt >= opts.MaxAttempts {
+				if opts.OnProgress != nil {
+					opts.OnProgress(progress)
+				}
+				return finish(fmt.Errorf("документ не отправлен в ОФД после %d попыток: %w", attempt, err))
+			}
+			progress.Backoff = backoff
+			if opts.OnProgress != nil {
+				opts.OnProgress(progress)
+			}
+			if err := opts.sleep(ctx, backoff); err != nil {
+				return finish(err)
+			}
+			backoff *= 2
+			if backoff > opts.MaxBackoff {
+				backoff = opts.MaxBackoff
+			}
+		}
+	}
+	return finish(nil)
+}
+
+// DrainOfdFunc возвращает функцию отправки очереди ОФД для опций
+// CloseGuardOptions.DrainOfd и FnReplaceOptions.DrainOfd.
+func DrainOfdFunc(opts OfdDrainOptions) func(ctx context.Context, drv driver.Driver) error {
+	return func(ctx context.Context, drv driver.Driver) error {
+		_, err := DrainOfdQueue(ctx, drv, opts)
+		return err
+	}
+}
+
+// useExternalClient включает режим внешнего клиента (OfdSettings.Client = "1")
+// и возвращает настройки ОФД и функцию восстановления исходного режима.
+func useExternalClient(drv driver.Driver) (*driver.OfdSettings, func(), error) {
+	settings, err := drv.GetOfdSettings()
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения настроек ОФД: %w", err)
+	}
+	if settings.Client == "1" {
+		return settings, func() {}, nil
+	}
+	original := settings.Client
+	settings.Client = "1"
+	if err := drv.SetOfdSettings(*settings); err != nil {
+		return nil, nil, fmt.Errorf("ошибка установки режима внешнего клиента: %w", err)
+	}
+	return settings, func() {
+		restored := *settings
+		restored.Client = original
+		drv.SetOfdSettings(restored)
+	}, nil
+}
+
+// sendOfdDocument отправляет документ, прочитанный из ККТ. Готовое сообщение
+// (с сигнатурой ОФД) отправляется как есть, TLV документа упаковывается в контейнер.
+func sendOfdDocument(ctx context.Context, client ofdclient.Client, addr string, fn *driver.FnStatus, doc []byte) (*ofdclient.SendResponse, error) {
+	if bytes.HasPrefix(doc, ofdclient.SignatureOFDBytes[:]) {
+		return client.SendRaw(ctx, addr, doc)
+	}
+	container, err := ofdclient.SerializeContainer(ofdclient.CreateContainerHeader(0xA5, 0, 1), doc)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка упаковки контейнера: %w", err)
+	}
+	return client.Send(ctx, ofdclient.SendRequest{
+		OfdAddress: addr,
+		FnNumber:   fn.Serial,
+		FFDVersion: ofdFFDVersion(fn.Ffd),
+		Container:  container,
+	})
+}
+
+// ofdFFDVersion приводит код версии ФФД из ККТ к виду 1.0/1.05/1.1/1.2.
+func ofdFFDVersion(code string) string {
+	switch code {
+	case "4", "1.2", "1.20":
+		return "1.2"
+	case "3", "1.1", "1.10":
+		return "1.1"
+	case "2", "1.05":
+		return "1.05"
+	default:
+		return "1.0"
+	}
+}
+
+// sleepContext ждет d или отмены ctx.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
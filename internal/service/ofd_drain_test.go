@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/ofdclient"
+)
+
+// ofdTransport - транспорт ОФД для тестов: отвечает квитанцией или ошибкой.
+type ofdTransport struct {
+	failures int // Сколько первых отправок завершить ошибкой
+	sent     int
+}
+
+func (t *ofdTransport) Send(ctx context.Context, address string, message []byte) ([]byte, error) {
+	t.sent++
+	if t.sent <= t.failures {
+		return nil, ofdclient.ErrConnectionFailed
+	}
+	header, err := ofdclient.CreateMessageHeader("9999078900012345", "1.2", ofdclient.FlagCRCFull|ofdclient.FlagHasContainer, 2)
+	if err != nil {
+		return nil, err
+	}
+	return ofdclient.SerializeMessage(header, []byte{0xAA, 0xBB})
+}
+
+func (t *ofdTransport) Close() error { return nil }
+
+func drainDriver(count int) *fakeDriver {
+	return &fakeDriver{
+		fn:          driver.FnStatus{Serial: "9999078900012345", Ffd: "4"},
+		ofd:         driver.OfdExchangeStatus{Count: count, FirstDoc: 10},
+		ofdSettings: driver.OfdSettings{Addr: "ofd.test", Port: 7777, Client: "0"},
+	}
+}
+
+func TestDrainOfdQueue(t *testing.T) {
+	drv := drainDriver(3)
+	transport := &ofdTransport{failures: 2}
+	var progress []OfdDrainProgress
+	var sleeps []time.Duration
+	summary, err := DrainOfdQueue(context.Background(), drv, OfdDrainOptions{
+		Client:         ofdclient.NewWithTransport(ofdclient.Config{}, transport),
+		InitialBackoff: time.Second,
+		MaxBackoff:     90 * time.Second,
+		OnProgress:     func(p OfdDrainProgress) { progress = append(progress, p) },
+		sleep: func(ctx context.Context, d time.Duration) error {
+			sleeps = append(sleeps, d)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("DrainOfdQueue: %v", err)
+	}
+	if summary.Initial != 3 || summary.Sent != 3 || summary.Failures != 2 || summary.Remaining != 0 || len(summary.Errors) != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if !reflect.DeepEqual(sleeps, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Errorf("unexpected backoff: %v", sleeps)
+	}
+	// Режим внешнего клиента включается один раз и восстанавливается
+	want := []string{"OFD_CLIENT=1", "OFD_LOAD", "OFD_LOAD", "OFD_LOAD", "OFD_CLIENT=0"}
+	if !reflect.DeepEqual(drv.commands, want) {
+		t.Errorf("unexpected commands: %v", drv.commands)
+	}
+	if len(progress) != 5 || progress[2].Err != nil || progress[2].Attempt != 3 || progress[4].Number != 3 || progress[4].Total != 3 {
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+}
+
+func TestDrainOfdQueueGivesUp(t *testing.T) {
+	drv := drainDriver(2)
+	drv.ofdSettings.Client = "1"
+	summary, err := DrainOfdQueue(context.Background(), drv, OfdDrainOptions{
+		Client:      ofdclient.NewWithTransport(ofdclient.Config{}, &ofdTransport{failures: 100}),
+		MaxAttempts: 3,
+		sleep:       func(ctx context.Context, d time.Duration) error { return nil },
+	})
+	if !errors.Is(err, ofdclient.ErrConnectionFailed) {
+		t.Fatalf("expected connection error, got %v", err)
+	}
+	if summary.Sent != 0 || summary.Failures != 3 || summary.Remaining != 2 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if len(drv.commands) != 0 {
+		t.Errorf("client mode must not be changed: %v", drv.commands)
+	}
+}
+
+func TestDrainOfdQueueLimit(t *testing.T) {
+	drv := drainDriver(5)
+	var queued []int
+	summary, err := DrainOfdQueue(context.Background(), drv, OfdDrainOptions{
+		Client:     ofdclient.NewWithTransport(ofdclient.Config{}, &ofdTransport{}),
+		Limit:      2,
+		OnProgress: func(p OfdDrainProgress) { queued = append(queued, p.Queued) },
+	})
+	if err != nil || summary.Sent != 2 || summary.Remaining != 3 {
+		t.Fatalf("unexpected result: %+v, %v", summary, err)
+	}
+	// Очередь ФН, а не ограниченное Limit количество
+	if !reflect.DeepEqual(queued, []int{5, 4}) {
+		t.Errorf("unexpected queue sizes: %v", queued)
+	}
+
+	empty := drainDriver(0)
+	summary, err = DrainOfdQueue(context.Background(), empty, OfdDrainOptions{})
+	if err != nil || summary.Sent != 0 || len(empty.commands) != 0 {
+		t.Fatalf("empty queue: %+v, %v, %v", summary, err, empty.commands)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/ofdclient"
+)
+
+// OismDrainOptions задает поведение DrainOismQueue.
+type OismDrainOptions struct {
+	// Client - клиент ОИСМ (nil - TCP-клиент с таймаутом 300 с).
+	Client ofdclient.Client
+	// Logger - журнал клиента по умолчанию (если Client не задан).
+	Logger func(msg string)
+	// Limit - максимальное количество отправляемых уведомлений
+	// (0 - столько, сколько было непереданных при запуске).
+	Limit int
+	// OnProgress вызывается после отправки каждого уведомления.
+	OnProgress func(p OismDrainProgress)
+}
+
+// OismDrainProgress - отправленное уведомление о реализации маркированного товара.
+type OismDrainProgress struct {
+	Number int // Порядковый номер уведомления в текущей отправке (с 1)
+	Total  int // Сколько уведомлений планируется отправить
+	Size   int // Размер уведомления в байтах
+}
+
+// OismDrainSummary - итог отправки уведомлений в ОИСМ.
+type OismDrainSummary struct {
+	Initial   int // Непереданных уведомлений при запуске
+	Sent      int // Отправлено уведомлений
+	Remaining int // Непереданных уведомлений после отправки
+	Elapsed   time.Duration
+}
+
+// DrainOismQueue отправляет в ОИСМ непереданные уведомления о реализации
+// маркированных товаров и записывает квитанции в ФН, пока очередь не опустеет
+// или не будет достигнут opts.Limit. В режиме внешнего клиента ККТ не передает
+// уведомления сама, и непереданные уведомления блокируют продажу маркированных
+// товаров. Режим внешнего клиента включается один раз на всю отправку (см.
+// DrainOfdQueue). Итог возвращается и при ошибке.
+func DrainOismQueue(ctx context.Context, drv driver.Driver, opts OismDrainOptions) (*OismDrainSummary, error) {
+	start := time.Now()
+	summary := &OismDrainSummary{}
+	finish := func(err error) (*OismDrainSummary, error) {
+		if mark, merr := drv.GetMarkingStatus(); merr == nil {
+			summary.Remaining = mark.Notice
+		}
+		summary.Elapsed = time.Since(start)
+		return summary, err
+	}
+
+	mark, err := drv.GetMarkingStatus()
+	if err != nil {
+		return summary, fmt.Errorf("ошибка получения статуса маркировки: %w", err)
+	}
+	summary.Initial = mark.Notice
+	if mark.Notice == 0 {
+		summary.Elapsed = time.Since(start)
+		return summary, nil
+	}
+	total := mark.Notice
+	if opts.Limit > 0 && opts.Limit < total {
+		total = opts.Limit
+	}
+
+	oism, err := drv.GetOismSettings()
+	if err != nil {
+		return summary, fmt.Errorf("ошибка получения настроек ОИСМ: %w", err)
+	}
+	if oism.Addr == "" {
+		return summary, fmt.Errorf("не задан адрес сервера ОИСМ")
+	}
+	addr := fmt.Sprintf("%s:%d", oism.Addr, oism.Port)
+
+	_, restore, err := useExternalClient(drv)
+	if err != nil {
+		return summary, err
+	}
+	defer restore()
+
+	fn, err := drv.GetFnStatus()
+	if err != nil {
+		return summary, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = ofdclient.New(ofdclient.Config{
+			Timeout:       300 * time.Second,
+			RetryCount:    3,
+			RetryInterval: 5 * time.Second,
+			Logger:        opts.Logger,
+		})
+		defer client.Close()
+	}
+
+	for summary.Sent < total {
+		if err := ctx.Err(); err != nil {
+			return finish(err)
+		}
+		if summary.Sent > 0 {
+			mark, err := drv.GetMarkingStatus()
+			if err != nil {
+				return finish(fmt.Errorf("ошибка получения статуса маркировки: %w", err))
+			}
+			if mark.Notice == 0 {
+				break
+			}
+		}
+
+		notice, err := drv.OismReadFullNotice()
+		if err != nil {
+			return finish(fmt.Errorf("ошибка чтения уведомления: %w", err))
+		}
+		resp, err := client.SendNotice(ctx, ofdclient.NoticeRequest{
+			OismAddress: addr,
+			FnNumber:    fn.Serial,
+			Notice:      notice,
+		})
+		if err != nil {
+			return finish(fmt.Errorf("ошибка отправки в ОИСМ: %w", err))
+		}
+		// Квитанция записывается полным сообщением с заголовком, как для ОФД
+		if err := drv.OismLoadReceipt(resp.RawMessage); err != nil {
+			return finish(fmt.Errorf("ошибка записи квитанции ОИСМ: %w", err))
+		}
+		summary.Sent++
+		if opts.OnProgress != nil {
+			opts.OnProgress(OismDrainProgress{Number: summary.Sent, Total: total, Size: len(notice)})
+		}
+	}
+	return finish(nil)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/ofdclient"
+)
+
+// oismTransport - транспорт ОИСМ для тестов: отвечает квитанцией ОИСМ.
+type oismTransport struct {
+	addrs []string
+}
+
+func (t *oismTransport) Send(ctx context.Context, address string, message []byte) ([]byte, error) {
+	t.addrs = append(t.addrs, address)
+	header, err := ofdclient.CreateOismMessageHeader("9999078900012345", ofdclient.FlagCRCFull|ofdclient.FlagHasContainer, 2)
+	if err != nil {
+		return nil, err
+	}
+	return ofdclient.SerializeMessage(header, []byte{0xCC, 0xDD})
+}
+
+func (t *oismTransport) Close() error { return nil }
+
+func TestDrainOismQueue(t *testing.T) {
+	drv := drainDriver(0)
+	drv.marking.Notice = 3
+	drv.oismSettings = driver.OismSettings{Addr: "oism.test", Port: 8888}
+	transport := &oismTransport{}
+	var progress []OismDrainProgress
+	summary, err := DrainOismQueue(context.Background(), drv, OismDrainOptions{
+		Client:     ofdclient.NewWithTransport(ofdclient.Config{}, transport),
+		OnProgress: func(p OismDrainProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("DrainOismQueue: %v", err)
+	}
+	if summary.Initial != 3 || summary.Sent != 3 || summary.Remaining != 0 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	// Режим внешнего клиента включается один раз на всю отправку
+	want := []string{"OFD_CLIENT=1", "OISM_LOAD", "OISM_LOAD", "OISM_LOAD", "OFD_CLIENT=0"}
+	if !reflect.DeepEqual(drv.commands, want) {
+		t.Errorf("unexpected commands: %v", drv.commands)
+	}
+	if len(transport.addrs) != 3 || transport.addrs[0] != "oism.test:8888" {
+		t.Errorf("unexpected addresses: %v", transport.addrs)
+	}
+	if len(progress) != 3 || progress[2].Number != 3 || progress[2].Total != 3 {
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+}
+
+func TestDrainOismQueueLimit(t *testing.T) {
+	drv := drainDriver(0)
+	drv.marking.Notice = 3
+	drv.oismSettings = driver.OismSettings{Addr: "oism.test", Port: 8888}
+	summary, err := DrainOismQueue(context.Background(), drv, OismDrainOptions{
+		Client: ofdclient.NewWithTransport(ofdclient.Config{}, &oismTransport{}),
+		Limit:  1,
+	})
+	if err != nil || summary.Sent != 1 || summary.Remaining != 2 {
+		t.Fatalf("unexpected result: %+v, %v", summary, err)
+	}
+}
+
+func TestDrainOismQueueNoAddress(t *testing.T) {
+	drv := drainDriver(0)
+	drv.marking.Notice = 1
+	if _, err := DrainOismQueue(context.Background(), drv, OismDrainOptions{}); err == nil {
+		t.Fatal("expected error for empty OISM address")
+	}
+	if len(drv.commands) != 0 {
+		t.Errorf("client mode must not change: %v", drv.commands)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"fmt"
+
+	"mitsuscanner/driver"
+)
+
+// Коды ошибок ККТ, означающие отсутствие открытого документа
+// (команда отмены/закрытия пришла вне документа).
+var noOpenDocumentCodes = []string{"33", "37"}
+
+// RecoveryAction - действие, выполненное процедурой восстановления.
+type RecoveryAction int
+
+const (
+	RecoveryNone      RecoveryAction = iota // Открытого документа нет, действий не требуется
+	RecoveryCancelled                       // Незавершенный чек аннулирован
+	RecoveryCompleted                       // Незавершенный чек закрыт (сформирован ФД)
+	RecoveryReprinted                       // Последний чек был зафиксирован в ФН и перепечатан
+)
+
+func (a RecoveryAction) String() string {
+	switch a {
+	case RecoveryCancelled:
+		return "чек аннулирован"
+	case RecoveryCompleted:
+		return "чек завершен"
+	case RecoveryReprinted:
+		return "чек перепечатан"
+	default:
+		return "действий не требуется"
+	}
+}
+
+// RecoveryOptions задает поведение Recover.
+type RecoveryOptions struct {
+	// ExpectedLastFD - номер последнего ФД в ФН, сохраненный кассовой
+	// программой при открытии чека. Если 0, факт фиксации чека определить
+	// нельзя (последний ФД может быть чеком предыдущего покупателя), и
+	// RecoveryReport.CommitUnknown устанавливается в true.
+	ExpectedLastFD int
+	// Complete - закрыть незавершенный чек вместо его аннулирования.
+	Complete bool
+	// Reprint - перепечатать последний чек, если он был зафиксирован в ФН.
+	// Без ExpectedLastFD перепечатка не выполняется.
+	Reprint bool
+}
+
+// RecoveryReport - результат анализа и восстановления состояния ККТ.
+type RecoveryReport struct {
+	DocumentType int    // Тип текущего документа (GET DOC='0')
+	ShiftState   string // Состояние смены (0, 1, 9)
+	ShiftNum     int
+	LastFD       int  // Номер последнего ФД в ФН после восстановления
+	Committed    bool // Последний чек зафиксирован в ФН
+	// CommitUnknown - факт фиксации чека неизвестен (не задан ExpectedLastFD)
+	CommitUnknown bool
+	Action        RecoveryAction
+	Message       string
+}
+
+// Recover анализирует состояние ККТ после сбоя питания или обрыва связи
+// между OpenCheck и CloseCheck: тип текущего документа, состояние смены и
+// номер последнего ФД. Незавершенный чек аннулируется (или закрывается при
+// opts.Complete), зафиксированный чек при необходимости перепечатывается.
+func Recover(drv driver.Driver, opts RecoveryOptions) (*RecoveryReport, error) {
+	docType, err := drv.GetCurrentDocumentType()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения типа документа: %w", err)
+	}
+	shift, err := drv.GetShiftStatus()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статуса смены: %w", err)
+	}
+	report := &RecoveryReport{
+		DocumentType: docType,
+		ShiftState:   shift.State,
+		ShiftNum:     shift.ShiftNum,
+	}
+
+	if driver.IsReceiptDocType(docType) {
+		if opts.Complete {
+			err = drv.CloseCheck()
+			report.Action = RecoveryCompleted
+		} else {
+			err = drv.CancelCheck()
+			report.Action = RecoveryCancelled
+		}
+		if err != nil {
+			if !driver.IsDeviceError(err, noOpenDocumentCodes...) {
+				return report, fmt.Errorf("ошибка завершения документа: %w", err)
+			}
+			// Чек уже закрыт: тип относится к последнему сформированному документу.
+			report.Action = RecoveryNone
+		}
+	}
+
+	fn, err := drv.GetFnStatus()
+	if err != nil {
+		return report, fmt.Errorf("ошибка получения статуса ФН: %w", err)
+	}
+	report.LastFD = fn.LastFD
+
+	if report.Action == RecoveryCancelled {
+		report.Message = "незавершенный чек аннулирован, ФД не сформирован"
+		return report, nil
+	}
+
+	if opts.ExpectedLastFD > 0 {
+		report.Committed = fn.LastFD > opts.ExpectedLastFD
+	} else {
+		report.CommitUnknown = report.Action != RecoveryCompleted
+	}
+
+	switch {
+	case report.Action == RecoveryCompleted:
+		report.Committed = true
+		report.Message = fmt.Sprintf("незавершенный чек закрыт, ФД %d", fn.LastFD)
+	case report.CommitUnknown:
+		report.Message = fmt.Sprintf("открытых документов нет; последний ФД %d, "+
+			"зафиксирован ли чек, неизвестно (не задан номер ФД при открытии чека)", fn.LastFD)
+	case report.Committed && opts.Reprint:
+		if err := drv.PrintLastDocument(); err != nil {
+			return report, fmt.Errorf("ошибка печати последнего документа: %w", err)
+		}
+		report.Action = RecoveryReprinted
+		report.Message = fmt.Sprintf("чек зафиксирован в ФН (ФД %d) и перепечатан", fn.LastFD)
+	case report.Committed:
+		report.Message = fmt.Sprintf("чек зафиксирован в ФН (ФД %d)", fn.LastFD)
+	default:
+		report.Message = "открытых документов нет, чек не зафиксирован"
+	}
+	if shift.State == ShiftStateExpired {
+		report.Message += "; смена превысила 24 часа и должна быть закрыта"
+	}
+	return report, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"mitsuscanner/driver"
+)
+
+func TestRecoverCancelsOpenCheck(t *testing.T) {
+	drv := &fakeDriver{
+		shift:     driver.ShiftStatus{ShiftNum: 3, State: "1"},
+		fn:        driver.FnStatus{LastFD: 40},
+		docType:   driver.DocTypeReceipt,
+		checkOpen: true,
+	}
+	rep, err := Recover(drv, RecoveryOptions{ExpectedLastFD: 40, Reprint: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rep.Action != RecoveryCancelled || rep.Committed {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	if !reflect.DeepEqual(drv.commands, []string{"CHECK_CANCEL"}) {
+		t.Fatalf("unexpected commands: %v", drv.commands)
+	}
+}
+
+func TestRecoverFnErrorIsNotClosedDocument(t *testing.T) {
+	// Код ФН 37 не означает отсутствие открытого документа (код ККТ 37)
+	drv := &fakeDriver{
+		shift:     driver.ShiftStatus{ShiftNum: 3, State: "1"},
+		fn:        driver.FnStatus{LastFD: 40},
+		docType:   driver.DocTypeReceipt,
+		checkOpen: true,
+		checkErr:  &driver.DeviceError{Code: "1", FnCode: "37"},
+	}
+	if _, err := Recover(drv, RecoveryOptions{ExpectedLastFD: 40}); err == nil {
+		t.Fatal("expected error for FN error code 37")
+	}
+}
+
+func TestRecoverCompletesOpenCheck(t *testing.T) {
+	drv := &fakeDriver{
+		shift:     driver.ShiftStatus{ShiftNum: 3, State: "1"},
+		fn:        driver.FnStatus{LastFD: 40},
+		docType:   driver.DocTypeReceipt,
+		checkOpen: true,
+	}
+	rep, err := Recover(drv, RecoveryOptions{ExpectedLastFD: 40, Complete: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rep.Action != RecoveryCompleted || !rep.Committed || rep.LastFD != 41 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+}
+
+func TestRecoverReprintsCommittedCheck(t *testing.T) {
+	drv := &fakeDriver{
+		shift:   driver.ShiftStatus{ShiftNum: 3, State: "9"},
+		fn:      driver.FnStatus{LastFD: 41},
+		docType: driver.DocTypeReceipt,
+	}
+	rep, err := Recover(drv, RecoveryOptions{ExpectedLastFD: 40, Reprint: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rep.Action != RecoveryReprinted || !rep.Committed {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	if !reflect.DeepEqual(drv.commands, []string{"PRINT"}) {
+		t.Fatalf("unexpected commands: %v", drv.commands)
+	}
+}
+
+func TestRecoverWithoutExpectedFDIsUnknown(t *testing.T) {
+	// Последний ФД - чек предыдущего покупателя: без ExpectedLastFD
+	// он не должен считаться зафиксированным и перепечатываться
+	drv := &fakeDriver{
+		shift:   driver.ShiftStatus{ShiftNum: 3, State: "1"},
+		fn:      driver.FnStatus{LastFD: 41},
+		docType: driver.DocTypeShiftOpen,
+		docs:    map[int]string{41: `<DocXML FORM="3"></DocXML>`},
+	}
+	rep, err := Recover(drv, RecoveryOptions{Reprint: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rep.Action != RecoveryNone || rep.Committed || !rep.CommitUnknown {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	if len(drv.commands) != 0 {
+		t.Fatalf("unexpected commands: %v", drv.commands)
+	}
+}
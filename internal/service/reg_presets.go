@@ -0,0 +1,306 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mitsuscanner/driver"
+)
+
+// RegPreset - сохраненные параметры регистрации организации. Параметры,
+// относящиеся к конкретной ККТ (РНМ, номер автомата), в пресете не хранятся.
+type RegPreset struct {
+	Name      string                     `json:"name"` // Уникальное имя пресета
+	Note      string                     `json:"note,omitempty"`
+	Request   driver.RegistrationRequest `json:"request"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+}
+
+// DeviceFields - параметры регистрации конкретной ККТ.
+type DeviceFields struct {
+	RNM           string
+	AutomatNumber string
+}
+
+// Build формирует запрос регистрации из пресета и параметров ККТ.
+func (p *RegPreset) Build(dev DeviceFields) driver.RegistrationRequest {
+	req := p.Request
+	req.RNM = dev.RNM
+	req.AutomatNumber = dev.AutomatNumber
+	return req
+}
+
+// deviceFields - поля ValidationIssue, которые не проверяются в пресете.
+var deviceFields = map[string]bool{"RNM": true, "AutomatNumber": true}
+
+// Validate проверяет пресет без учета параметров конкретной ККТ.
+func (p *RegPreset) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("не задано имя пресета")
+	}
+	var issues driver.ValidationErrors
+	for _, issue := range driver.ValidateRegistration(p.Request, driver.RegValidationOptions{}) {
+		if !deviceFields[issue.Field] {
+			issues = append(issues, issue)
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("пресет %q: %w", p.Name, issues)
+	}
+	return nil
+}
+
+// PresetLoadError возвращается при загрузке, если часть пресетов не прошла проверку.
+// Корректные пресеты при этом загружаются.
+type PresetLoadError struct {
+	Invalid map[string]error // Имя пресета -> ошибка
+}
+
+func (e *PresetLoadError) Error() string {
+	names := make([]string, 0, len(e.Invalid))
+	for name := range e.Invalid {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = e.Invalid[name].Error()
+	}
+	return "некорректные пресеты регистрации: " + strings.Join(msgs, "; ")
+}
+
+// presetsData используется для сериализации/десериализации JSON.
+type presetsData struct {
+	Presets []*RegPreset `json:"presets"`
+}
+
+// PresetStore хранит пресеты регистрации в JSON-файле.
+type PresetStore struct {
+	mu       sync.RWMutex
+	presets  map[string]*RegPreset
+	filePath string
+	now      func() time.Time
+}
+
+// OpenPresetStore открывает хранилище пресетов. Отсутствующий файл - пустое хранилище.
+// Если часть пресетов некорректна, хранилище возвращается вместе с *PresetLoadError.
+func OpenPresetStore(path string) (*PresetStore, error) {
+	s := &PresetStore{filePath: path, presets: make(map[string]*RegPreset), now: time.Now}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла пресетов: %w", err)
+	}
+	defer f.Close()
+	presets, err := decodePresets(f)
+	if err != nil {
+		return nil, err
+	}
+	var invalid map[string]error
+	for _, p := range presets {
+		if err := p.Validate(); err != nil {
+			if invalid == nil {
+				invalid = make(map[string]error)
+			}
+			invalid[p.Name] = err
+			continue
+		}
+		s.presets[p.Name] = p
+	}
+	if invalid != nil {
+		return s, &PresetLoadError{Invalid: invalid}
+	}
+	return s, nil
+}
+
+func decodePresets(r io.Reader) ([]*RegPreset, error) {
+	var pd presetsData
+	if err := json.NewDecoder(r).Decode(&pd); err != nil {
+		return nil, fmt.Errorf("ошибка разбора JSON пресетов: %w", err)
+	}
+	for _, p := range pd.Presets {
+		if p == nil {
+			return nil, fmt.Errorf("пустой пресет в файле")
+		}
+		normalizePreset(p)
+	}
+	return pd.Presets, nil
+}
+
+// normalizePreset удаляет из пресета параметры конкретной ККТ.
+func normalizePreset(p *RegPreset) {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Request.RNM = ""
+	p.Request.AutomatNumber = ""
+	p.Request.IsReregistration = false
+	p.Request.Base = ""
+}
+
+// List возвращает пресеты, отсортированные по имени.
+func (s *PresetStore) List() []*RegPreset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]*RegPreset, 0, len(s.presets))
+	for _, p := range s.presets {
+		cp := *p
+		res = append(res, &cp)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// Get возвращает пресет по имени (nil, если не найден).
+func (s *PresetStore) Get(name string) *RegPreset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.presets[strings.TrimSpace(name)]
+	if !ok {
+		return nil
+	}
+	cp := *p
+	return &cp
+}
+
+// FindByINN возвращает пресеты организации с указанным ИНН.
+func (s *PresetStore) FindByINN(inn string) []*RegPreset {
+	var res []*RegPreset
+	for _, p := range s.List() {
+		if p.Request.Inn == strings.TrimSpace(inn) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Put проверяет и сохраняет пресет (создает новый или заменяет существующий).
+func (s *PresetStore) Put(p RegPreset) error {
+	normalizePreset(&p)
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.UpdatedAt = s.now()
+	presets := s.copyLocked()
+	presets[p.Name] = &p
+	return s.commitLocked(presets)
+}
+
+// Delete удаляет пресет.
+func (s *PresetStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name = strings.TrimSpace(name)
+	if _, ok := s.presets[name]; !ok {
+		return fmt.Errorf("пресет %q не найден", name)
+	}
+	presets := s.copyLocked()
+	delete(presets, name)
+	return s.commitLocked(presets)
+}
+
+// Export записывает пресеты в w (names пустой - все пресеты).
+func (s *PresetStore) Export(w io.Writer, names ...string) error {
+	pd := presetsData{Presets: []*RegPreset{}}
+	for _, p := range s.List() {
+		if len(names) == 0 || containsString(names, p.Name) {
+			pd.Presets = append(pd.Presets, p)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pd)
+}
+
+// Import добавляет пресеты из r. Существующие пресеты заменяются только при overwrite.
+// Возвращает количество импортированных пресетов; некорректные пресеты пропускаются
+// и перечисляются в *PresetLoadError.
+func (s *PresetStore) Import(r io.Reader, overwrite bool) (int, error) {
+	presets, err := decodePresets(r)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged := s.copyLocked()
+	imported := 0
+	var invalid map[string]error
+	for _, p := range presets {
+		if err := p.Validate(); err != nil {
+			if invalid == nil {
+				invalid = make(map[string]error)
+			}
+			invalid[p.Name] = err
+			continue
+		}
+		if _, exists := merged[p.Name]; exists && !overwrite {
+			continue
+		}
+		if p.UpdatedAt.IsZero() {
+			p.UpdatedAt = s.now()
+		}
+		merged[p.Name] = p
+		imported++
+	}
+	if imported > 0 {
+		if err := s.commitLocked(merged); err != nil {
+			return 0, err
+		}
+	}
+	if invalid != nil {
+		return imported, &PresetLoadError{Invalid: invalid}
+	}
+	return imported, nil
+}
+
+// copyLocked возвращает копию набора пресетов для изменения.
+func (s *PresetStore) copyLocked() map[string]*RegPreset {
+	presets := make(map[string]*RegPreset, len(s.presets))
+	for name, p := range s.presets {
+		presets[name] = p
+	}
+	return presets
+}
+
+// commitLocked сохраняет набор пресетов в файл и только после успешной
+// записи заменяет им текущий набор.
+func (s *PresetStore) commitLocked(presets map[string]*RegPreset) error {
+	if err := s.saveLocked(presets); err != nil {
+		return err
+	}
+	s.presets = presets
+	return nil
+}
+
+func (s *PresetStore) saveLocked(presets map[string]*RegPreset) error {
+	pd := presetsData{Presets: make([]*RegPreset, 0, len(presets))}
+	for _, p := range presets {
+		pd.Presets = append(pd.Presets, p)
+	}
+	sort.Slice(pd.Presets, func(i, j int) bool { return pd.Presets[i].Name < pd.Presets[j].Name })
+	data, err := json.MarshalIndent(pd, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации пресетов: %w", err)
+	}
+	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи файла пресетов: %w", err)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mitsuscanner/driver"
+)
+
+func testPreset(name string) RegPreset {
+	return RegPreset{
+		Name: name,
+		Request: driver.RegistrationRequest{
+			RNM:         "0000000001012345", // Не сохраняется в пресете
+			Inn:         "7707083893",
+			FfdVer:      "4",
+			TaxSystems:  "0",
+			OrgName:     "ООО Ромашка",
+			Address:     "г. Москва",
+			Place:       "Магазин",
+			OfdName:     "ОФД",
+			OfdInn:      "7707083893",
+			FnsSite:     "www.nalog.gov.ru",
+			AutomatMode: true,
+		},
+	}
+}
+
+func TestPresetStoreCRUD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.json")
+	store, err := OpenPresetStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(testPreset("Ромашка - магазин")); err != nil {
+		t.Fatal(err)
+	}
+	bad := testPreset("Некорректный")
+	bad.Request.Inn = "123"
+	if err := store.Put(bad); err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	// Повторное открытие загружает сохраненный пресет без РНМ
+	store, err = OpenPresetStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := store.Get("Ромашка - магазин")
+	if p == nil || p.Request.RNM != "" || p.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected preset: %+v", p)
+	}
+	if got := store.FindByINN("7707083893"); len(got) != 1 {
+		t.Fatalf("unexpected search result: %v", got)
+	}
+
+	req := p.Build(DeviceFields{RNM: "0000000002012345", AutomatNumber: "A-1"})
+	if req.RNM != "0000000002012345" || req.AutomatNumber != "A-1" || req.OrgName != "ООО Ромашка" {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+
+	if err := store.Delete("Ромашка - магазин"); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.List()) != 0 {
+		t.Fatal("preset not deleted")
+	}
+}
+
+func TestPresetStoreImportExport(t *testing.T) {
+	dir := t.TempDir()
+	src, _ := OpenPresetStore(filepath.Join(dir, "a.json"))
+	if err := src.Put(testPreset("A")); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Put(testPreset("B")); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := src.Export(&buf, "B"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `"name": "A"`) {
+		t.Fatalf("unexpected export:\n%s", buf.String())
+	}
+
+	dst, _ := OpenPresetStore(filepath.Join(dir, "b.json"))
+	n, err := dst.Import(&buf, false)
+	if err != nil || n != 1 || dst.Get("B") == nil {
+		t.Fatalf("unexpected import: %d, %v", n, err)
+	}
+}
+
+func TestPresetStoreValidatesOnLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.json")
+	data := `{"presets":[
+		{"name":"ok","request":{"inn":"7707083893","tax_systems":"0","ofd_inn":"7707083893"}},
+		{"name":"bad","request":{"inn":"7707083894","tax_systems":"9","ofd_inn":"7707083893"}}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenPresetStore(path)
+	var loadErr *PresetLoadError
+	if !errors.As(err, &loadErr) || loadErr.Invalid["bad"] == nil {
+		t.Fatalf("expected load error, got %v", err)
+	}
+	if store == nil || store.Get("ok") == nil || store.Get("bad") != nil {
+		t.Fatalf("unexpected store content")
+	}
+}
+
+func TestPresetStoreKeepsStateOnSaveError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "presets")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenPresetStore(filepath.Join(dir, "presets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(testPreset("Ромашка")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Каталог удален - запись файла невозможна
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(testPreset("Лютик")); err == nil {
+		t.Fatal("expected save error")
+	}
+	if err := store.Delete("Ромашка"); err == nil {
+		t.Fatal("expected save error")
+	}
+	var buf bytes.Buffer
+	if err := store.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Import(strings.NewReader(strings.Replace(buf.String(), "Ромашка", "Василек", 1)), false); err == nil || n != 0 {
+		t.Fatalf("expected import save error, got %d, %v", n, err)
+	}
+	if list := store.List(); len(list) != 1 || list[0].Name != "Ромашка" {
+		t.Fatalf("store changed after failed save: %v", list)
+	}
+}
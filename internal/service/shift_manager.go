@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"mitsuscanner/driver"
+	"mitsuscanner/pkg/fndoc"
+)
+
+// ShiftMaxDuration - максимальная продолжительность смены.
+const ShiftMaxDuration = 24 * time.Hour
+
+// Состояния смены из <GET INFO='0'/> (атрибут STATE).
+const (
+	ShiftStateClosed  = "0"
+	ShiftStateOpen    = "1"
+	ShiftStateExpired = "9"
+)
+
+// shiftOpenScanLimit - сколько документов сверх количества чеков за смену
+// просматривать в архиве ФН при поиске отчета об открытии смены.
+const shiftOpenScanLimit = 50
+
+// ShiftEventKind определяет тип события менеджера смен.
+type ShiftEventKind int
+
+const (
+	ShiftEventWarning ShiftEventKind = iota // Приближается окончание 24 часов
+	ShiftEventExpired                       // Смена превысила 24 часа
+	ShiftEventOpened                        // Смена открыта менеджером
+	ShiftEventClosed                        // Смена закрыта менеджером (с Z-отчетом)
+)
+
+// ShiftEvent описывает событие менеджера смен.
+type ShiftEvent struct {
+	Kind      ShiftEventKind
+	ShiftNum  int
+	OpenedAt  time.Time     // Время открытия смены (нулевое, если неизвестно)
+	Remaining time.Duration // Остаток до 24 часов
+	Message   string
+}
+
+// ShiftInfo содержит текущее состояние смены с учетом времени открытия.
+type ShiftInfo struct {
+	ShiftNum  int
+	State     string
+	OpenedAt  time.Time // Нулевое, если время открытия определить не удалось
+	ExpiresAt time.Time
+	Remaining time.Duration
+}
+
+// IsOpen возвращает true для открытой (в т.ч. истекшей) смены.
+func (s *ShiftInfo) IsOpen() bool {
+	return s.State == ShiftStateOpen || s.State == ShiftStateExpired
+}
+
+// IsExpired возвращает true, если смена превысила 24 часа.
+func (s *ShiftInfo) IsExpired() bool {
+	return s.State == ShiftStateExpired || (s.State == ShiftStateOpen && !s.OpenedAt.IsZero() && s.Remaining <= 0)
+}
+
+// ShiftConfig содержит настройки менеджера смен.
+type ShiftConfig struct {
+	Cashier     string           // Кассир для открытия/закрытия смены (пусто - текущий кассир ККТ)
+	WarnBefore  []time.Duration  // Пороги предупреждений до 24 часов (по умолчанию 2ч, 1ч, 15мин)
+	AutoCloseAt string           // Время автоматического закрытия смены "ЧЧ:ММ" (пусто - отключено)
+	Location    *time.Location   // Часовой пояс магазина (по умолчанию time.Local)
+	AutoOpen    bool             // Открывать смену автоматически перед первым чеком
+	OnEvent     func(ShiftEvent) // Обработчик событий (опционально)
+	Now         func() time.Time // Источник времени (для тестов)
+}
+
+// ShiftManager отслеживает жизненный цикл смены: предупреждает о приближении
+// 24-часового лимита, закрывает смену по расписанию и открывает ее перед первым чеком.
+// Работает через GetShiftStatus, OpenShift и CloseShift.
+type ShiftManager struct {
+	drv driver.Driver
+	cfg ShiftConfig
+
+	mu            sync.Mutex
+	shiftNum      int
+	openedAt      time.Time
+	warned        map[time.Duration]bool
+	expiredNoted  bool
+	lastAutoClose string // Дата последнего автозакрытия (гггг-мм-дд)
+}
+
+// NewShiftManager создает менеджер смен.
+func NewShiftManager(drv driver.Driver, cfg ShiftConfig) (*ShiftManager, error) {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.WarnBefore == nil {
+		cfg.WarnBefore = []time.Duration{2 * time.Hour, time.Hour, 15 * time.Minute}
+	}
+	if cfg.AutoCloseAt != "" {
+		if _, err := time.Parse("15:04", cfg.AutoCloseAt); err != nil {
+			return nil, fmt.Errorf("некорректное время автозакрытия смены %q (ожидается ЧЧ:ММ)", cfg.AutoCloseAt)
+		}
+	}
+	// Пороги проверяем от большего к меньшему
+	warn := append([]time.Duration(nil), cfg.WarnBefore...)
+	sort.Slice(warn, func(i, j int) bool { return warn[i] > warn[j] })
+	cfg.WarnBefore = warn
+
+	return &ShiftManager{
+		drv:    drv,
+		cfg:    cfg,
+		warned: make(map[time.Duration]bool),
+	}, nil
+}
+
+// Status читает состояние смены и определяет время ее открытия.
+func (m *ShiftManager) Status() (*ShiftInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.statusLocked()
+}
+
+func (m *ShiftManager) statusLocked() (*ShiftInfo, error) {
+	st, err := m.drv.GetShiftStatus()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статуса смены: %w", err)
+	}
+
+	info := &ShiftInfo{ShiftNum: st.ShiftNum, State: st.State}
+	if !info.IsOpen() {
+		return info, nil
+	}
+
+	// Новая смена (или первый запуск) - определяем время открытия по архиву ФН.
+	// Архив просматривается один раз на смену, в том числе если отчет не найден.
+	if st.ShiftNum != m.shiftNum {
+		m.resetShiftLocked(st.ShiftNum, m.findShiftOpenTime(st.Count))
+	}
+
+	if !m.openedAt.IsZero() {
+		info.OpenedAt = m.openedAt
+		info.ExpiresAt = m.openedAt.Add(ShiftMaxDuration)
+		info.Remaining = info.ExpiresAt.Sub(m.cfg.Now())
+	}
+	return info, nil
+}
+
+// EnsureOpen гарантирует открытую смену перед первым чеком.
+// Истекшая смена закрывается с Z-отчетом и открывается заново.
+func (m *ShiftManager) EnsureOpen() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.statusLocked()
+	if err != nil {
+		return err
+	}
+
+	if info.IsExpired() {
+		if !m.cfg.AutoOpen {
+			return fmt.Errorf("смена №%d превысила 24 часа, требуется закрытие смены", info.ShiftNum)
+		}
+		if err := m.closeLocked(info, "смена превысила 24 часа"); err != nil {
+			return err
+		}
+		return m.openLocked()
+	}
+
+	if info.IsOpen() {
+		return nil
+	}
+	if !m.cfg.AutoOpen {
+		return fmt.Errorf("смена закрыта")
+	}
+	return m.openLocked()
+}
+
+// Close закрывает смену с печатью Z-отчета.
+func (m *ShiftManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.statusLocked()
+	if err != nil {
+		return err
+	}
+	if !info.IsOpen() {
+		return nil
+	}
+	return m.closeLocked(info, "закрытие по запросу")
+}
+
+// Tick выполняет одну проверку: предупреждения о 24-часовом лимите и закрытие по расписанию.
+func (m *ShiftManager) Tick() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.statusLocked()
+	if err != nil {
+		return err
+	}
+	if !info.IsOpen() {
+		return nil
+	}
+
+	now := m.cfg.Now().In(m.cfg.Location)
+
+	// Закрытие по расписанию (один раз в сутки)
+	if m.cfg.AutoCloseAt != "" {
+		closeAt, _ := time.ParseInLocation("15:04", m.cfg.AutoCloseAt, m.cfg.Location)
+		today := now.Format("2006-01-02")
+		scheduled := time.Date(now.Year(), now.Month(), now.Day(), closeAt.Hour(), closeAt.Minute(), 0, 0, m.cfg.Location)
+		openedBefore := info.OpenedAt.IsZero() || info.OpenedAt.Before(scheduled)
+		if !now.Before(scheduled) && openedBefore && m.lastAutoClose != today {
+			m.lastAutoClose = today
+			return m.closeLocked(info, fmt.Sprintf("закрытие по расписанию (%s)", m.cfg.AutoCloseAt))
+		}
+	}
+
+	if info.IsExpired() {
+		if !m.expiredNoted {
+			m.expiredNoted = true
+			m.emit(ShiftEvent{
+				Kind: ShiftEventExpired, ShiftNum: info.ShiftNum, OpenedAt: info.OpenedAt, Remaining: info.Remaining,
+				Message: fmt.Sprintf("Смена №%d превысила 24 часа. Оформление чеков невозможно до закрытия смены.", info.ShiftNum),
+			})
+		}
+		return nil
+	}
+
+	if info.OpenedAt.IsZero() {
+		return nil
+	}
+
+	// Предупреждение выдаем по наименьшему достигнутому порогу, один раз на порог
+	for i := len(m.cfg.WarnBefore) - 1; i >= 0; i-- {
+		threshold := m.cfg.WarnBefore[i]
+		if info.Remaining > threshold {
+			continue
+		}
+		if !m.warned[threshold] {
+			for _, t := range m.cfg.WarnBefore[:i+1] {
+				m.warned[t] = true
+			}
+			m.emit(ShiftEvent{
+				Kind: ShiftEventWarning, ShiftNum: info.ShiftNum, OpenedAt: info.OpenedAt, Remaining: info.Remaining,
+				Message: fmt.Sprintf("До окончания 24 часов смены №%d осталось %s", info.ShiftNum, info.Remaining.Truncate(time.Minute)),
+			})
+		}
+		break
+	}
+	return nil
+}
+
+// Run периодически вызывает Tick до отмены контекста.
+func (m *ShiftManager) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Tick(); err != nil {
+			m.emit(ShiftEvent{Kind: ShiftEventWarning, Message: "Ошибка проверки смены: " + err.Error()})
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *ShiftManager) openLocked() error {
+	if err := m.drv.OpenShift(m.cfg.Cashier); err != nil {
+		return fmt.Errorf("ошибка открытия смены: %w", err)
+	}
+	_ = m.drv.PrintLastDocument()
+
+	st, err := m.drv.GetShiftStatus()
+	if err != nil {
+		return fmt.Errorf("ошибка получения статуса смены: %w", err)
+	}
+	m.resetShiftLocked(st.ShiftNum, m.cfg.Now())
+	m.emit(ShiftEvent{Kind: ShiftEventOpened, ShiftNum: st.ShiftNum, OpenedAt: m.openedAt, Remaining: ShiftMaxDuration,
+		Message: fmt.Sprintf("Открыта смена №%d", st.ShiftNum)})
+	return nil
+}
+
+func (m *ShiftManager) closeLocked(info *ShiftInfo, reason string) error {
+	if err := m.drv.CloseShift(m.cfg.Cashier); err != nil {
+		return fmt.Errorf("ошибка закрытия смены: %w", err)
+	}
+	// Печать отчета о закрытии смены (Z-отчет)
+	_ = m.drv.PrintLastDocument()
+
+	m.emit(ShiftEvent{Kind: ShiftEventClosed, ShiftNum: info.ShiftNum, OpenedAt: info.OpenedAt,
+		Message: fmt.Sprintf("Смена №%d закрыта: %s", info.ShiftNum, reason)})
+	m.resetShiftLocked(0, time.Time{})
+	return nil
+}
+
+func (m *ShiftManager) resetShiftLocked(num int, openedAt time.Time) {
+	m.shiftNum = num
+	m.openedAt = openedAt
+	m.warned = make(map[time.Duration]bool)
+	m.expiredNoted = false
+}
+
+// findShiftOpenTime ищет в архиве ФН отчет об открытии смены (FORM=2)
+// и возвращает время его формирования. checks - количество чеков за смену:
+// просматривается не более checks+shiftOpenScanLimit последних документов.
+// Возвращает нулевое время, если найти не удалось.
+func (m *ShiftManager) findShiftOpenTime(checks int) time.Time {
+	fn, err := m.drv.GetFnStatus()
+	if err != nil || fn.LastFD <= 0 {
+		return time.Time{}
+	}
+	limit := checks + shiftOpenScanLimit
+	for fd := fn.LastFD; fd > 0 && fd > fn.LastFD-limit; fd-- {
+		xmlDoc, err := m.drv.GetDocumentXMLFromFN(fd)
+		if err != nil {
+			return time.Time{}
+		}
+		doc, err := fndoc.Parse(xmlDoc)
+		if err != nil {
+			return time.Time{}
+		}
+		report, ok := doc.(*fndoc.ShiftReport)
+		if !ok || !report.Open {
+			continue
+		}
+		if report.DateTime.IsZero() {
+			return time.Time{}
+		}
+		// Время в документе - локальное время ККТ
+		dt := report.DateTime
+		return time.Date(dt.Year(), dt.Month(), dt.Day(), dt.Hour(), dt.Minute(), 0, 0, m.cfg.Location)
+	}
+	return time.Time{}
+}
+
+func (m *ShiftManager) emit(ev ShiftEvent) {
+	if m.cfg.OnEvent != nil {
+		m.cfg.OnEvent(ev)
+	}
+}
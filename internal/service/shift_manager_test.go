@@ -0,0 +1,144 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mitsuscanner/driver"
+)
+
+func TestShiftManagerWarnings(t *testing.T) {
+	loc := time.UTC
+	now := time.Date(2024, 3, 2, 9, 0, 0, 0, loc)
+	drv := &fakeDriver{
+		shift: driver.ShiftStatus{ShiftNum: 7, State: "1"},
+		fn:    driver.FnStatus{LastFD: 12},
+		docs: map[int]string{
+			12: `<DocXML FORM="3"><T1012>2024-03-02T08:00</T1012></DocXML>`,
+			11: `<DocXML FORM="2"><T1012>2024-03-01T10:30</T1012></DocXML>`,
+		},
+	}
+
+	var events []ShiftEvent
+	m, err := NewShiftManager(drv, ShiftConfig{
+		Location: loc,
+		Now:      func() time.Time { return now },
+		OnEvent:  func(ev ShiftEvent) { events = append(events, ev) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := m.Status()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !info.OpenedAt.Equal(time.Date(2024, 3, 1, 10, 30, 0, 0, loc)) {
+		t.Fatalf("unexpected open time: %v", info.OpenedAt)
+	}
+	if info.Remaining != 90*time.Minute {
+		t.Fatalf("expected 90m remaining, got %v", info.Remaining)
+	}
+
+	// 1ч30м до лимита: срабатывает порог 2ч
+	m.Tick()
+	m.Tick()
+	if len(events) != 1 || events[0].Kind != ShiftEventWarning {
+		t.Fatalf("expected one warning, got %+v", events)
+	}
+
+	// 10 минут до лимита: порог 15 минут (1ч пропущен и не дублируется)
+	now = now.Add(80 * time.Minute)
+	m.Tick()
+	if len(events) != 2 {
+		t.Fatalf("expected second warning, got %+v", events)
+	}
+
+	// Смена истекла
+	now = now.Add(20 * time.Minute)
+	m.Tick()
+	if len(events) != 3 || events[2].Kind != ShiftEventExpired {
+		t.Fatalf("expected expired event, got %+v", events)
+	}
+}
+
+func TestShiftManagerLongShift(t *testing.T) {
+	loc := time.UTC
+	now := time.Date(2024, 3, 2, 9, 0, 0, 0, loc)
+	docs := map[int]string{100: `<DocXML FORM="2"><T1012>2024-03-01T10:30</T1012></DocXML>`}
+	for fd := 101; fd <= 200; fd++ {
+		docs[fd] = `<DocXML FORM="3"><T1012>2024-03-02T08:00</T1012></DocXML>`
+	}
+	drv := &fakeDriver{
+		shift: driver.ShiftStatus{ShiftNum: 7, State: "1", Count: 100},
+		fn:    driver.FnStatus{LastFD: 200},
+		docs:  docs,
+	}
+	m, err := NewShiftManager(drv, ShiftConfig{Location: loc, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Отчет об открытии находится дальше shiftOpenScanLimit документов назад
+	info, err := m.Status()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !info.OpenedAt.Equal(time.Date(2024, 3, 1, 10, 30, 0, 0, loc)) {
+		t.Fatalf("unexpected open time: %v", info.OpenedAt)
+	}
+
+	// Если отчет не найден, архив не просматривается повторно в той же смене
+	delete(drv.docs, 100)
+	drv.shift.ShiftNum = 8
+	m.Tick()
+	reads := drv.docReads
+	m.Tick()
+	m.Tick()
+	if drv.docReads != reads {
+		t.Fatalf("archive rescanned: %d reads, expected %d", drv.docReads, reads)
+	}
+}
+
+func TestShiftManagerEnsureOpen(t *testing.T) {
+	now := time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC)
+	drv := &fakeDriver{shift: driver.ShiftStatus{ShiftNum: 7, State: "9"}, fn: driver.FnStatus{}}
+
+	m, _ := NewShiftManager(drv, ShiftConfig{AutoOpen: true, Cashier: "Кассир", Now: func() time.Time { return now }})
+	if err := m.EnsureOpen(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"CLOSE", "PRINT", "OPEN", "PRINT"}
+	if len(drv.commands) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, drv.commands)
+	}
+	for i := range expected {
+		if drv.commands[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, drv.commands)
+		}
+	}
+
+	info, _ := m.Status()
+	if info.ShiftNum != 8 || !info.OpenedAt.Equal(now) {
+		t.Errorf("unexpected shift info: %+v", info)
+	}
+}
+
+func TestShiftManagerAutoClose(t *testing.T) {
+	now := time.Date(2024, 3, 2, 23, 55, 0, 0, time.UTC)
+	drv := &fakeDriver{shift: driver.ShiftStatus{ShiftNum: 3, State: "1"}}
+
+	m, _ := NewShiftManager(drv, ShiftConfig{AutoCloseAt: "23:50", Location: time.UTC, Now: func() time.Time { return now }})
+	m.Tick()
+	if drv.shift.State != "0" {
+		t.Fatal("expected shift to be closed by schedule")
+	}
+
+	// Повторное открытие в тот же день не закрывается повторно
+	drv.OpenShift("")
+	m.Tick()
+	if drv.shift.State != "1" {
+		t.Error("shift must not be closed twice a day")
+	}
+}
@@ -0,0 +1,26 @@
+// Package fndoc parses fiscal documents read from the FN archive
+// (driver.GetDocumentXMLFromFN) into typed structures.
+//
+// Every document keeps the full tag tree, so any T-tag, including nested
+// ones (positions T1059, agent data T1223, correction basis T1174 and so on),
+// stays accessible through Node even if it has no dedicated typed field.
+// Both FFD 1.05 and FFD 1.2 variants of the documents are supported.
+//
+// Example Usage:
+//
+//	xmlDoc, err := drv.GetDocumentXMLFromFN(fd)
+//	if err != nil {
+//	    return err
+//	}
+//	doc, err := fndoc.Parse(xmlDoc)
+//	if err != nil {
+//	    return err
+//	}
+//	switch d := doc.(type) {
+//	case *fndoc.Receipt:
+//	    fmt.Println(d.Total, len(d.Items))
+//	case *fndoc.ShiftReport:
+//	    fmt.Println(d.ShiftNum)
+//	}
+//	fmt.Println(doc.Tags().String(1209)) // any tag by number
+package fndoc
@@ -0,0 +1,365 @@
+package fndoc
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Коды форм фискальных документов (атрибут FORM корневого элемента).
+const (
+	FormRegistration      = 1  // Отчет о регистрации
+	FormShiftOpen         = 2  // Отчет об открытии смены
+	FormReceipt           = 3  // Кассовый чек
+	FormBSO               = 4  // Бланк строгой отчетности
+	FormShiftClose        = 5  // Отчет о закрытии смены
+	FormFnClose           = 6  // Отчет о закрытии фискального накопителя
+	FormOperatorConfirm   = 7  // Подтверждение оператора
+	FormReregistration    = 11 // Отчет об изменении параметров регистрации
+	FormCurrentState      = 21 // Отчет о текущем состоянии расчетов
+	FormCorrectionReceipt = 31 // Кассовый чек коррекции
+	FormCorrectionBSO     = 41 // БСО коррекции
+)
+
+// IsReceiptForm возвращает true для чеков и БСО (включая коррекции).
+func IsReceiptForm(form int) bool {
+	switch form {
+	case FormReceipt, FormBSO, FormCorrectionReceipt, FormCorrectionBSO:
+		return true
+	}
+	return false
+}
+
+// FFDVersion - версия ФФД (значение тега 1209).
+type FFDVersion int
+
+const (
+	FFDUnknown FFDVersion = 0
+	FFD10      FFDVersion = 1
+	FFD105     FFDVersion = 2
+	FFD11      FFDVersion = 3
+	FFD12      FFDVersion = 4
+)
+
+func (v FFDVersion) String() string {
+	switch v {
+	case FFD10:
+		return "1.0"
+	case FFD105:
+		return "1.05"
+	case FFD11:
+		return "1.1"
+	case FFD12:
+		return "1.2"
+	default:
+		return "неизвестно"
+	}
+}
+
+// Document - общий интерфейс документов ФН.
+type Document interface {
+	// DocHeader возвращает общие реквизиты документа.
+	DocHeader() *Header
+	// Tags возвращает корневой элемент с полным деревом реквизитов.
+	Tags() *Node
+}
+
+// Header - реквизиты, общие для всех документов.
+type Header struct {
+	Form       int
+	FD         int        // T1040 Номер ФД
+	FP         string     // T1077 Фискальный признак
+	DateTime   time.Time  // T1012 Дата, время
+	FNSerial   string     // T1041 Номер ФН
+	RNM        string     // T1037 Регистрационный номер ККТ
+	UserINN    string     // T1018 ИНН пользователя
+	UserName   string     // T1048 Наименование пользователя
+	Cashier    string     // T1021 Кассир
+	CashierINN string     // T1203 ИНН кассира
+	Address    string     // T1009 Адрес расчетов
+	Place      string     // T1187 Место расчетов
+	FFD        FFDVersion // T1209 Версия ФФД
+
+	tags *Node
+}
+
+// DocHeader реализует Document.
+func (h *Header) DocHeader() *Header { return h }
+
+// Tags реализует Document.
+func (h *Header) Tags() *Node { return h.tags }
+
+// Registration - отчет о регистрации (FORM 1) или об изменении параметров регистрации (FORM 11).
+type Registration struct {
+	Header
+	Reregistration bool
+	KKTSerial      string // T1013 Заводской номер ККТ
+	KKTVersion     string // T1188 Версия ККТ
+	TaxSystems     int    // T1062 Системы налогообложения (битовая маска)
+	OFDName        string // T1046 Наименование ОФД
+	OFDINN         string // T1017 ИНН ОФД
+	FNSSite        string // T1060 Адрес сайта ФНС
+	SenderEmail    string // T1117 Адрес эл. почты отправителя чека
+	AutomatNumber  string // T1036 Номер автомата
+
+	Autonomous     bool // T1002 Автономный режим
+	Encryption     bool // T1056 Шифрование
+	Automatic      bool // T1001 Автоматический режим
+	Internet       bool // T1108 Расчеты только в сети Интернет
+	Services       bool // T1109 Оказание услуг
+	BSO            bool // T1110 Применение БСО
+	Excise         bool // T1207 Торговля подакцизными товарами
+	Gambling       bool // T1193 Проведение азартных игр
+	Lottery        bool // T1126 Проведение лотереи
+	PrinterAutomat bool // T1221 Установка принтера в автомате
+
+	ReasonCode int    // T1101 Код причины перерегистрации (ФФД 1.05)
+	ReasonMask uint32 // T1205 Коды причин изменения сведений о ККТ (ФФД 1.2)
+	UsageMask  uint32 // T1290 Признаки условий применения ККТ (ФФД 1.2)
+}
+
+// ShiftReport - отчет об открытии (FORM 2) или закрытии (FORM 5) смены.
+type ShiftReport struct {
+	Header
+	Open            bool
+	ShiftNum        int       // T1038 Номер смены
+	KKTVersion      string    // T1188 Версия ККТ
+	ReceiptsCount   int       // T1118 Количество кассовых чеков за смену
+	DocsCount       int       // T1111 Количество ФД за смену
+	Unsent          int       // T1097 Количество непереданных ФД
+	FirstUnsentDate time.Time // T1098 Дата первого из непереданных ФД
+	FnReplaceUrgent bool      // T1051 Необходима срочная замена ФН
+	FnExhausted     bool      // T1050 Исчерпание ресурса ФН
+	FnMemoryFull    bool      // T1052 Переполнение памяти ФН
+	OfdTimeout      bool      // T1053 Превышение времени ожидания ответа ОФД
+	KeysResource    int       // T1213 Ресурс ключей проверки КМ, дней (ФФД 1.2)
+}
+
+// Payments - суммы расчета по видам оплаты.
+type Payments struct {
+	Cash       float64 // T1031 Наличными
+	Electronic float64 // T1081 Безналичными
+	Prepayment float64 // T1215 Предоплатой (зачет аванса)
+	Credit     float64 // T1216 Постоплатой (кредит)
+	Barter     float64 // T1217 Встречным предоставлением
+}
+
+// Item - предмет расчета (T1059).
+type Item struct {
+	Name          string  // T1030 Наименование
+	Price         float64 // T1079 Цена за единицу
+	Quantity      float64 // T1023 Количество
+	Sum           float64 // T1043 Стоимость
+	VATRate       int     // T1199 Ставка НДС
+	PaymentMethod int     // T1214 Признак способа расчета
+	Subject       int     // T1212 Признак предмета расчета
+	Unit          string  // T1197 Единица измерения (ФФД 1.05)
+	MeasureCode   int     // T2108 Мера количества (ФФД 1.2)
+	ProductCode   string  // T1162 Код товара (ФФД 1.05)
+	MarkCode      string  // T2000 Код маркировки (ФФД 1.2)
+	AgentType     int     // T1222 Признак агента по предмету расчета
+	SupplierINN   string  // T1226 ИНН поставщика
+
+	// Node - элемент T1059 со всеми вложенными реквизитами.
+	Node *Node
+}
+
+// Receipt - кассовый чек, БСО и их коррекции (FORM 3, 4, 31, 41).
+type Receipt struct {
+	Header
+	Correction      bool
+	ShiftNum        int     // T1038 Номер смены
+	Number          int     // T1042 Номер чека за смену
+	Type            int     // T1054 Признак расчета
+	TaxSystem       int     // T1055 Применяемая СНО
+	Total           float64 // T1020 Сумма расчета
+	Payments        Payments
+	CustomerContact string // T1008 Телефон или эл. адрес покупателя
+	Customer        string // T1227 Покупатель (ФФД 1.2)
+	CustomerINN     string // T1228 ИНН покупателя (ФФД 1.2)
+	Items           []Item
+
+	CorrectionType      int       // T1173 Тип коррекции
+	CorrectionBasisDate time.Time // T1178 Дата совершения корректируемого расчета (в T1174)
+	CorrectionBasisNum  string    // T1179 Номер предписания (в T1174)
+}
+
+// CurrentStateReport - отчет о текущем состоянии расчетов (FORM 21).
+type CurrentStateReport struct {
+	Header
+	ShiftNum        int       // T1038 Номер смены
+	Unsent          int       // T1097 Количество непереданных ФД
+	FirstUnsent     int       // T1116 Номер первого непереданного ФД
+	FirstUnsentDate time.Time // T1098 Дата первого из непереданных ФД
+	KeysResource    int       // T1213 Ресурс ключей проверки КМ, дней (ФФД 1.2)
+}
+
+// FnCloseReport - отчет о закрытии фискального накопителя (FORM 6).
+type FnCloseReport struct {
+	Header
+	KKTSerial string // T1013 Заводской номер ККТ
+}
+
+// Generic - документ без специализированной структуры (например, подтверждение оператора).
+type Generic struct {
+	Header
+}
+
+// Parse разбирает XML документа, полученный из архива ФН, в типизированную структуру:
+// *Registration, *ShiftReport, *Receipt, *CurrentStateReport, *FnCloseReport или *Generic.
+func Parse(xmlDoc string) (Document, error) {
+	root, err := ParseTree(xmlDoc)
+	if err != nil {
+		return nil, err
+	}
+	return FromTree(root)
+}
+
+// FromTree формирует типизированный документ из уже разобранного дерева (см. ParseTree).
+func FromTree(root *Node) (Document, error) {
+	form, err := strconv.Atoi(root.Attrs["FORM"])
+	if err != nil {
+		return nil, fmt.Errorf("не указан код формы документа")
+	}
+
+	h := parseHeader(form, root)
+	switch form {
+	case FormRegistration, FormReregistration:
+		return parseRegistration(h, root), nil
+	case FormShiftOpen, FormShiftClose:
+		return parseShiftReport(h, root), nil
+	case FormReceipt, FormBSO, FormCorrectionReceipt, FormCorrectionBSO:
+		return parseReceipt(h, root), nil
+	case FormCurrentState:
+		return &CurrentStateReport{
+			Header:          h,
+			ShiftNum:        root.Int(1038),
+			Unsent:          root.Int(1097),
+			FirstUnsent:     root.Int(1116),
+			FirstUnsentDate: root.Time(1098),
+			KeysResource:    root.Int(1213),
+		}, nil
+	case FormFnClose:
+		return &FnCloseReport{Header: h, KKTSerial: root.String(1013)}, nil
+	default:
+		return &Generic{Header: h}, nil
+	}
+}
+
+func parseHeader(form int, root *Node) Header {
+	return Header{
+		Form:       form,
+		FD:         root.Int(1040),
+		FP:         root.String(1077),
+		DateTime:   root.Time(1012),
+		FNSerial:   root.String(1041),
+		RNM:        root.String(1037),
+		UserINN:    root.String(1018),
+		UserName:   root.String(1048),
+		Cashier:    root.String(1021),
+		CashierINN: root.String(1203),
+		Address:    root.String(1009),
+		Place:      root.String(1187),
+		FFD:        FFDVersion(root.Int(1209)),
+		tags:       root,
+	}
+}
+
+func parseRegistration(h Header, root *Node) *Registration {
+	return &Registration{
+		Header:         h,
+		Reregistration: h.Form == FormReregistration,
+		KKTSerial:      root.String(1013),
+		KKTVersion:     root.String(1188),
+		TaxSystems:     root.Int(1062),
+		OFDName:        root.String(1046),
+		OFDINN:         root.String(1017),
+		FNSSite:        root.String(1060),
+		SenderEmail:    root.String(1117),
+		AutomatNumber:  root.String(1036),
+		Autonomous:     root.Bool(1002),
+		Encryption:     root.Bool(1056),
+		Automatic:      root.Bool(1001),
+		Internet:       root.Bool(1108),
+		Services:       root.Bool(1109),
+		BSO:            root.Bool(1110),
+		Excise:         root.Bool(1207),
+		Gambling:       root.Bool(1193),
+		Lottery:        root.Bool(1126),
+		PrinterAutomat: root.Bool(1221),
+		ReasonCode:     root.Int(1101),
+		ReasonMask:     uint32(root.Int(1205)),
+		UsageMask:      uint32(root.Int(1290)),
+	}
+}
+
+func parseShiftReport(h Header, root *Node) *ShiftReport {
+	return &ShiftReport{
+		Header:          h,
+		Open:            h.Form == FormShiftOpen,
+		ShiftNum:        root.Int(1038),
+		KKTVersion:      root.String(1188),
+		ReceiptsCount:   root.Int(1118),
+		DocsCount:       root.Int(1111),
+		Unsent:          root.Int(1097),
+		FirstUnsentDate: root.Time(1098),
+		FnReplaceUrgent: root.Bool(1051),
+		FnExhausted:     root.Bool(1050),
+		FnMemoryFull:    root.Bool(1052),
+		OfdTimeout:      root.Bool(1053),
+		KeysResource:    root.Int(1213),
+	}
+}
+
+func parseReceipt(h Header, root *Node) *Receipt {
+	r := &Receipt{
+		Header:    h,
+		ShiftNum:  root.Int(1038),
+		Number:    root.Int(1042),
+		Type:      root.Int(1054),
+		TaxSystem: root.Int(1055),
+		Total:     root.Float(1020),
+		Payments: Payments{
+			Cash:       root.Float(1031),
+			Electronic: root.Float(1081),
+			Prepayment: root.Float(1215),
+			Credit:     root.Float(1216),
+			Barter:     root.Float(1217),
+		},
+		CustomerContact: root.String(1008),
+		Correction:      h.Form == FormCorrectionReceipt || h.Form == FormCorrectionBSO,
+		CorrectionType:  root.Int(1173),
+	}
+	// В ФФД 1.2 сведения о покупателе вложены в T1256
+	customer := root
+	if c := root.Find(1256); c != nil {
+		customer = c
+	}
+	r.Customer = customer.String(1227)
+	r.CustomerINN = customer.String(1228)
+
+	if basis := root.Find(1174); basis != nil {
+		r.CorrectionBasisDate = basis.Time(1178)
+		r.CorrectionBasisNum = basis.String(1179)
+	}
+
+	for _, n := range root.FindAll(1059) {
+		r.Items = append(r.Items, Item{
+			Name:          n.String(1030),
+			Price:         n.Float(1079),
+			Quantity:      n.Float(1023),
+			Sum:           n.Float(1043),
+			VATRate:       n.Int(1199),
+			PaymentMethod: n.Int(1214),
+			Subject:       n.Int(1212),
+			Unit:          n.String(1197),
+			MeasureCode:   n.Int(2108),
+			ProductCode:   n.String(1162),
+			MarkCode:      n.String(2000),
+			AgentType:     n.Int(1222),
+			SupplierINN:   n.String(1226),
+			Node:          n,
+		})
+	}
+	return r
+}
@@ -0,0 +1,125 @@
+package fndoc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseReceiptFFD12(t *testing.T) {
+	xmlDoc := `<?xml version="1.0" encoding="windows-1251"?>
+<DocXML FORM="3">
+<T1040>42</T1040><T1077>1234567890</T1077><T1012>2024-03-01T10:30:15</T1012>
+<T1209>4</T1209><T1038>7</T1038><T1042>3</T1042><T1054>1</T1054><T1055>1</T1055>
+<T1020>150,50</T1020><T1031>100.00</T1031><T1081>50.50</T1081>
+<T1256><T1227>ООО Ромашка</T1227><T1228>7707083893</T1228></T1256>
+<T1059><T1030>Хлеб</T1030><T1079>50.50</T1079><T1023>1</T1023><T1043>50.50</T1043><T2108>0</T2108></T1059>
+<T1059><T1030>Молоко</T1030><T1079>50.00</T1079><T1023>2</T1023><T1043>100.00</T1043><T2000>010460</T2000><T1222>4</T1222></T1059>
+</DocXML>`
+	doc, err := Parse(xmlDoc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, ok := doc.(*Receipt)
+	if !ok {
+		t.Fatalf("expected *Receipt, got %T", doc)
+	}
+	if r.FD != 42 || r.FP != "1234567890" || r.FFD != FFD12 || r.Correction {
+		t.Fatalf("unexpected header: %+v", r.Header)
+	}
+	if !r.DateTime.Equal(time.Date(2024, 3, 1, 10, 30, 15, 0, time.UTC)) {
+		t.Fatalf("unexpected date: %v", r.DateTime)
+	}
+	if r.Total != 150.5 || r.Payments.Cash != 100 || r.Payments.Electronic != 50.5 {
+		t.Fatalf("unexpected sums: %v %+v", r.Total, r.Payments)
+	}
+	if r.Customer != "ООО Ромашка" || r.CustomerINN != "7707083893" {
+		t.Fatalf("unexpected customer: %q %q", r.Customer, r.CustomerINN)
+	}
+	if len(r.Items) != 2 || r.Items[1].Quantity != 2 || r.Items[1].MarkCode != "010460" || r.Items[1].AgentType != 4 {
+		t.Fatalf("unexpected items: %+v", r.Items)
+	}
+	if r.Tags().String(1209) != "4" || r.Items[0].Node.String(1030) != "Хлеб" {
+		t.Fatal("raw tags are not accessible")
+	}
+}
+
+func TestParseCorrectionFFD105(t *testing.T) {
+	doc, err := Parse(`<DocXML FORM="31"><T1209>2</T1209><T1173>1</T1173>` +
+		`<T1174><T1178>01-02-24</T1178><T1179>N 15</T1179></T1174>` +
+		`<T1059><T1030>Услуга</T1030><T1197>шт</T1197><T1162>AB12</T1162></T1059></DocXML>`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := doc.(*Receipt)
+	if !r.Correction || r.FFD != FFD105 || r.CorrectionType != 1 || r.CorrectionBasisNum != "N 15" {
+		t.Fatalf("unexpected correction: %+v", r)
+	}
+	if !r.CorrectionBasisDate.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected basis date: %v", r.CorrectionBasisDate)
+	}
+	if r.Items[0].Unit != "шт" || r.Items[0].ProductCode != "AB12" {
+		t.Fatalf("unexpected item: %+v", r.Items[0])
+	}
+}
+
+func TestParseDocumentTypes(t *testing.T) {
+	tests := []struct {
+		xml  string
+		want string
+	}{
+		{`<DocXML FORM="1"><T1062>3</T1062><T1002>1</T1002></DocXML>`, "*fndoc.Registration"},
+		{`<DocXML FORM="11"><T1205>1</T1205></DocXML>`, "*fndoc.Registration"},
+		{`<DocXML FORM="2"><T1038>5</T1038></DocXML>`, "*fndoc.ShiftReport"},
+		{`<DocXML FORM="5"><T1038>5</T1038><T1118>10</T1118></DocXML>`, "*fndoc.ShiftReport"},
+		{`<DocXML FORM="4"></DocXML>`, "*fndoc.Receipt"},
+		{`<DocXML FORM="41"></DocXML>`, "*fndoc.Receipt"},
+		{`<DocXML FORM="21"><T1097>3</T1097></DocXML>`, "*fndoc.CurrentStateReport"},
+		{`<DocXML FORM="6"></DocXML>`, "*fndoc.FnCloseReport"},
+		{`<DocXML FORM="7"></DocXML>`, "*fndoc.Generic"},
+	}
+	for _, tt := range tests {
+		doc, err := Parse(tt.xml)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.xml, err)
+		}
+		if got := fmt.Sprintf("%T", doc); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.xml, got, tt.want)
+		}
+	}
+
+	reg, _ := Parse(tests[1].xml)
+	if r := reg.(*Registration); !r.Reregistration || r.ReasonMask != 1 {
+		t.Errorf("unexpected reregistration: %+v", r)
+	}
+	shift, _ := Parse(tests[3].xml)
+	if s := shift.(*ShiftReport); s.Open || s.ShiftNum != 5 || s.ReceiptsCount != 10 {
+		t.Errorf("unexpected shift report: %+v", s)
+	}
+}
+
+func TestParseWrappedDocument(t *testing.T) {
+	for _, xmlDoc := range []string{
+		`<OK><DocXML FORM="2"><T1040>7</T1040><T1038>3</T1038></DocXML></OK>`,
+		// Обертка не закрыта, после документа - мусор
+		`<OK><DocXML FORM="2"><T1040>7</T1040><T1038>3</T1038></DocXML><T1040>`,
+		"<DocXML FORM=\"2\"><T1040>7</T1040><T1038>3</T1038></DocXML>\x00\x00 OK",
+	} {
+		doc, err := Parse(xmlDoc)
+		if err != nil {
+			t.Errorf("%q: %v", xmlDoc, err)
+			continue
+		}
+		if s, ok := doc.(*ShiftReport); !ok || s.FD != 7 || s.ShiftNum != 3 {
+			t.Errorf("%q: unexpected document: %+v", xmlDoc, doc)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, xmlDoc := range []string{"", "<DocXML>", "<DocXML></DocXML>", "<DocXML FORM='x'/>", "<OK><DocXML FORM='2'><T1040>1</T1040></OK>"} {
+		if _, err := Parse(xmlDoc); err == nil {
+			t.Errorf("expected error for %q", xmlDoc)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package fndoc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts - форматы даты/времени, встречающиеся в XML документов ФН.
+var dateLayouts = []string{
+	"02-01-06T15:04",
+	"02-01-06T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"02.01.2006 15:04",
+	"02.01.2006 15:04:05",
+	"02-01-06",
+	"2006-01-02",
+	"02.01.2006",
+}
+
+// Node - элемент XML документа ФН. Для реквизитов вида <T1012> поле Tag
+// содержит номер тега, для прочих элементов - 0.
+type Node struct {
+	Name     string
+	Tag      int
+	Attrs    map[string]string
+	Value    string
+	Children []*Node
+}
+
+// ParseTree разбирает XML документа ФН в дерево элементов и возвращает
+// элемент документа - первый элемент с атрибутом FORM на любой глубине
+// (ответ ККТ может быть обернут, например <OK><DocXML FORM='..'>), а если
+// такого нет - корневой элемент. Разбор заканчивается на закрывающем теге
+// этого элемента, все, что следует за ним, игнорируется.
+func ParseTree(xmlDoc string) (*Node, error) {
+	dec := xml.NewDecoder(strings.NewReader(xmlDoc))
+	// Текст уже перекодирован в UTF-8, объявленная кодировка игнорируется.
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+
+	var root, doc *Node
+	var stack []*Node
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора XML документа: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &Node{Name: t.Name.Local, Tag: tagNumber(t.Name.Local)}
+			if len(t.Attr) > 0 {
+				node.Attrs = make(map[string]string, len(t.Attr))
+				for _, a := range t.Attr {
+					node.Attrs[a.Name.Local] = a.Value
+				}
+			}
+			if _, ok := node.Attrs["FORM"]; ok && doc == nil {
+				doc = node
+			}
+			if len(stack) == 0 {
+				root = node
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			}
+			stack = append(stack, node)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Value += string(t)
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				top.Value = strings.TrimSpace(top.Value)
+				stack = stack[:len(stack)-1]
+				if top == doc || (doc == nil && len(stack) == 0) {
+					return top, nil
+				}
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("документ пуст")
+	}
+	return nil, fmt.Errorf("ошибка разбора XML документа: элемент <%s> не закрыт", stack[len(stack)-1].Name)
+}
+
+// tagNumber возвращает номер тега для имени элемента "Txxxx" или 0.
+func tagNumber(name string) int {
+	if len(name) < 2 || name[0] != 'T' {
+		return 0
+	}
+	n, err := strconv.Atoi(name[1:])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Find возвращает первый дочерний реквизит с указанным тегом или nil.
+func (n *Node) Find(tag int) *Node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.Tag == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// FindAll возвращает все дочерние реквизиты с указанным тегом.
+func (n *Node) FindAll(tag int) []*Node {
+	if n == nil {
+		return nil
+	}
+	var res []*Node
+	for _, c := range n.Children {
+		if c.Tag == tag {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Has возвращает true, если реквизит присутствует.
+func (n *Node) Has(tag int) bool {
+	return n.Find(tag) != nil
+}
+
+// String возвращает значение реквизита или пустую строку.
+func (n *Node) String(tag int) string {
+	if c := n.Find(tag); c != nil {
+		return c.Value
+	}
+	return ""
+}
+
+// Int возвращает целочисленное значение реквизита (0, если реквизита нет или он не число).
+func (n *Node) Int(tag int) int {
+	v, _ := strconv.Atoi(n.String(tag))
+	return v
+}
+
+// Float возвращает числовое значение реквизита (суммы, количество).
+// Допускается десятичная запятая.
+func (n *Node) Float(tag int) float64 {
+	s := strings.ReplaceAll(n.String(tag), ",", ".")
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// Bool возвращает true, если значение реквизита "1" или "true".
+func (n *Node) Bool(tag int) bool {
+	s := n.String(tag)
+	return s == "1" || strings.EqualFold(s, "true")
+}
+
+// Time возвращает дату/время реквизита (нулевое значение, если не распознано).
+func (n *Node) Time(tag int) time.Time {
+	t, _ := ParseDateTime(n.String(tag))
+	return t
+}
+
+// ParseDateTime разбирает дату/время в одном из форматов документов ФН.
+func ParseDateTime(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("не удалось распарсить дату-время: %s", s)
+}
@@ -0,0 +1,110 @@
+package fndoc
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrTimeLayout - формат даты/времени в QR-коде чека.
+const qrTimeLayout = "20060102T1504"
+
+// ReceiptQR - данные QR-кода кассового чека для проверки в ФНС
+// (строка вида t=20240301T1030&s=150.50&fn=...&i=42&fp=...&n=1).
+type ReceiptQR struct {
+	DateTime time.Time // t  - дата и время расчета
+	Total    float64   // s  - сумма расчета
+	FNSerial string    // fn - номер ФН
+	FD       int       // i  - номер ФД
+	FP       string    // fp - фискальный признак
+	Type     int       // n  - признак расчета (1-4)
+}
+
+// ReceiptQRFromDocument формирует данные QR-кода по чеку из архива ФН.
+// fnSerial используется, если в документе нет номера ФН (T1041).
+func ReceiptQRFromDocument(r *Receipt, fnSerial string) (ReceiptQR, error) {
+	if r == nil {
+		return ReceiptQR{}, fmt.Errorf("документ не задан")
+	}
+	q := ReceiptQR{
+		DateTime: r.DateTime,
+		Total:    r.Total,
+		FNSerial: r.FNSerial,
+		FD:       r.FD,
+		FP:       r.FP,
+		Type:     r.Type,
+	}
+	if q.FNSerial == "" {
+		q.FNSerial = fnSerial
+	}
+	return q, q.Validate()
+}
+
+// Validate проверяет, что заданы все данные QR-кода.
+func (q ReceiptQR) Validate() error {
+	var missing []string
+	if q.DateTime.IsZero() {
+		missing = append(missing, "дата и время (t)")
+	}
+	if q.FNSerial == "" {
+		missing = append(missing, "номер ФН (fn)")
+	}
+	if q.FD <= 0 {
+		missing = append(missing, "номер ФД (i)")
+	}
+	if q.FP == "" {
+		missing = append(missing, "ФП (fp)")
+	}
+	if q.Type < 1 || q.Type > 4 {
+		missing = append(missing, "признак расчета (n)")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("недостаточно данных для QR-кода чека: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// String возвращает строку QR-кода чека.
+func (q ReceiptQR) String() string {
+	return fmt.Sprintf("t=%s&s=%.2f&fn=%s&i=%d&fp=%s&n=%d",
+		q.DateTime.Format(qrTimeLayout), q.Total, q.FNSerial, q.FD, q.FP, q.Type)
+}
+
+// PNG возвращает изображение QR-кода размером size x size пикселей.
+func (q ReceiptQR) PNG(size int) ([]byte, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	png, err := qrcode.Encode(q.String(), qrcode.Medium, size)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка формирования QR-кода: %w", err)
+	}
+	return png, nil
+}
+
+// ParseReceiptQR разбирает строку QR-кода чека.
+func ParseReceiptQR(s string) (ReceiptQR, error) {
+	values, err := url.ParseQuery(strings.TrimSpace(s))
+	if err != nil {
+		return ReceiptQR{}, fmt.Errorf("некорректная строка QR-кода: %w", err)
+	}
+	var q ReceiptQR
+	t := values.Get("t")
+	// Время может быть указано с секундами
+	for _, layout := range []string{qrTimeLayout, "20060102T150405"} {
+		if dt, err := time.Parse(layout, t); err == nil {
+			q.DateTime = dt
+			break
+		}
+	}
+	q.Total, _ = strconv.ParseFloat(values.Get("s"), 64)
+	q.FNSerial = values.Get("fn")
+	q.FD, _ = strconv.Atoi(values.Get("i"))
+	q.FP = values.Get("fp")
+	q.Type, _ = strconv.Atoi(values.Get("n"))
+	return q, q.Validate()
+}
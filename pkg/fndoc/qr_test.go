@@ -0,0 +1,49 @@
+package fndoc
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestReceiptQRFromDocument(t *testing.T) {
+	doc, err := Parse(`<DocXML FORM="3"><T1012>2024-03-01T10:30:15</T1012><T1040>42</T1040>` +
+		`<T1077>1234567890</T1077><T1054>1</T1054><T1020>150.5</T1020></DocXML>`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q, err := ReceiptQRFromDocument(doc.(*Receipt), "9999078900001234")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "t=20240301T1030&s=150.50&fn=9999078900001234&i=42&fp=1234567890&n=1"
+	if q.String() != want {
+		t.Fatalf("got %q, want %q", q.String(), want)
+	}
+
+	parsed, err := ParseReceiptQR(want)
+	if err != nil || parsed.String() != want {
+		t.Fatalf("round trip failed: %q, %v", parsed.String(), err)
+	}
+
+	png, err := q.PNG(128)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatal("result is not a PNG image")
+	}
+}
+
+func TestReceiptQRValidate(t *testing.T) {
+	q := ReceiptQR{DateTime: time.Now(), FD: 1, FP: "1", Type: 5}
+	if err := q.Validate(); err == nil {
+		t.Fatal("expected error for missing FN and invalid type")
+	}
+	if _, err := q.PNG(64); err == nil {
+		t.Fatal("expected PNG error for invalid data")
+	}
+	if _, err := ParseReceiptQR("t=20240301T103015&s=10&fn=1&i=2&fp=3&n=2"); err != nil {
+		t.Fatalf("time with seconds must be accepted: %v", err)
+	}
+}
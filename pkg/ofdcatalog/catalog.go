@@ -0,0 +1,243 @@
+// Package ofdcatalog содержит справочник операторов фискальных данных (ОФД):
+// наименование, ИНН, адрес сервера и сайт ФНС. Справочник встроен в программу
+// и может быть исправлен или дополнен файлом переопределений.
+package ofdcatalog
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"mitsuscanner/driver"
+)
+
+//go:embed providers.json
+var embedded []byte
+
+// Provider - оператор фискальных данных.
+type Provider struct {
+	Name    string `json:"name"`     // Наименование ОФД (T1046)
+	Brand   string `json:"brand"`    // Торговая марка
+	INN     string `json:"inn"`      // ИНН ОФД (T1017)
+	Host    string `json:"host"`     // Адрес сервера ОФД
+	Port    int    `json:"port"`     // Порт сервера ОФД
+	FnsSite string `json:"fns_site"` // Адрес сайта ФНС (T1060)
+}
+
+// Title возвращает наименование для отображения.
+func (p Provider) Title() string {
+	if p.Brand == "" || p.Brand == p.Name {
+		return p.Name
+	}
+	return p.Brand + " (" + p.Name + ")"
+}
+
+// ApplySettings заполняет адрес сервера ОФД в настройках ККТ.
+// Режим клиента и таймеры не изменяются.
+func (p Provider) ApplySettings(s *driver.OfdSettings) {
+	s.Addr = p.Host
+	s.Port = p.Port
+}
+
+// ApplyRequest заполняет реквизиты ОФД в запросе регистрации.
+func (p Provider) ApplyRequest(req *driver.RegistrationRequest) {
+	req.OfdName = p.Name
+	req.OfdInn = p.INN
+	if p.FnsSite != "" {
+		req.FnsSite = p.FnsSite
+	}
+}
+
+// catalogData используется для сериализации/десериализации JSON.
+type catalogData struct {
+	Providers []Provider `json:"providers"`
+}
+
+// Catalog - справочник ОФД.
+type Catalog struct {
+	providers []Provider
+}
+
+// Default возвращает встроенный справочник.
+func Default() *Catalog {
+	c, err := Load(bytes.NewReader(embedded))
+	if err != nil {
+		panic("ofdcatalog: некорректный встроенный справочник: " + err.Error())
+	}
+	return c
+}
+
+// Load читает справочник в формате JSON.
+func Load(r io.Reader) (*Catalog, error) {
+	var cd catalogData
+	if err := json.NewDecoder(r).Decode(&cd); err != nil {
+		return nil, fmt.Errorf("ошибка разбора справочника ОФД: %w", err)
+	}
+	c := &Catalog{}
+	for _, p := range cd.Providers {
+		if err := c.put(p); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// LoadWithOverride возвращает встроенный справочник, дополненный записями из
+// файла path. Записи файла заменяют встроенные с тем же ИНН. Отсутствующий
+// файл не является ошибкой.
+func LoadWithOverride(path string) (*Catalog, error) {
+	c := Default()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("ошибка чтения файла справочника ОФД: %w", err)
+	}
+	defer f.Close()
+	override, err := Load(f)
+	if err != nil {
+		return c, err
+	}
+	for _, p := range override.providers {
+		c.put(p)
+	}
+	return c, nil
+}
+
+// put добавляет или заменяет (по ИНН) запись справочника.
+func (c *Catalog) put(p Provider) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.INN = strings.TrimSpace(p.INN)
+	p.Host = strings.TrimSpace(p.Host)
+	if p.Name == "" {
+		return fmt.Errorf("ОФД с ИНН %q: не задано наименование", p.INN)
+	}
+	if err := driver.ValidateINN(p.INN); err != nil {
+		return fmt.Errorf("ОФД %q: %w", p.Name, err)
+	}
+	if p.Port < 0 || p.Port > 65535 {
+		return fmt.Errorf("ОФД %q: некорректный порт %d", p.Name, p.Port)
+	}
+	for i := range c.providers {
+		if c.providers[i].INN == p.INN {
+			c.providers[i] = p
+			return nil
+		}
+	}
+	c.providers = append(c.providers, p)
+	return nil
+}
+
+// List возвращает записи справочника, отсортированные по наименованию для отображения.
+func (c *Catalog) List() []Provider {
+	res := append([]Provider(nil), c.providers...)
+	sort.Slice(res, func(i, j int) bool { return res[i].Title() < res[j].Title() })
+	return res
+}
+
+// ByINN ищет ОФД по ИНН.
+func (c *Catalog) ByINN(inn string) (Provider, bool) {
+	inn = strings.TrimSpace(inn)
+	for _, p := range c.providers {
+		if p.INN == inn {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// ByName ищет ОФД по наименованию или торговой марке без учета регистра и кавычек.
+func (c *Catalog) ByName(name string) (Provider, bool) {
+	key := normalizeName(name)
+	if key == "" {
+		return Provider{}, false
+	}
+	for _, p := range c.providers {
+		if normalizeName(p.Name) == key || (p.Brand != "" && normalizeName(p.Brand) == key) {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// ByHost ищет ОФД по адресу сервера.
+func (c *Catalog) ByHost(host string) (Provider, bool) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" {
+		return Provider{}, false
+	}
+	for _, p := range c.providers {
+		if strings.ToLower(p.Host) == host {
+			return p, true
+		}
+	}
+	return Provider{}, false
+}
+
+// Lookup ищет ОФД по ИНН, а если не найден - по наименованию.
+func (c *Catalog) Lookup(key string) (Provider, bool) {
+	if p, ok := c.ByINN(key); ok {
+		return p, true
+	}
+	return c.ByName(key)
+}
+
+func normalizeName(s string) string {
+	s = strings.NewReplacer(`"`, "", "«", "", "»", "", "'", "").Replace(s)
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// Mismatch - расхождение между настройками ОФД и реквизитами регистрации.
+type Mismatch struct {
+	Field   string // Поле: OfdInn, OfdName, FnsSite, Addr, Port
+	Message string
+}
+
+// Check сверяет адрес сервера ОФД в настройках ККТ с реквизитами ОФД
+// в запросе регистрации. В автономном режиме ОФД не используется, и
+// проверяется только сайт ФНС. Возвращает список расхождений (пустой - расхождений нет).
+func (c *Catalog) Check(settings driver.OfdSettings, req driver.RegistrationRequest) []Mismatch {
+	var res []Mismatch
+	add := func(field, format string, args ...interface{}) {
+		res = append(res, Mismatch{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if req.AutonomousMode {
+		if req.FnsSite == "" {
+			add("FnsSite", "не задан адрес сайта ФНС")
+		}
+		return res
+	}
+
+	p, ok := c.ByINN(req.OfdInn)
+	if !ok {
+		add("OfdInn", "ОФД с ИНН %q отсутствует в справочнике", req.OfdInn)
+		if hp, ok := c.ByHost(settings.Addr); ok {
+			add("Addr", "сервер %s принадлежит ОФД %s (ИНН %s)", settings.Addr, hp.Title(), hp.INN)
+		}
+		return res
+	}
+
+	if normalizeName(req.OfdName) != normalizeName(p.Name) {
+		add("OfdName", "наименование ОФД %q не соответствует ИНН %s (%q)", req.OfdName, p.INN, p.Name)
+	}
+	if p.FnsSite != "" && !strings.EqualFold(strings.TrimSpace(req.FnsSite), p.FnsSite) {
+		add("FnsSite", "адрес сайта ФНС %q, ожидается %q", req.FnsSite, p.FnsSite)
+	}
+	if !strings.EqualFold(strings.TrimSpace(settings.Addr), p.Host) {
+		if hp, ok := c.ByHost(settings.Addr); ok {
+			add("Addr", "сервер %s принадлежит ОФД %s, а в регистрации указан %s", settings.Addr, hp.Title(), p.Title())
+		} else {
+			add("Addr", "сервер ОФД %q, ожидается %q (%s)", settings.Addr, p.Host, p.Title())
+		}
+	} else if settings.Port != p.Port {
+		add("Port", "порт сервера ОФД %d, ожидается %d (%s)", settings.Port, p.Port, p.Title())
+	}
+	return res
+}
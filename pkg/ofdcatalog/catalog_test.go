@@ -0,0 +1,139 @@
+package ofdcatalog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mitsuscanner/driver"
+)
+
+func TestDefaultLookups(t *testing.T) {
+	c := Default()
+	if len(c.List()) == 0 {
+		t.Fatal("встроенный справочник пуст")
+	}
+
+	p, ok := c.ByINN("7704358518")
+	if !ok || p.Host != "kkt.ofd.yandex.net" || p.Port != 12345 {
+		t.Fatalf("ByINN: %+v, %v", p, ok)
+	}
+	if byName, ok := c.ByName(`ооо «яндекс.офд»`); !ok || byName.INN != p.INN {
+		t.Errorf("ByName по наименованию: %+v, %v", byName, ok)
+	}
+	if byBrand, ok := c.ByName("первый офд"); !ok || byBrand.INN != "7709364346" {
+		t.Errorf("ByName по торговой марке: %+v, %v", byBrand, ok)
+	}
+	if byHost, ok := c.ByHost("F1.TAXCOM.RU"); !ok || byHost.INN != "7704211201" {
+		t.Errorf("ByHost: %+v, %v", byHost, ok)
+	}
+	if _, ok := c.Lookup("1234567890"); ok {
+		t.Error("Lookup нашел несуществующий ОФД")
+	}
+}
+
+func TestApply(t *testing.T) {
+	p, _ := Default().ByINN("7704211201")
+	settings := driver.OfdSettings{Client: "1", TimerFN: 60}
+	p.ApplySettings(&settings)
+	if settings.Addr != p.Host || settings.Port != p.Port || settings.Client != "1" || settings.TimerFN != 60 {
+		t.Errorf("ApplySettings: %+v", settings)
+	}
+	var req driver.RegistrationRequest
+	p.ApplyRequest(&req)
+	if req.OfdName != p.Name || req.OfdInn != p.INN || req.FnsSite != p.FnsSite {
+		t.Errorf("ApplyRequest: %+v", req)
+	}
+	if mm := Default().Check(settings, req); len(mm) != 0 {
+		t.Errorf("Check для согласованных настроек: %+v", mm)
+	}
+}
+
+func TestLoadWithOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ofd_catalog.json")
+
+	c, err := LoadWithOverride(path)
+	if err != nil || len(c.List()) != len(Default().List()) {
+		t.Fatalf("отсутствующий файл: %v", err)
+	}
+
+	data := `{"providers": [
+		{"name": "ООО \"Такском\"", "inn": "7704211201", "host": "new.taxcom.ru", "port": 7778, "fns_site": "www.nalog.gov.ru"},
+		{"name": "ООО \"Тестовый ОФД\"", "inn": "7707083893", "host": "ofd.test", "port": 9999}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err = LoadWithOverride(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.List()) != len(Default().List())+1 {
+		t.Errorf("ожидалась одна новая запись, всего %d", len(c.List()))
+	}
+	if p, _ := c.ByINN("7704211201"); p.Host != "new.taxcom.ru" || p.Port != 7778 {
+		t.Errorf("запись не переопределена: %+v", p)
+	}
+	if _, ok := c.ByHost("ofd.test"); !ok {
+		t.Error("новая запись не добавлена")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"providers": [{"name": "X", "inn": "123"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err = LoadWithOverride(path)
+	if err == nil {
+		t.Error("ожидалась ошибка для некорректного ИНН")
+	}
+	if c == nil || len(c.List()) != len(Default().List()) {
+		t.Error("при ошибке должен возвращаться встроенный справочник")
+	}
+}
+
+func TestCheckMismatches(t *testing.T) {
+	c := Default()
+	taxcom, _ := c.ByINN("7704211201")
+	yandex, _ := c.ByINN("7704358518")
+
+	var req driver.RegistrationRequest
+	taxcom.ApplyRequest(&req)
+
+	fields := func(mm []Mismatch) string {
+		var res []string
+		for _, m := range mm {
+			res = append(res, m.Field)
+		}
+		return strings.Join(res, ",")
+	}
+
+	tests := []struct {
+		name     string
+		settings driver.OfdSettings
+		modify   func(*driver.RegistrationRequest)
+		want     string
+	}{
+		{"сервер другого ОФД", driver.OfdSettings{Addr: yandex.Host, Port: yandex.Port}, nil, "Addr"},
+		{"неизвестный сервер", driver.OfdSettings{Addr: "10.0.0.1", Port: 7777}, nil, "Addr"},
+		{"порт", driver.OfdSettings{Addr: taxcom.Host, Port: 7000}, nil, "Port"},
+		{"наименование", driver.OfdSettings{Addr: taxcom.Host, Port: taxcom.Port},
+			func(r *driver.RegistrationRequest) { r.OfdName = yandex.Name }, "OfdName"},
+		{"сайт ФНС", driver.OfdSettings{Addr: taxcom.Host, Port: taxcom.Port},
+			func(r *driver.RegistrationRequest) { r.FnsSite = "nalog.ru" }, "FnsSite"},
+		{"неизвестный ИНН", driver.OfdSettings{Addr: yandex.Host, Port: yandex.Port},
+			func(r *driver.RegistrationRequest) { r.OfdInn = "7707083893" }, "OfdInn,Addr"},
+		{"автономный режим", driver.OfdSettings{},
+			func(r *driver.RegistrationRequest) { r.AutonomousMode = true; r.OfdInn = ""; r.OfdName = "" }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := req
+			if tt.modify != nil {
+				tt.modify(&r)
+			}
+			if got := fields(c.Check(tt.settings, r)); got != tt.want {
+				t.Errorf("поля расхождений %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,10 @@ type Client interface {
 
 	// SendRaw отправляет готовое сообщение (уже сформированное ККТ) и возвращает квитанцию
 	SendRaw(ctx context.Context, address string, rawMessage []byte) (*SendResponse, error)
+
+	// SendNotice отправляет уведомление о реализации маркированного товара в ОИСМ
+	// и возвращает квитанцию
+	SendNotice(ctx context.Context, req NoticeRequest) (*SendResponse, error)
 }
 
 // New создает новый OFD-клиент с заданной конфигурацией
@@ -15,6 +15,7 @@ var (
 	ErrNoContainer       = errors.New("ofdclient: response contains no container")
 	ErrEmptyContainer    = errors.New("ofdclient: container is empty")
 	ErrServerRejected    = errors.New("ofdclient: server rejected message")
+	ErrInvalidSignature  = errors.New("ofdclient: unexpected message signature")
 )
 
 // OfdError представляет ошибку от сервера ОФД
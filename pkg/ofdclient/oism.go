@@ -0,0 +1,75 @@
+package ofdclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// CreateOismMessageHeader создает заголовок сообщения для ОИСМ:
+// сигнатура 'DD80CAA1'h, версия S-протокола '82A2'h, версия P-протокола ФФД 1.2
+// (уведомления о реализации маркированных товаров есть только в ФФД 1.2)
+func CreateOismMessageHeader(fnNumber string, flags MessageFlags, bodySize uint16) (*MessageHeader, error) {
+	header, err := CreateMessageHeader(fnNumber, "1.2", flags, bodySize)
+	if err != nil {
+		return nil, err
+	}
+	header.Signature = SignatureOISMBytes
+	header.SProtoVersion = SProtoVersionOISMBytes
+	return header, nil
+}
+
+// IsOismMessage возвращает true, если данные начинаются с сигнатуры ОИСМ
+// (ККТ вернула готовое сообщение с заголовком)
+func IsOismMessage(data []byte) bool {
+	return bytes.HasPrefix(data, SignatureOISMBytes[:])
+}
+
+// SendNotice реализует отправку уведомления в ОИСМ.
+// Готовое сообщение (с сигнатурой ОИСМ) отправляется как есть, иначе
+// уведомление упаковывается в сообщение с заголовком ОИСМ.
+func (c *ofdClient) SendNotice(ctx context.Context, req NoticeRequest) (*SendResponse, error) {
+	if len(req.Notice) == 0 {
+		return nil, ErrEmptyContainer
+	}
+
+	message := req.Notice
+	if !IsOismMessage(message) {
+		if len(req.FnNumber) != 16 {
+			return nil, ErrInvalidFnNumber
+		}
+		header, err := CreateOismMessageHeader(req.FnNumber, FlagCRCFull|FlagHasContainer|FlagExpectResponse, uint16(len(req.Notice)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OISM message header: %w", err)
+		}
+		message, err = SerializeMessage(header, req.Notice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize OISM message: %w", err)
+		}
+	}
+
+	if c.cfg.Logger != nil {
+		c.cfg.Logger(fmt.Sprintf("Sending notice to OISM, FN: %s, size: %d bytes", req.FnNumber, len(message)))
+	}
+
+	response, err := c.transport.Send(ctx, req.OismAddress, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send notice: %w", err)
+	}
+
+	header, respBody, err := DeserializeMessage(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize OISM response: %w", err)
+	}
+	if header.Signature != SignatureOISMBytes {
+		return nil, fmt.Errorf("%w: % X", ErrInvalidSignature, header.Signature)
+	}
+	if len(respBody) == 0 {
+		return nil, ErrNoContainer
+	}
+
+	return &SendResponse{
+		Receipt:    respBody,
+		RawMessage: response,
+	}, nil
+}
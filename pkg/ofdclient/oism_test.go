@@ -0,0 +1,82 @@
+package ofdclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// createOismResponse создает ответ ОИСМ с квитанцией
+func createOismResponse(t *testing.T, body []byte) []byte {
+	header, err := CreateOismMessageHeader("1234567890123456", FlagCRCFull|FlagHasContainer, uint16(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := SerializeMessage(header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestClientSendNotice(t *testing.T) {
+	receipt := []byte{0x0A, 0x0B, 0x0C}
+	var sent []byte
+	transport := &MockTransport{
+		OnSend: func(ctx context.Context, address string, message []byte) ([]byte, error) {
+			if address != "oism.test:7790" {
+				t.Errorf("unexpected address: %s", address)
+			}
+			sent = message
+			return createOismResponse(t, receipt), nil
+		},
+	}
+	client := NewWithTransport(Config{}, transport)
+
+	notice := []byte{0x01, 0x02, 0x03, 0x04}
+	resp, err := client.SendNotice(context.Background(), NoticeRequest{
+		OismAddress: "oism.test:7790",
+		FnNumber:    "9999078900012345",
+		Notice:      notice,
+	})
+	if err != nil {
+		t.Fatalf("SendNotice: %v", err)
+	}
+	if !bytes.Equal(resp.Receipt, receipt) {
+		t.Errorf("unexpected receipt: % X", resp.Receipt)
+	}
+
+	header, body, err := DeserializeMessage(sent)
+	if err != nil {
+		t.Fatalf("sent message: %v", err)
+	}
+	if header.Signature != SignatureOISMBytes || header.SProtoVersion != SProtoVersionOISMBytes || header.PProtoVersion != PProtoFFD12Bytes {
+		t.Errorf("unexpected header: % X % X % X", header.Signature, header.SProtoVersion, header.PProtoVersion)
+	}
+	if string(header.FnNumber[:]) != "9999078900012345" || !bytes.Equal(body, notice) {
+		t.Errorf("unexpected message: % X", sent)
+	}
+
+	// Готовое сообщение отправляется без изменений
+	resp, err = client.SendNotice(context.Background(), NoticeRequest{OismAddress: "oism.test:7790", Notice: sent})
+	if err != nil || !bytes.Equal(resp.Receipt, receipt) {
+		t.Fatalf("raw notice: %v", err)
+	}
+}
+
+func TestClientSendNoticeWrongSignature(t *testing.T) {
+	transport := &MockTransport{
+		OnSend: func(ctx context.Context, address string, message []byte) ([]byte, error) {
+			return createValidResponse([]byte{0x01}), nil // ответ с сигнатурой ОФД
+		},
+	}
+	client := NewWithTransport(Config{}, transport)
+	_, err := client.SendNotice(context.Background(), NoticeRequest{FnNumber: "9999078900012345", Notice: []byte{0x01}})
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+	if _, err := client.SendNotice(context.Background(), NoticeRequest{FnNumber: "9999078900012345"}); !errors.Is(err, ErrEmptyContainer) {
+		t.Fatalf("expected ErrEmptyContainer, got %v", err)
+	}
+}
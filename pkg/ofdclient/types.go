@@ -121,3 +121,10 @@ type SendResponse struct {
 	Receipt    []byte // Квитанция для записи в ФН
 	RawMessage []byte // Полное сырое сообщение (для отладки)
 }
+
+// NoticeRequest — запрос на отправку уведомления о реализации маркированного товара в ОИСМ
+type NoticeRequest struct {
+	OismAddress string // host:port
+	FnNumber    string // 16-значный номер ФН
+	Notice      []byte // Уведомление (получено от ККТ): готовое сообщение или контейнер
+}
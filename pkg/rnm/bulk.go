@@ -0,0 +1,154 @@
+package rnm
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Device - строка списка ККТ для пакетной проверки РНМ.
+type Device struct {
+	Line   int // Номер строки в исходном файле
+	RNM    string
+	INN    string
+	Serial string
+}
+
+// Result - результат проверки РНМ одной ККТ.
+type Result struct {
+	Device
+	Err error // nil - РНМ корректен
+}
+
+// OK возвращает true, если РНМ корректен.
+func (r Result) OK() bool { return r.Err == nil }
+
+// csvColumns - допустимые заголовки колонок CSV (без учета регистра).
+var csvColumns = map[string][]string{
+	"rnm":    {"rnm", "рнм", "регистрационный номер"},
+	"inn":    {"inn", "инн", "инн пользователя"},
+	"serial": {"serial", "зн", "заводской номер", "заводской номер ккт"},
+}
+
+// ReadDevicesCSV читает список ККТ из CSV. Первая строка - заголовок с колонками
+// rnm, inn, serial (или РНМ, ИНН, Заводской номер) в любом порядке; остальные
+// колонки игнорируются. Разделитель - ';' или ',' (определяется по заголовку).
+func ReadDevicesCSV(r io.Reader) ([]Device, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(br.Size())
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("ошибка чтения CSV: %w", err)
+	}
+	firstLine := string(head)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = ','
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("пустой CSV")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения заголовка CSV: %w", err)
+	}
+	idx := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for col, aliases := range csvColumns {
+			for _, a := range aliases {
+				if name == a {
+					idx[col] = i
+				}
+			}
+		}
+	}
+	for _, col := range []string{"rnm", "inn", "serial"} {
+		if _, ok := idx[col]; !ok {
+			return nil, fmt.Errorf("в заголовке CSV нет колонки %s", col)
+		}
+	}
+
+	var devices []Device
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(col string) string {
+			if i := idx[col]; i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		dev := Device{Line: line, RNM: field("rnm"), INN: field("inn"), Serial: field("serial")}
+		if dev.RNM == "" && dev.INN == "" && dev.Serial == "" {
+			continue
+		}
+		devices = append(devices, dev)
+	}
+	return devices, nil
+}
+
+// VerifyAll проверяет РНМ всех ККТ списка.
+func VerifyAll(devices []Device) []Result {
+	res := make([]Result, len(devices))
+	for i, dev := range devices {
+		res[i] = Result{Device: dev, Err: VerifyRNM(dev.RNM, dev.INN, dev.Serial)}
+	}
+	return res
+}
+
+// VerifyCSV читает список ККТ из CSV (см. ReadDevicesCSV) и проверяет их РНМ.
+func VerifyCSV(r io.Reader) ([]Result, error) {
+	devices, err := ReadDevicesCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyAll(devices), nil
+}
+
+// WriteResultsCSV записывает результаты проверки в CSV с разделителем ';'.
+func WriteResultsCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	if err := cw.Write([]string{"line", "rnm", "inn", "serial", "status", "error"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		status, msg := "OK", ""
+		if r.Err != nil {
+			status, msg = "ERROR", r.Err.Error()
+		}
+		if err := cw.Write([]string{fmt.Sprint(r.Line), r.RNM, r.INN, r.Serial, status, msg}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CountInvalid возвращает количество некорректных РНМ.
+func CountInvalid(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if !r.OK() {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+// Package rnm рассчитывает и проверяет регистрационный номер ККТ (РНМ).
+//
+// РНМ состоит из 16 цифр: порядковый номер ККТ (10 цифр), присвоенный
+// пользователем, и 6 проверочных цифр - CRC16-CCITT строки из порядкового
+// номера, ИНН пользователя и заводского номера ККТ.
+//
+// Для пакетной проверки РНМ списка ККТ из CSV используется VerifyCSV.
+package rnm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Length - длина РНМ.
+const Length = 16
+
+// GenerateRNM рассчитывает РНМ по порядковому номеру, ИНН пользователя и заводскому номеру ККТ.
+func GenerateRNM(order, inn, serial string) (string, error) {
+	order, inn, serial = strings.TrimSpace(order), strings.TrimSpace(inn), strings.TrimSpace(serial)
+	if !isDigits(order) || len(order) > 10 {
+		return "", fmt.Errorf("порядковый номер должен содержать от 1 до 10 цифр: %q", order)
+	}
+	if !isDigits(inn) || len(inn) > 12 {
+		return "", fmt.Errorf("некорректный ИНН: %q", inn)
+	}
+	if serial == "" || len(serial) > 20 {
+		return "", fmt.Errorf("заводской номер ККТ должен содержать от 1 до 20 символов: %q", serial)
+	}
+
+	// 1. Формируем строку для расчета
+	// Строка: 0000000001 + 007804437548 + 00000000000000000156 (пример)
+	paddedOrder := padLeft(order, 10, '0')
+	calcString := paddedOrder + padLeft(inn, 12, '0') + padLeft(serial, 20, '0')
+
+	// 2. Считаем CRC и дополняем до 6 цифр нулями (CRC 33271 -> "033271")
+	crc := CRC16CCITT([]byte(calcString))
+	return paddedOrder + padLeft(fmt.Sprintf("%d", crc), 6, '0'), nil
+}
+
+// VerifyRNM проверяет проверочные цифры РНМ для указанных ИНН и заводского номера ККТ.
+func VerifyRNM(rnm, inn, serial string) error {
+	rnm = strings.ReplaceAll(strings.TrimSpace(rnm), " ", "")
+	if len(rnm) != Length || !isDigits(rnm) {
+		return fmt.Errorf("РНМ должен содержать %d цифр: %q", Length, rnm)
+	}
+	expected, err := GenerateRNM(rnm[:10], inn, serial)
+	if err != nil {
+		return err
+	}
+	if expected != rnm {
+		return fmt.Errorf("РНМ %s не соответствует ИНН %s и заводскому номеру %s (ожидается %s)", rnm, inn, serial, expected)
+	}
+	return nil
+}
+
+// CRC16CCITT вычисляет CRC-16 (CCITT False)
+// Poly: 0x1021, Init: 0xFFFF
+func CRC16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if (crc & 0x8000) != 0 {
+				crc = (crc << 1) ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// padLeft дополняет строку символом padChar слева до длины length
+func padLeft(s string, length int, padChar byte) string {
+	if len(s) >= length {
+		return s
+	}
+	return strings.Repeat(string(padChar), length-len(s)) + s
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
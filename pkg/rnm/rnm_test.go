@@ -0,0 +1,98 @@
+package rnm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Пример из прежней реализации в интерфейсе: порядковый номер 1, ИНН 7804437548,
+// заводской номер 156 -> CRC 33271.
+const (
+	knownRNM    = "0000000001033271"
+	knownINN    = "7804437548"
+	knownSerial = "156"
+)
+
+func TestGenerateVerifyRNM(t *testing.T) {
+	for _, tc := range []struct{ order, inn, serial, want string }{
+		{"1", knownINN, knownSerial, knownRNM},
+		{"0000000001", knownINN, "00000000000000000156", knownRNM},
+		{"2", "7707083893", "0650012345", "0000000002018364"},
+	} {
+		got, err := GenerateRNM(tc.order, tc.inn, tc.serial)
+		if err != nil || got != tc.want {
+			t.Errorf("GenerateRNM(%s, %s, %s) = %s, %v; want %s", tc.order, tc.inn, tc.serial, got, err, tc.want)
+		}
+	}
+
+	got := knownRNM
+	if err := VerifyRNM(got, knownINN, knownSerial); err != nil {
+		t.Fatalf("VerifyRNM: %v", err)
+	}
+	if err := VerifyRNM(got, "7804437548", "157"); err == nil {
+		t.Fatal("expected mismatch for another serial")
+	}
+	if err := VerifyRNM("123", "7804437548", "156"); err == nil {
+		t.Fatal("expected error for short RNM")
+	}
+	if _, err := GenerateRNM("12345678901", "7804437548", "156"); err == nil {
+		t.Fatal("expected error for long order number")
+	}
+}
+
+func TestCRC16CCITT(t *testing.T) {
+	// Контрольное значение CRC-16/CCITT-FALSE
+	if crc := CRC16CCITT([]byte("123456789")); crc != 0x29B1 {
+		t.Fatalf("unexpected CRC: %#x", crc)
+	}
+}
+
+func TestVerifyCSV(t *testing.T) {
+	good := knownRNM
+	other := "0000000002018364"
+	input := "\ufeffЗаводской номер;ИНН;Комментарий;РНМ\n" +
+		"156;7804437548;магазин 1;" + good + "\n" +
+		"\n" +
+		"157;7804437548;магазин 2;" + good + "\n" +
+		"0650012345;7707083893;;" + other[:10] + " " + other[10:] + "\n"
+
+	results, err := VerifyCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("VerifyCSV: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if !results[0].OK() || results[1].OK() || !results[2].OK() {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[1].Line != 4 || results[1].Serial != "157" {
+		t.Errorf("unexpected device: %+v", results[1].Device)
+	}
+	if n := CountInvalid(results); n != 1 {
+		t.Errorf("CountInvalid = %d", n)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteResultsCSV(&buf, results); err != nil {
+		t.Fatalf("WriteResultsCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "4;"+good+";7804437548;157;ERROR;") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestReadDevicesCSVErrors(t *testing.T) {
+	if _, err := ReadDevicesCSV(strings.NewReader("")); err == nil {
+		t.Error("expected error for empty CSV")
+	}
+	if _, err := ReadDevicesCSV(strings.NewReader("rnm,inn\n1,2\n")); err == nil {
+		t.Error("expected error for missing serial column")
+	}
+	devices, err := ReadDevicesCSV(strings.NewReader("inn,serial,rnm\n7804437548,156,0000000001000000\n"))
+	if err != nil || len(devices) != 1 || devices[0].Serial != "156" {
+		t.Errorf("comma separated CSV: %+v, %v", devices, err)
+	}
+}